@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/config"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+// runAPIKey runs the 'apikey' command, which creates, lists and revokes the API keys.
+//
+// Usage:
+//
+//	app apikey create -name name -role client|partner|admin [-partner-id id] [-scopes scope,...] [configuration flags]
+//	app apikey list [configuration flags]
+//	app apikey revoke -id id [configuration flags]
+func runAPIKey(args []string) {
+	if len(args) == 0 {
+		log.Fatal("missing apikey command: create, list or revoke")
+	}
+
+	fs := flag.NewFlagSet("apikey "+args[0], flag.ExitOnError)
+
+	var err error
+	switch args[0] {
+	case "create":
+		name := fs.String("name", "", "the name of the key's holder")
+		role := fs.String("role", "", "the role of the key: client, partner or admin")
+		partnerID := fs.Uint("partner-id", 0, "the id of the partner a partner's key is for")
+		scopes := fs.String("scopes", "", "the scopes the key is restricted to, separated by commas (defaults to all of its role's)")
+		repo := openAPIKeys(fs, args[1:])
+		err = createAPIKey(repo, *name, *role, *partnerID, *scopes)
+	case "list":
+		repo := openAPIKeys(fs, args[1:])
+		err = listAPIKeys(repo)
+	case "revoke":
+		id := fs.Uint("id", 0, "the id of the key")
+		repo := openAPIKeys(fs, args[1:])
+		err = repo.RevokeAPIKey(context.Background(), *id)
+		if errors.Is(err, repository.ErrNotFound) {
+			err = fmt.Errorf("there is no api key %d, or it is already revoked", *id)
+		}
+	default:
+		err = fmt.Errorf("unknown apikey command '%s', must be create, list or revoke", args[0])
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openAPIKeys loads the configuration, parsing the command's flags, and opens the database.
+func openAPIKeys(fs *flag.FlagSet, args []string) *repository.Database {
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := openDB(context.Background(), cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return repository.NewDatabase(db)
+}
+
+// createAPIKey creates an API key and prints it, which is the only time it is shown.
+func createAPIKey(repo *repository.Database, name, role string, partnerID uint, scopes string) error {
+	if name == "" {
+		return errors.New("the name of the api key is required")
+	}
+
+	r, err := auth.ParseRole(role)
+	if err != nil {
+		return err
+	}
+
+	k := models.APIKey{Name: name, Role: string(r)}
+	switch {
+	case r == auth.RolePartner && partnerID == 0:
+		return errors.New("the partner id of a partner's api key is required")
+	case r != auth.RolePartner && partnerID != 0:
+		return errors.New("only a partner's api key is for a partner")
+	case partnerID != 0:
+		k.PartnerID = &partnerID
+	}
+	for _, s := range strings.Split(scopes, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		scope, err := auth.ParseScope(s)
+		if err != nil {
+			return err
+		}
+		k.Scopes = append(k.Scopes, string(scope))
+	}
+
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+	k.Hash = auth.HashKey(key)
+
+	k, err = repo.CreateAPIKey(context.Background(), k)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "created api key %d for %s, store it now since it can't be shown again:\n", k.ID, k.Name)
+	fmt.Println(key)
+	return nil
+}
+
+// listAPIKeys prints the API keys, without the keys themselves.
+func listAPIKeys(repo *repository.Database) error {
+	ks, err := repo.ListAPIKeys(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tPARTNER\tSCOPES\tCREATED\tREVOKED")
+	for _, k := range ks {
+		scopes := strings.Join(k.Scopes, ",")
+		if scopes == "" {
+			scopes = "-"
+		}
+		partner := "-"
+		if k.PartnerID != nil {
+			partner = strconv.FormatUint(uint64(*k.PartnerID), 10)
+		}
+		revoked := "-"
+		if k.RevokedAt != nil {
+			revoked = k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Role, partner, scopes, k.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return w.Flush()
+}
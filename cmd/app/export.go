@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"match/cmd/pkg/config"
+	"match/cmd/pkg/export"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+// runExport runs the 'export' command, which writes all partners to a file or to the standard output.
+//
+// Usage: app export [-format csv|jsonl|geojson] [-output file] [configuration flags]
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", string(export.FormatCSV), "the export format: csv, jsonl or geojson")
+	output := fs.String("output", "", "the file to write to (defaults to the standard output)")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := export.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := openDB(context.Background(), cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+
+	w, err := export.NewWriter(out, f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repo := repository.NewDatabase(db)
+	err = repo.StreamPartners(context.Background(), func(p models.Partner) error {
+		return w.Write(p)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
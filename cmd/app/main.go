@@ -1,68 +1,549 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"match"
+	"match/cmd/pkg/apiversion"
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/breaker"
+	"match/cmd/pkg/cache"
+	"match/cmd/pkg/config"
+	"match/cmd/pkg/controller/graphql"
+	"match/cmd/pkg/controller/health"
+	"match/cmd/pkg/controller/jobs"
 	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/idempotency"
+	"match/cmd/pkg/index"
+	"match/cmd/pkg/lifecycle"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/metrics"
+	"match/cmd/pkg/openapi"
+	"match/cmd/pkg/ratelimit"
 	"match/cmd/pkg/repository"
+	"match/cmd/pkg/rpc"
+	"match/cmd/pkg/tracing"
+	"match/cmd/pkg/worker"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func main() {
-	db, err := gorm.Open(postgres.Open(getDBDSN()), &gorm.Config{})
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		runAPIKey(os.Args[2:])
+		return
+	}
+
+	// the logs are JSON from the start, at the configured level once it is loaded
+	slog.SetDefault(logging.New(os.Stderr, slog.LevelInfo))
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the configuration, with its secrets redacted, and exit")
+
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		fatal("error loading the configuration", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.SlogLevel()))
+
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+
+	// the components are stopped in the reverse order of their registration, i.e. the server first and the
+	// database last
+	lc := lifecycle.New()
+
+	shutdownTracing, err := tracing.Setup(lc.Context(), tracing.Options{
+		ServiceName: "match",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("error setting up the tracing", err)
+	}
+	lc.OnStop("tracing", shutdownTracing)
+
+	db, err := openDB(lc.Context(), cfg.DB)
+	if err != nil {
+		fatal("error opening the database", err)
+	}
+
+	replicas, err := openReplicas(cfg.DB)
 	if err != nil {
-		log.Fatal(err)
+		fatal("error opening the replicas", err)
+	}
+
+	err = traceDBs(db, replicas)
+	if err != nil {
+		fatal("error tracing the database", err)
+	}
+	lc.OnStop("database", func(context.Context) error {
+		return closeDBs(append([]*gorm.DB{db}, replicas...))
+	})
+
+	m := metrics.New()
+	err = registerDBStats(m, db, replicas)
+	if err != nil {
+		fatal("error registering the database metrics", err)
 	}
 
 	r := mux.NewRouter()
-	repo := repository.NewDatabase(db)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(m.Middleware)
+	r.Handle("/metrics", m.Handler()).Methods(http.MethodGet)
+
+	// the routes are registered from the specification, which is served with its documentation
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		fatal("error loading the openapi specification", err)
+	}
+	// the requests are validated once rate limited and authenticated, so only the clients allowed to use an operation
+	// get their bodies buffered and checked
+	validate := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if cfg.API.ValidateRequests {
+		validator := spec.Validator(openapi.ValidatorOptions{Responses: cfg.API.ValidateResponses})
+		validate = func(next http.HandlerFunc) http.HandlerFunc { return validator(next).ServeHTTP }
+	}
+	r.HandleFunc("/openapi.yml", spec.ServeYAML).Methods(http.MethodGet)
+	r.HandleFunc("/openapi.json", spec.ServeJSON).Methods(http.MethodGet)
+	r.HandleFunc("/docs", spec.ServeDocs).Methods(http.MethodGet)
+	r.HandleFunc("/docs/redoc.js", spec.ServeRedoc).Methods(http.MethodGet)
+
+	repo := repository.NewDatabase(
+		db,
+		repository.WithQueryTimeout(cfg.DB.QueryTimeout),
+		repository.WithReadRetries(cfg.DB.ReadRetries, cfg.DB.ReadRetryBackoff),
+		repository.WithBreaker(breaker.New(breaker.Options{Threshold: cfg.DB.BreakerThreshold, Cooldown: cfg.DB.BreakerCooldown})),
+		repository.WithReplicas(replicas...),
+		repository.WithReplicaMaxLag(cfg.DB.ReplicaMaxLag),
+		repository.WithMatchRanking(cfg.Match.Ranking()),
+	)
+	lc.Go("replica checks", func(ctx context.Context) {
+		repo.RunReplicaChecks(ctx, cfg.DB.ReplicaCheckInterval)
+	})
+
+	if cfg.DB.ReadYourWrites {
+		r.Use(readYourWrites)
+	}
+
+	// the queries are measured, by the repository's method that runs them, except the health checks'
+	measuredRepo := m.WrapRepository(repo)
+
+	cachedRepo := cache.NewDatabase(measuredRepo, cache.Options{
+		Size:     cfg.Cache.Size,
+		TTL:      cfg.Cache.TTL,
+		GridSize: cfg.Cache.GridSize,
+	})
+	err = m.RegisterCacheStats(cachedRepo)
+	if err != nil {
+		fatal("error registering the cache metrics", err)
+	}
 
-	partnersHandler := partners.NewHandler(repo)
-	registerPartnersHandler(r, partnersHandler)
+	var partnersRepo partners.Database = cachedRepo
+	var idx *index.Database
+	if cfg.Index.Enabled {
+		idx = index.NewDatabase(cachedRepo, index.WithMatchRanking(cfg.Match.Ranking()))
+		err = idx.Load(lc.Context())
+		if err != nil {
+			slog.Error("error loading the partners index", "error", err)
+		}
+		lc.Go("partners index", func(ctx context.Context) {
+			idx.Run(ctx, cfg.Index.RefreshInterval)
+		})
+		partnersRepo = idx
+	}
+
+	// the matches are recorded as served, i.e. from the index or the cache when there are
+	partnersRepo = m.WrapMatches(partnersRepo)
+
+	listener := repository.NewListener(db)
+	go watchPartnerChanges(listener.Subscribe(), repo, cachedRepo, idx)
+	lc.Go("partner changes listener", listener.Run)
+
+	var tokens *auth.Verifier
+	if cfg.Auth.JWT() {
+		tokens, err = auth.NewVerifier(lc.Context(), auth.TokenOptions{
+			JWKSFile:     cfg.Auth.JWKSFile,
+			JWKSURL:      cfg.Auth.JWKSURL,
+			JWKSRefresh:  cfg.Auth.JWKSRefresh,
+			Issuer:       cfg.Auth.JWTIssuer,
+			Audience:     cfg.Auth.JWTAudience,
+			RoleClaim:    cfg.Auth.JWTRoleClaim,
+			PartnerClaim: cfg.Auth.JWTPartnerClaim,
+		})
+		if err != nil {
+			fatal("error loading the jwks", err)
+		}
+		lc.Go("jwks refresh", tokens.Run)
+	}
+
+	authenticator := auth.NewAuthenticator(measuredRepo, auth.Options{
+		CacheTTL:     cfg.Auth.KeyCacheTTL,
+		BootstrapKey: cfg.Auth.BootstrapKey.Value(),
+		Tokens:       tokens,
+	})
+
+	limiter := newLimiter(lc, cfg.RateLimit, measuredRepo, authenticator)
+
+	idempotencyKeys := idempotency.New(measuredRepo, idempotency.Options{
+		TTL:         cfg.Idempotency.TTL,
+		LockTimeout: cfg.Idempotency.LockTimeout,
+	})
+	lc.Go("idempotency keys cleanup", func(ctx context.Context) {
+		idempotencyKeys.Run(ctx, time.Hour)
+	})
+
+	partnersHandler := partners.NewHandler(partnersRepo)
+	jobsHandler := jobs.NewHandler(measuredRepo)
+
+	// a version with other response shapes, e.g. v2, is mounted before v1 with the handlers that return them
+	v1 := func(router *mux.Router) {
+		err := spec.Register(router, apiOperations(partnersHandler, jobsHandler, authenticator, limiter, idempotencyKeys, validate))
+		if err != nil {
+			fatal("error registering the v1 routes", err)
+		}
+	}
+
+	// the deprecations were validated with the configuration
+	deprecations, _ := cfg.API.Deprecations()
+	apiversion.Mount(r, apiversion.Version{Name: "v1", Deprecation: deprecations["v1"]}, v1)
+	apiversion.Mount(r, apiversion.Version{Deprecation: deprecations[apiversion.Unversioned]}, v1)
+	for version := range deprecations {
+		if version != "v1" && version != apiversion.Unversioned {
+			slog.Warn("deprecation of an unknown api version", "version", version)
+		}
+	}
 
-	p := getOSEnv("APP_PORT")
-	s := http.Server{
-		Addr:         fmt.Sprintf(":%s", p),
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 90 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	// the fields of the queries are authorized by their resolvers, and the schema evolves without versions
+	graphqlHandler := graphql.NewHandler(partnersRepo, limiter)
+	r.HandleFunc("/graphql", limiter.Limit("graphql", authenticator.Require("", graphqlHandler.Query))).Methods(http.MethodPost)
+
+	if routes := limiter.UnknownRoutes(); len(routes) > 0 {
+		slog.Warn("rate limits of unknown routes", "routes", routes)
+	}
+
+	healthHandler := health.NewHandler()
+	healthHandler.Register("database", health.CheckerFunc(repo.Ping))
+	healthHandler.Register("migrations", health.CheckerFunc(repo.CheckMigrations))
+	if idx != nil {
+		healthHandler.Register("partners index", health.CheckerFunc(func(context.Context) error {
+			if !idx.Loaded() {
+				return errors.New("the partners are not loaded yet")
+			}
+			return nil
+		}))
+	}
+	err = spec.Register(r, healthOperations(healthHandler))
+	if err != nil {
+		fatal("error registering the health routes", err)
+	}
+
+	if ops := spec.Unregistered(); len(ops) > 0 {
+		fatal("error registering the routes", fmt.Errorf("operations without a handler: %s", strings.Join(ops, ", ")))
+	}
+
+	// a job being processed when the worker stops is resumed by the next worker once its lock expires
+	w := worker.NewWorker(measuredRepo, partnersRepo)
+	lc.Go("jobs worker", w.Run)
+
+	// the gRPC server is stopped with the HTTP server, after the readiness of both is withdrawn
+	var grpcHealth *grpchealth.Server
+	if cfg.GRPC.Enabled {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPC.Port))
+		if err != nil {
+			fatal("error listening for grpc calls", err)
+		}
+
+		var gs *grpc.Server
+		gs, grpcHealth = rpc.New(partnersRepo, authenticator, limiter)
+		lc.OnStop("grpc server", func(ctx context.Context) error {
+			return stopGRPCServer(ctx, gs)
+		})
+
+		go func() {
+			err := gs.Serve(lis)
+			if err != nil {
+				lc.Stop(err)
+			}
+		}()
+	}
+
+	s := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.HTTP.Port),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
 		Handler:      r,
 	}
+	lc.OnStop("http server", func(ctx context.Context) error {
+		return shutdownServer(ctx, s)
+	})
+	lc.OnStop("readiness", func(ctx context.Context) error {
+		healthHandler.ShuttingDown()
+		if grpcHealth != nil {
+			grpcHealth.Shutdown()
+		}
+		return wait(ctx, cfg.HTTP.ShutdownDelay)
+	})
+
+	go func() {
+		err := s.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			lc.Stop(err)
+		}
+	}()
+
+	err = lc.Wait()
+	if err != nil {
+		slog.Error("error running the server, shutting down", "error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	err = lc.Shutdown(ctx)
+	if err != nil {
+		slog.Error("error shutting down", "error", err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// shutdownServer stops the server from accepting requests and waits for the in-flight ones to finish, until the
+// context is done, when the connections still open are closed.
+func shutdownServer(ctx context.Context, s *http.Server) error {
+	err := s.Shutdown(ctx)
+	if err != nil {
+		_ = s.Close()
+		return fmt.Errorf("error waiting for the in-flight requests: %w", err)
+	}
+	return nil
+}
+
+// stopGRPCServer stops the server from accepting calls and waits for the in-flight ones to finish, until the context
+// is done, when the connections still open are closed.
+func stopGRPCServer(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return fmt.Errorf("error waiting for the in-flight calls: %w", ctx.Err())
+	}
+}
+
+// wait waits for the given time, or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
 
-	err = s.ListenAndServe()
+// traceDBs traces the statements run on the primary database and on its replicas.
+func traceDBs(db *gorm.DB, replicas []*gorm.DB) error {
+	err := db.Use(tracing.NewGormPlugin(false))
 	if err != nil {
-		if err != http.ErrServerClosed {
-			log.Println(err)
+		return fmt.Errorf("error trying to trace the database: %w", err)
+	}
+
+	for _, r := range replicas {
+		err = r.Use(tracing.NewGormPlugin(true))
+		if err != nil {
+			return fmt.Errorf("error trying to trace the replica: %w", err)
+		}
+	}
+	return nil
+}
+
+// registerDBStats exposes the statistics of the connection pools of the primary database and of its replicas.
+func registerDBStats(m *metrics.Metrics, db *gorm.DB, replicas []*gorm.DB) error {
+	for i, h := range append([]*gorm.DB{db}, replicas...) {
+		sqlDB, err := h.DB()
+		if err != nil {
+			return err
+		}
+
+		name := "primary"
+		if i > 0 {
+			name = fmt.Sprintf("replica-%d", i-1)
+		}
+
+		err = m.RegisterDBStats(name, sqlDB)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeDBs closes the connection pools of the databases.
+func closeDBs(dbs []*gorm.DB) error {
+	for _, db := range dbs {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		err = sqlDB.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchPartnerChanges invalidates the cached partners, and refreshes the index when there is one, on every change.
+// The partners are read from the primary first, so they are not cached again, or indexed, from a lagging replica.
+func watchPartnerChanges(changes <-chan repository.Change, repo *repository.Database, c *cache.Database, idx *index.Database) {
+	for change := range changes {
+		repo.PartnersChanged()
+
+		if change.AllPartners() {
+			c.Purge()
+		} else {
+			c.InvalidatePartner(change.PartnerID)
+		}
+
+		if idx != nil {
+			idx.Refresh()
+		}
+	}
+}
+
+// openDB opens the database, waiting for it to be reachable for up to the connect timeout, or until the context is
+// done.
+func openDB(ctx context.Context, cfg config.DB) (*gorm.DB, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+
+	return repository.Open(ctx, postgres.Open(cfg.DSN(cfg.Host, cfg.Port)), getOpenOptions(cfg))
+}
+
+// openReplicas opens the read replicas, which have the same user, password and database name as the primary.
+func openReplicas(cfg config.DB) ([]*gorm.DB, error) {
+	var replicas []*gorm.DB
+	for _, hostPort := range cfg.ReplicaHosts {
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the replica host '%s': %w", hostPort, err)
+		}
+
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the port of the replica host '%s': %w", hostPort, err)
+		}
+
+		replica, err := repository.OpenReplica(postgres.Open(cfg.DSN(host, p)), getOpenOptions(cfg))
+		if err != nil {
+			return nil, err
 		}
+		replicas = append(replicas, replica)
 	}
+
+	return replicas, nil
 }
 
-func getDBDSN() string {
-	host := getOSEnv("PSQL_HOST")
-	port := getOSEnv("PSQL_PORT")
-	user := getOSEnv("PSQL_USER")
-	password := getOSEnv("PSQL_PASSWORD")
-	dbName := getOSEnv("PSQL_DB_NAME")
+func getOpenOptions(cfg config.DB) repository.OpenOptions {
+	return repository.OpenOptions{
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		Logger:          logging.NewGormLogger(cfg.SlowQuery),
+	}
+}
 
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbName)
+// readYourWrites makes the reads of a request that follow one of its writes go to the primary database.
+func readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(repository.WithReadYourWrites(r.Context())))
+	})
 }
 
-func getOSEnv(key string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		log.Fatalf("please provide and env variable for the key '%s'", key)
+// newLimiter creates the rate limiter of the routes, which counts the requests in memory or, to share the limits
+// between the instances, in the database. When the rate limits are disabled it doesn't limit any route.
+func newLimiter(lc *lifecycle.Lifecycle, cfg config.RateLimit, buckets ratelimit.Buckets, a *auth.Authenticator) *ratelimit.Limiter {
+	if !cfg.Enabled {
+		return ratelimit.New(nil, ratelimit.Options{})
+	}
+
+	// the limits were validated with the configuration
+	def, routes, _ := cfg.Limits()
+	opts := ratelimit.Options{Default: def, Routes: routes, TrustForwardedFor: cfg.TrustForwardedFor, Authenticator: a}
+
+	if cfg.Store == "postgres" {
+		store := ratelimit.NewSharedStore(buckets)
+		lc.Go("rate limit buckets cleanup", func(ctx context.Context) {
+			store.Run(ctx, time.Minute)
+		})
+		return ratelimit.New(store, opts)
+	}
+
+	return ratelimit.New(ratelimit.NewMemoryStore(), opts)
+}
+
+// apiOperations returns the handlers of the API's operations, by their id in openapi.yml. They are rate limited
+// before they are authenticated, so the requests with invalid credentials are limited too, and they are named after
+// their operation for their limits. The requests are validated against their operation once authenticated. The POST
+// operations accept an idempotency key, once authenticated, since the keys are per client.
+func apiOperations(p partners.Handler, j jobs.Handler, a *auth.Authenticator, l *ratelimit.Limiter, k *idempotency.Keys, v func(http.HandlerFunc) http.HandlerFunc) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		// a partner can only look up and edit itself, see auth.OwnPartner
+		"list_partners":   l.Limit("list_partners", a.Require(auth.ScopePartnersRead, auth.OwnPartner(v(p.ListPartners)))),
+		"match":           l.Limit("match", a.Require(auth.ScopeMatch, v(k.Wrap(p.GetMatches)))),
+		"match_batch":     l.Limit("match_batch", a.Require(auth.ScopeMatch, v(k.Wrap(p.GetBatchMatches)))),
+		"get_partner":     l.Limit("get_partner", a.Require(auth.ScopePartnersRead, auth.OwnPartner(v(p.GetPartnerById)))),
+		"update_partner":  l.Limit("update_partner", a.Require(auth.ScopePartnersWrite, auth.OwnPartner(v(p.UpdatePartner)))),
+		"export_partners": l.Limit("export_partners", a.Require(auth.ScopePartnersExport, v(p.Export))),
+
+		"create_job":      l.Limit("create_job", a.Require(auth.ScopeJobs, v(k.Wrap(j.CreateJob)))),
+		"get_job":         l.Limit("get_job", a.Require(auth.ScopeJobs, v(j.GetJob))),
+		"get_job_results": l.Limit("get_job_results", a.Require(auth.ScopeJobs, v(j.GetJobResults))),
 	}
-	return v
 }
 
-func registerPartnersHandler(router *mux.Router, handler partners.Handler) {
-	router.HandleFunc("/partners/match", handler.GetMatches).Methods(http.MethodPost)
-	router.HandleFunc("/partners/{id:[0-9]+}", handler.GetPartnerById).Methods(http.MethodGet)
+// healthOperations returns the handlers of the health checks, by their id in openapi.yml.
+func healthOperations(h *health.Handler) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"liveness":  h.Liveness,
+		"readiness": h.Readiness,
+	}
 }
@@ -17,6 +17,9 @@ import (
 
 const url string = "http://localhost:8080"
 
+// apiKey is the bootstrap key of the docker-compose.yml app, an admin.
+const apiKey string = "local-admin-key"
+
 func TestIntegration(t *testing.T) {
 	c := &http.Client{Timeout: 30 * time.Second}
 
@@ -37,6 +40,7 @@ func testGetMatches(t *testing.T, c *http.Client) {
 	}
 	`
 	req, _ := http.NewRequest(http.MethodPost, url+"/partners/match", strings.NewReader(getMatchBody))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	resp, _ := c.Do(req)
 	defer resp.Body.Close()
 
@@ -168,6 +172,7 @@ func testGetMatches(t *testing.T, c *http.Client) {
 
 func testGetPartnerById(t *testing.T, c *http.Client) {
 	req, _ := http.NewRequest(http.MethodGet, url+"/partners/1", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	resp, _ := c.Do(req)
 	defer resp.Body.Close()
 
@@ -0,0 +1,127 @@
+// Package apiversion serves each version of the API under its own prefix, e.g. '/v1', so a version can change the
+// shape of its responses without breaking the clients of the others, and tells the clients of a retired version when
+// it stops being served.
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Unversioned is the name of the routes without a version prefix, from before the API was versioned.
+const Unversioned = "unversioned"
+
+// The headers of a deprecated version, see https://www.rfc-editor.org/rfc/rfc9745 and
+// https://www.rfc-editor.org/rfc/rfc8594.
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+)
+
+// dateLayout is the layout of the dates of a Deprecation.
+const dateLayout = "2006-01-02"
+
+// Deprecation is when a version was, or will be, deprecated and when it stops being served. The zero Deprecation is
+// a version that is not deprecated.
+type Deprecation struct {
+	Date time.Time
+	// Sunset is when the version stops being served. Zero if it is not known yet.
+	Sunset time.Time
+}
+
+// ParseDeprecation parses a deprecation as 'date' or 'date/sunset', e.g. '2026-01-01/2026-07-01'.
+func ParseDeprecation(s string) (Deprecation, error) {
+	date, sunset, hasSunset := strings.Cut(s, "/")
+
+	d, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return Deprecation{}, fmt.Errorf("invalid deprecation '%s', the date must be YYYY-MM-DD", s)
+	}
+
+	if !hasSunset {
+		return Deprecation{Date: d}, nil
+	}
+
+	sd, err := time.Parse(dateLayout, sunset)
+	if err != nil {
+		return Deprecation{}, fmt.Errorf("invalid deprecation '%s', the sunset must be YYYY-MM-DD", s)
+	}
+	if sd.Before(d) {
+		return Deprecation{}, fmt.Errorf("invalid deprecation '%s', the sunset is before the deprecation", s)
+	}
+
+	return Deprecation{Date: d, Sunset: sd}, nil
+}
+
+// Deprecated reports whether the version is deprecated.
+func (d Deprecation) Deprecated() bool {
+	return !d.Date.IsZero()
+}
+
+// Version is a version of the API.
+type Version struct {
+	// Name is the name of the version, e.g. 'v1', which prefixes its routes. The empty name is the unversioned routes.
+	Name string
+	Deprecation
+}
+
+// Prefix returns the prefix of the routes of the version, e.g. '/v1', which is empty for the unversioned routes.
+func (v Version) Prefix() string {
+	if v.Name == "" {
+		return ""
+	}
+	return "/" + v.Name
+}
+
+// Mount registers the routes of the version, with register, under its prefix. When it is deprecated its responses
+// have the 'Deprecation' header and, once its sunset is known, the 'Sunset' header.
+//
+// The versions are matched in the order they are mounted, so the unversioned routes must be mounted after the others.
+func Mount(r *mux.Router, v Version, register func(*mux.Router)) {
+	var sub *mux.Router
+	if v.Name == "" {
+		sub = r.NewRoute().Subrouter()
+	} else {
+		sub = r.PathPrefix(v.Prefix()).Subrouter()
+	}
+
+	sub.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if v.Deprecated() {
+				w.Header().Set(HeaderDeprecation, "@"+strconv.FormatInt(v.Date.Unix(), 10))
+				if !v.Sunset.IsZero() {
+					w.Header().Set(HeaderSunset, v.Sunset.UTC().Format(http.TimeFormat))
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithVersion(r.Context(), v)))
+		})
+	})
+
+	register(sub)
+}
+
+type contextKey struct{}
+
+// WithVersion returns a copy of the context with the version of the request.
+func WithVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// FromContext returns the version of the request, and whether it has one.
+func FromContext(ctx context.Context) (Version, bool) {
+	v, ok := ctx.Value(contextKey{}).(Version)
+	return v, ok
+}
+
+// Path returns the path, e.g. of a 'Location' header, under the prefix of the request's version, so the clients stay
+// on the version they use.
+func Path(ctx context.Context, path string) string {
+	v, _ := FromContext(ctx)
+	return v.Prefix() + path
+}
@@ -0,0 +1,96 @@
+package apiversion_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/apiversion"
+
+	"github.com/gorilla/mux"
+)
+
+// routes registers a route that answers with the version of the request, as prefixed to its path.
+func routes(shape string) func(*mux.Router) {
+	return func(r *mux.Router) {
+		r.HandleFunc("/partners/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", apiversion.Path(r.Context(), "/partners/"+mux.Vars(r)["id"]))
+			_, _ = w.Write([]byte(shape))
+		}).Methods(http.MethodGet)
+	}
+}
+
+func TestMount(t *testing.T) {
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	r := mux.NewRouter()
+	apiversion.Mount(r, apiversion.Version{Name: "v2"}, routes("v2"))
+	apiversion.Mount(r, apiversion.Version{Name: "v1"}, routes("v1"))
+	apiversion.Mount(r, apiversion.Version{Deprecation: apiversion.Deprecation{Date: deprecated, Sunset: sunset}}, routes("v1"))
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	for _, tc := range []struct {
+		path        string
+		code        int
+		body        string
+		location    string
+		deprecation string
+		sunset      string
+	}{
+		{path: "/v2/partners/1", code: http.StatusOK, body: "v2", location: "/v2/partners/1"},
+		{path: "/v1/partners/1", code: http.StatusOK, body: "v1", location: "/v1/partners/1"},
+		{path: "/partners/1", code: http.StatusOK, body: "v1", location: "/partners/1", deprecation: "@1767225600", sunset: "Wed, 01 Jul 2026 00:00:00 GMT"},
+		{path: "/v3/partners/1", code: http.StatusNotFound},
+		// the routes that are not versioned are still served
+		{path: "/healthz", code: http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rr.Code != tc.code {
+			t.Errorf("%s status code mismatch: want %v got %v", tc.path, tc.code, rr.Code)
+		}
+
+		if tc.body != "" && rr.Body.String() != tc.body {
+			t.Errorf("%s body mismatch: want %s got %s", tc.path, tc.body, rr.Body.String())
+		}
+
+		if got := rr.Header().Get("Location"); got != tc.location {
+			t.Errorf("%s location mismatch: want '%s' got '%s'", tc.path, tc.location, got)
+		}
+
+		if got := rr.Header().Get(apiversion.HeaderDeprecation); got != tc.deprecation {
+			t.Errorf("%s deprecation mismatch: want '%s' got '%s'", tc.path, tc.deprecation, got)
+		}
+
+		if got := rr.Header().Get(apiversion.HeaderSunset); got != tc.sunset {
+			t.Errorf("%s sunset mismatch: want '%s' got '%s'", tc.path, tc.sunset, got)
+		}
+	}
+}
+
+func TestParseDeprecation(t *testing.T) {
+	d, err := apiversion.ParseDeprecation("2026-01-01/2026-07-01")
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := apiversion.Deprecation{
+		Date:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset: time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if d != expected {
+		t.Errorf("deprecation mismatch: want %v got %v", expected, d)
+	}
+
+	for _, s := range []string{"", "2026-13-01", "2026-01-01/soon", "2026-07-01/2026-01-01"} {
+		_, err := apiversion.ParseDeprecation(s)
+
+		if err == nil {
+			t.Errorf("'%s' error mismatch: want an invalid deprecation error got 'nil'", s)
+		}
+	}
+}
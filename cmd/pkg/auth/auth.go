@@ -0,0 +1,135 @@
+// Package auth authenticates the clients of the API with API keys or JWTs, and authorizes their requests by the role
+// and scopes of their credentials.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"match/cmd/pkg/models"
+)
+
+// Role is the role of a client of the API, which grants it the permissions of a set of scopes.
+type Role string
+
+const (
+	// RoleClient is a customer facing client, which matches the customers with partners.
+	RoleClient Role = "client"
+	// RolePartner is a partner, which looks up and edits its own partner.
+	RolePartner Role = "partner"
+	// RoleAdmin is an administrator, which can do anything.
+	RoleAdmin Role = "admin"
+)
+
+// Scope is a permission to use a group of endpoints.
+type Scope string
+
+const (
+	// ScopeMatch allows matching customers with partners, one request or a batch at a time.
+	ScopeMatch Scope = "match"
+	// ScopeJobs allows creating match jobs and retrieving their results.
+	ScopeJobs Scope = "jobs"
+	// ScopePartnersRead allows looking up and listing the partners, including their locations.
+	ScopePartnersRead Scope = "partners:read"
+	// ScopePartnersWrite allows editing the partners.
+	ScopePartnersWrite Scope = "partners:write"
+	// ScopePartnersExport allows exporting all the partners.
+	ScopePartnersExport Scope = "partners:export"
+)
+
+// roleScopes are the scopes granted by each role.
+var roleScopes = map[Role][]Scope{
+	RoleClient:  {ScopeMatch, ScopeJobs},
+	RolePartner: {ScopePartnersRead, ScopePartnersWrite},
+	RoleAdmin:   {ScopeMatch, ScopeJobs, ScopePartnersRead, ScopePartnersWrite, ScopePartnersExport},
+}
+
+// ParseRole parses a role, e.g. 'client'.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleScopes[r]; !ok {
+		return "", fmt.Errorf("invalid role '%s', must be client, partner or admin", s)
+	}
+	return r, nil
+}
+
+// ParseScope parses a scope, e.g. 'partners:read'.
+func ParseScope(s string) (Scope, error) {
+	for _, scope := range roleScopes[RoleAdmin] {
+		if scope == Scope(s) {
+			return scope, nil
+		}
+	}
+	return "", fmt.Errorf("invalid scope '%s', must be match, jobs, partners:read, partners:write or partners:export", s)
+}
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// ID is the unique identity of the client, e.g. 'key:12' for the API key 12 or 'jwt:<iss>:<sub>' for a JWT, with
+	// its partner for a partner. Unlike its name, it tells any two clients apart.
+	ID string
+	// Name is the name of the client's API key, or the subject of its JWT.
+	Name string
+	Role Role
+	// Scopes are the scopes the client is allowed to use, i.e. the ones granted by its role that its credentials
+	// are restricted to, if they are.
+	Scopes []Scope
+	// PartnerID is the partner a partner is, the only one it can access. It is 0 for the other roles.
+	PartnerID uint
+}
+
+// NewPrincipal creates the principal authenticated by an API key.
+func NewPrincipal(k models.APIKey) Principal {
+	p := Principal{ID: "key:" + strconv.FormatUint(uint64(k.ID), 10), Name: k.Name, Role: Role(k.Role)}
+	if p.Role == RolePartner && k.PartnerID != nil {
+		p.PartnerID = *k.PartnerID
+		p.ID += ":partner:" + strconv.FormatUint(uint64(p.PartnerID), 10)
+	}
+
+	for _, scope := range roleScopes[p.Role] {
+		if len(k.Scopes) == 0 || contains(k.Scopes, string(scope)) {
+			p.Scopes = append(p.Scopes, scope)
+		}
+	}
+
+	return p
+}
+
+// Can reports whether the principal is allowed to use the scope.
+func (p Principal) Can(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessPartner reports whether the principal is allowed to access the partner. A partner can only access
+// itself, and a partner's credentials without a partner can't access any.
+func (p Principal) CanAccessPartner(id uint) bool {
+	return p.Role != RolePartner || (p.PartnerID != 0 && p.PartnerID == id)
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of the context that carries the authenticated client of the request.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the authenticated client of the request, if it was authenticated.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
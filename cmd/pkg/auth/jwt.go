@@ -0,0 +1,399 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultJWKSRefresh  = 5 * time.Minute
+	defaultRoleClaim    = "role"
+	defaultPartnerClaim = "partner_id"
+
+	// minJWKSReload is the minimum time between two loads of the JWKS caused by tokens signed with an unknown key,
+	// so made up key IDs can't flood the JWKS endpoint.
+	minJWKSReload = 30 * time.Second
+
+	// maxJWKSSize is the maximum size of a JWKS, in bytes.
+	maxJWKSSize = 1 << 20
+)
+
+// signingMethods are the accepted signing algorithms. Any other, notably 'none' and the HMAC ones, is rejected.
+var signingMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+
+// TokenOptions configures a Verifier.
+type TokenOptions struct {
+	// JWKSFile is the path of the file with the JWKS, i.e. the public keys that sign the tokens. Either it or
+	// JWKSURL is required.
+	JWKSFile string
+	// JWKSURL is the URL the JWKS is fetched from, e.g. an internal identity provider's.
+	JWKSURL string
+	// JWKSRefresh is the interval the JWKS is loaded again at, so rotated keys are picked up. Defaults to five
+	// minutes.
+	JWKSRefresh time.Duration
+
+	// Issuer is the required 'iss' claim of the tokens. Empty accepts any issuer.
+	Issuer string
+	// Audience is the audience, one of the 'aud' claim, the tokens must be for. Empty accepts any audience.
+	Audience string
+
+	// RoleClaim is the claim with the role of the token's holder. Defaults to 'role'.
+	RoleClaim string
+	// PartnerClaim is the claim with the id of the partner a partner's token is for, a number or a string. Defaults
+	// to 'partner_id'.
+	PartnerClaim string
+
+	// Client is the client the JWKS is fetched with. Defaults to a client with a ten seconds timeout.
+	Client *http.Client
+}
+
+// Verifier verifies the JWTs issued by our frontends, signed with RS256 or ES256 by one of the keys of a JWKS, and
+// maps their claims to a principal.
+type Verifier struct {
+	opts TokenOptions
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewVerifier creates a new Verifier, loading its JWKS.
+func NewVerifier(ctx context.Context, opts TokenOptions) (*Verifier, error) {
+	if (opts.JWKSFile == "") == (opts.JWKSURL == "") {
+		return nil, errors.New("either a jwks file or a jwks url is required")
+	}
+
+	if opts.JWKSRefresh <= 0 {
+		opts.JWKSRefresh = defaultJWKSRefresh
+	}
+	if opts.RoleClaim == "" {
+		opts.RoleClaim = defaultRoleClaim
+	}
+	if opts.PartnerClaim == "" {
+		opts.PartnerClaim = defaultPartnerClaim
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := &Verifier{opts: opts}
+	err := v.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Load loads the JWKS again. The keys loaded before are kept if it fails.
+func (v *Verifier) Load(ctx context.Context) error {
+	var data []byte
+	var err error
+	if v.opts.JWKSFile != "" {
+		data, err = os.ReadFile(v.opts.JWKSFile)
+	} else {
+		data, err = v.fetch(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error trying to load the jwks: %w", err)
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.loadedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *Verifier) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.opts.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// Run loads the JWKS every refresh interval until the context is done.
+func (v *Verifier) Run(ctx context.Context) {
+	t := time.NewTicker(v.opts.JWKSRefresh)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		err := v.Load(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("error refreshing the jwks", "error", err)
+		}
+	}
+}
+
+// Verify verifies the token's signature and its 'exp', 'nbf', 'iss' and 'aud' claims, and returns the principal
+// of its holder: named by its 'sub' claim, with the role of its role claim and, for partners, the partner of its
+// partner claim. The scopes of this API in the token's 'scope' claim, if any, restrict its role's scopes like an
+// API key's scopes do.
+// An invalid token returns an error wrapping ErrInvalidCredentials.
+func (v *Verifier) Verify(ctx context.Context, token string) (Principal, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	}
+	if v.opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.opts.Issuer))
+	}
+	if v.opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(v.opts.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	}, parserOpts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	p, err := v.principal(claims)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return p, nil
+}
+
+// key returns the key with the given id, loading the JWKS again if it is unknown, since it may have been rotated.
+// A token without a key id is verified with the only key of the JWKS, if it has one.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	k, ok := v.lookup(kid)
+	stale := time.Since(v.loadedAt) >= minJWKSReload
+	v.mu.RUnlock()
+
+	if ok {
+		return k, nil
+	}
+
+	if stale {
+		err := v.Load(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("error reloading the jwks", "error", err)
+		}
+
+		v.mu.RLock()
+		k, ok = v.lookup(kid)
+		v.mu.RUnlock()
+		if ok {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key '%s'", kid)
+}
+
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// principal maps the verified claims of a token to its principal.
+func (v *Verifier) principal(claims jwt.MapClaims) (Principal, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Principal{}, errors.New("missing sub claim")
+	}
+
+	role, _ := claims[v.opts.RoleClaim].(string)
+	r, err := ParseRole(role)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	k := models.APIKey{Name: sub, Role: string(r)}
+	// the token may have the scopes of other APIs too, e.g. 'openid', which are ignored
+	if scope, ok := claims["scope"].(string); ok {
+		for _, s := range strings.Fields(scope) {
+			if _, err := ParseScope(s); err == nil {
+				k.Scopes = append(k.Scopes, s)
+			}
+		}
+	}
+
+	if r == RolePartner {
+		id, err := partnerID(claims[v.opts.PartnerClaim])
+		if err != nil {
+			return Principal{}, fmt.Errorf("invalid %s claim: %w", v.opts.PartnerClaim, err)
+		}
+		k.PartnerID = &id
+	}
+
+	// the JWTs have no key id, and the subjects are only unique for their issuer
+	p := NewPrincipal(k)
+	iss, _ := claims["iss"].(string)
+	p.ID = "jwt:" + iss + ":" + sub
+	if p.PartnerID != 0 {
+		p.ID += ":partner:" + strconv.FormatUint(uint64(p.PartnerID), 10)
+	}
+	return p, nil
+}
+
+// partnerID parses the partner claim, which is a JSON number or a string.
+func partnerID(claim interface{}) (uint, error) {
+	var id uint64
+	var err error
+	switch c := claim.(type) {
+	case float64:
+		if c < 1 || c != float64(uint64(c)) {
+			return 0, fmt.Errorf("'%v' is not a partner id", c)
+		}
+		id = uint64(c)
+	case string:
+		id, err = strconv.ParseUint(c, 10, 64)
+		if err != nil || id == 0 {
+			return 0, fmt.Errorf("'%s' is not a partner id", c)
+		}
+	case nil:
+		return 0, errors.New("missing partner id")
+	default:
+		return 0, fmt.Errorf("'%v' is not a partner id", c)
+	}
+	return uint(id), nil
+}
+
+// jwk is a JSON Web Key, with the parameters of the RSA and EC public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set, returning its RSA and P-256 signature keys by their key id. The other keys,
+// e.g. encryption ones, are ignored, but a set without any usable key is an error.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("error trying to parse the jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		switch {
+		case k.Kty == "RSA" && (k.Alg == "" || k.Alg == jwt.SigningMethodRS256.Alg()):
+			key, err = parseRSAKey(k)
+		case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == jwt.SigningMethodES256.Alg()):
+			key, err = parseECKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error trying to parse the jwks key '%s': %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("the jwks has no RS256 or ES256 signature keys")
+	}
+
+	return keys, nil
+}
+
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if key.N.BitLen() < 2048 || key.E < 3 {
+		return nil, errors.New("the rsa key is too weak")
+	}
+	return key, nil
+}
+
+func parseECKey(k jwk) (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != 32 {
+		return nil, errors.New("invalid x coordinate")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil || len(y) != 32 {
+		return nil, errors.New("invalid y coordinate")
+	}
+
+	// the uncompressed point is only parsed to check it is on the curve
+	_, err = ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// keyPrefix is the prefix of the API keys, which tells them apart from other secrets, e.g. in a leaked file.
+const keyPrefix = "mk_"
+
+// GenerateKey returns a new random API key. It is shown to its holder once and only its hash, see HashKey, is
+// stored.
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error trying to generate the api key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashKey returns the hash under which an API key is stored. The keys are random, and long, so a fast hash is
+// enough, unlike for passwords.
+func HashKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultCacheTTL = time.Minute
+
+	// scheme is the scheme of the 'Authorization' header with the API key or the JWT, e.g. 'Bearer mk_...'.
+	scheme = "Bearer"
+	// HeaderAPIKey is the header with the API key, an alternative to the 'Authorization' header.
+	HeaderAPIKey = "X-API-Key"
+
+	// bootstrapName is the name of the principal authenticated by the bootstrap key.
+	bootstrapName = "bootstrap"
+)
+
+var (
+	// ErrMissingCredentials is returned by Authenticate when the client gave no API key or JWT.
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned by Authenticate when the client's API key or JWT is not valid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// KeyStore stores the API keys.
+type KeyStore interface {
+	// GetAPIKeyByHash returns the API key, not revoked, with the given hash, or repository.ErrNotFound.
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+}
+
+// Options configures an Authenticator.
+type Options struct {
+	// CacheTTL is the time an authenticated API key is cached for, so a revoked key may still be accepted for that
+	// long. Defaults to a minute.
+	CacheTTL time.Duration
+
+	// BootstrapKey is an admin API key that is accepted without being stored, e.g. to create the first keys or for
+	// local development. Empty disables it.
+	BootstrapKey string
+
+	// Tokens verifies the JWTs given instead of an API key. Nil only accepts API keys.
+	Tokens *Verifier
+}
+
+type cachedKey struct {
+	principal Principal
+	expiresAt time.Time
+}
+
+// Authenticator authenticates the requests by their API key, given by the 'Authorization: Bearer <key>' header or
+// by the 'X-API-Key' header, or by their JWT, given by the 'Authorization: Bearer <token>' header, and authorizes
+// them by the scopes of their route.
+type Authenticator struct {
+	store         KeyStore
+	tokens        *Verifier
+	ttl           time.Duration
+	bootstrapHash string
+
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+// NewAuthenticator creates a new Authenticator that looks the API keys up in the given store.
+func NewAuthenticator(store KeyStore, opts Options) *Authenticator {
+	a := &Authenticator{
+		store:  store,
+		tokens: opts.Tokens,
+		ttl:    opts.CacheTTL,
+		keys:   make(map[string]cachedKey),
+	}
+	if a.ttl <= 0 {
+		a.ttl = defaultCacheTTL
+	}
+	if opts.BootstrapKey != "" {
+		a.bootstrapHash = HashKey(opts.BootstrapKey)
+	}
+	return a
+}
+
+// Require returns a handler that serves the authenticated requests allowed to use the scope with next, with their
+// principal in their context. It answers with 401 Unauthorized to the requests without a valid API key or JWT and with
+// 403 Forbidden to the ones whose credentials are not allowed to use the scope. An empty scope only requires the
+// requests to be authenticated, e.g. for '/graphql', whose fields are authorized by their resolvers.
+//
+// The requests whose principal is already in their context, e.g. identified by the rate limiter, are not
+// authenticated again.
+func (a *Authenticator) Require(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		p, ok := FromContext(ctx)
+		var err error
+		if !ok {
+			p, err = a.Authenticate(ctx, Credentials(r))
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case errors.Is(err, ErrMissingCredentials), errors.Is(err, ErrInvalidCredentials):
+				logging.FromContext(ctx).Warn("unauthenticated request", "error", err)
+				response.WriteUnauthorized(w, scheme)
+			case errors.Is(err, repository.ErrUnavailable):
+				logging.FromContext(ctx).Error("error authenticating the request", "error", err)
+				response.WriteServiceUnavailable(w)
+			default:
+				logging.FromContext(ctx).Error("error authenticating the request", "error", err)
+				response.WriteInternalServerError(w)
+			}
+			return
+		}
+
+		logger := logging.FromContext(ctx).With("client", p.Name)
+		if scope != "" && !p.Can(scope) {
+			logger.Warn("forbidden request", "role", string(p.Role), "scope", string(scope))
+			w.Header().Set("Content-Type", "application/json")
+			response.WriteForbidden(w)
+			return
+		}
+
+		ctx = WithPrincipal(logging.WithLogger(ctx, logger), p)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// OwnPartner returns a handler that serves with next the requests whose principal is allowed to access the partner
+// of the route's 'id' variable, see Principal.CanAccessPartner, and answers with 403 Forbidden to the others. A
+// partner can't use the routes without a partner, e.g. the listing of all of them. It must be wrapped by Require.
+func OwnPartner(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		p, ok := FromContext(ctx)
+		if ok && p.Role == RolePartner {
+			id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+			if err != nil || !p.CanAccessPartner(uint(id)) {
+				logging.FromContext(ctx).Warn("forbidden request", "role", string(p.Role), "partner_id", p.PartnerID)
+				w.Header().Set("Content-Type", "application/json")
+				response.WriteForbidden(w)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// Authenticate returns the principal of the API key or JWT, e.g. the one given by Credentials. It returns
+// ErrMissingCredentials for an empty key and an error wrapping ErrInvalidCredentials for a key that is not valid.
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (Principal, error) {
+	if key == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+
+	hash := HashKey(key)
+	if a.bootstrapHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.bootstrapHash)) == 1 {
+		return bootstrapPrincipal(), nil
+	}
+
+	// the API keys have no dots, unlike the JWTs' three base64url encoded parts
+	if a.tokens != nil && strings.Count(key, ".") == 2 {
+		return a.tokens.Verify(ctx, key)
+	}
+
+	now := time.Now()
+	if p, ok := a.cached(hash, now); ok {
+		return p, nil
+	}
+
+	k, err := a.store.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return Principal{}, fmt.Errorf("error trying to retrieve the api key: %w", err)
+	}
+
+	p := NewPrincipal(k)
+	a.cache(hash, p, now)
+	return p, nil
+}
+
+// Identify returns the principal of the API key or JWT when it is known without looking the key up in the store: the
+// bootstrap key, an API key authenticated within the cache's TTL or a valid JWT. It is meant for the checks that run
+// before the requests are authenticated, e.g. the rate limits, so the made up keys don't each query the store.
+func (a *Authenticator) Identify(ctx context.Context, key string) (Principal, bool) {
+	if key == "" {
+		return Principal{}, false
+	}
+
+	hash := HashKey(key)
+	if a.bootstrapHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.bootstrapHash)) == 1 {
+		return bootstrapPrincipal(), true
+	}
+
+	if a.tokens != nil && strings.Count(key, ".") == 2 {
+		p, err := a.tokens.Verify(ctx, key)
+		return p, err == nil
+	}
+
+	return a.cached(hash, time.Now())
+}
+
+// bootstrapPrincipal returns the principal of the bootstrap key.
+func bootstrapPrincipal() Principal {
+	return Principal{ID: bootstrapName, Name: bootstrapName, Role: RoleAdmin, Scopes: roleScopes[RoleAdmin]}
+}
+
+// Credentials returns the API key or the JWT of the request, or an empty string if it has none.
+func Credentials(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		prefix := scheme + " "
+		if len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+			return strings.TrimSpace(h[len(prefix):])
+		}
+		return ""
+	}
+	return r.Header.Get(HeaderAPIKey)
+}
+
+func (a *Authenticator) cached(hash string, now time.Time) (Principal, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k, ok := a.keys[hash]
+	if !ok {
+		return Principal{}, false
+	}
+	if !now.Before(k.expiresAt) {
+		delete(a.keys, hash)
+		return Principal{}, false
+	}
+	return k.principal, true
+}
+
+// cache caches the principal of a valid API key. Only the valid keys are cached, so the cache can't be filled with
+// made up ones.
+func (a *Authenticator) cache(hash string, p Principal, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys[hash] = cachedKey{principal: p, expiresAt: now.Add(a.ttl)}
+}
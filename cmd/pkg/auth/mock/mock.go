@@ -0,0 +1,3 @@
+//go:generate mockgen -package=mock -source=../middleware.go -destination=./middleware.go
+
+package mock
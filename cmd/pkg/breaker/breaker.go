@@ -0,0 +1,142 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is the error of the calls that the breaker does not let through.
+var ErrOpen = errors.New("circuit breaker is open")
+
+const (
+	defaultThreshold = 5
+	defaultCooldown  = 10 * time.Second
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open fails every call fast, until the cooldown passes.
+	Open
+	// HalfOpen lets a single trial call through, which closes the breaker if it succeeds or opens it again if not.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Options configures a Breaker.
+type Options struct {
+	// Threshold is the number of consecutive failures that opens the breaker. Defaults to 5.
+	Threshold int
+	// Cooldown is the time the breaker stays open before letting a trial call through. Defaults to 10 seconds.
+	Cooldown time.Duration
+}
+
+// Breaker is a circuit breaker: after a number of consecutive failures it stops letting calls through for a while,
+// so callers fail fast instead of waiting on a dependency that is down. It is safe for concurrent use.
+//
+// Every call allowed by Allow must be followed by a call to Done with its outcome.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// New creates a new, closed, Breaker.
+func New(opts Options) *Breaker {
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultCooldown
+	}
+
+	return &Breaker{threshold: opts.Threshold, cooldown: opts.Cooldown}
+}
+
+// Allow reports whether a call may go through.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+
+	return true
+}
+
+// Done records the outcome of a call allowed by Allow.
+func (b *Breaker) Done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.trial = false
+		if failed {
+			b.open()
+		} else {
+			b.state = Closed
+			b.failures = 0
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == Closed && b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = time.Now()
+	b.failures = 0
+}
@@ -0,0 +1,85 @@
+package breaker_test
+
+import (
+	"testing"
+	"time"
+
+	"match/cmd/pkg/breaker"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b := breaker.New(breaker.Options{Threshold: 3, Cooldown: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("%d: allow mismatch: want true got false", i)
+		}
+		b.Done(true)
+	}
+
+	if b.State() != breaker.Open {
+		t.Errorf("state mismatch: want %v got %v", breaker.Open, b.State())
+	}
+
+	if b.Allow() {
+		t.Errorf("allow mismatch: want false got true")
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	b := breaker.New(breaker.Options{Threshold: 2, Cooldown: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		b.Allow()
+		b.Done(true)
+		b.Allow()
+		b.Done(false)
+	}
+
+	if b.State() != breaker.Closed {
+		t.Errorf("state mismatch: want %v got %v", breaker.Closed, b.State())
+	}
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b := breaker.New(breaker.Options{Threshold: 1, Cooldown: 10 * time.Millisecond})
+
+	b.Allow()
+	b.Done(true)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if b.State() != breaker.HalfOpen {
+		t.Errorf("state mismatch: want %v got %v", breaker.HalfOpen, b.State())
+	}
+
+	if !b.Allow() {
+		t.Fatalf("allow mismatch: want true got false")
+	}
+
+	// only a single trial call goes through
+	if b.Allow() {
+		t.Errorf("allow mismatch: want false got true")
+	}
+
+	b.Done(true)
+
+	if b.State() != breaker.Open {
+		t.Errorf("state mismatch: want %v got %v", breaker.Open, b.State())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatalf("allow mismatch: want true got false")
+	}
+	b.Done(false)
+
+	if b.State() != breaker.Closed {
+		t.Errorf("state mismatch: want %v got %v", breaker.Closed, b.State())
+	}
+
+	if !b.Allow() {
+		t.Errorf("allow mismatch: want true got false")
+	}
+}
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/models"
+)
+
+const (
+	defaultSize = 10000
+	defaultTTL  = time.Minute
+)
+
+// Options configures a Database.
+type Options struct {
+	// Size is the maximum number of cached results of each method. Defaults to 10000.
+	Size int
+
+	// TTL is the time a cached result is used for. Defaults to a minute.
+	TTL time.Duration
+
+	// GridSize is the size, in degrees, of the grid cells of the cached matches. Match requests whose coordinates
+	// fall in the same cell, and that have the same materials, share the cached result, which is the result for the
+	// coordinates of the first of them. Zero, the default, only shares it between the same coordinates.
+	GridSize float64
+}
+
+// Stats represents the usage of a Database's cache.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the ratio of cache hits, between 0 and 1.
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Database is a partners.Database that caches, in memory, the matches and the partners returned by the wrapped
+// database. The remaining methods are not cached.
+//
+// The cached results are shared between callers, so they must not be modified.
+type Database struct {
+	partners.Database
+
+	gridSize float64
+
+	mu       sync.Mutex
+	matches  *lru[string, []models.Partner]
+	partners *lru[uint, models.Partner]
+
+	// generation is incremented on every invalidation, so results fetched before it are not cached after it
+	generation uint64
+
+	hits   uint64
+	misses uint64
+}
+
+// NewDatabase creates a new Database that caches the results of the given database.
+func NewDatabase(db partners.Database, opts Options) *Database {
+	if opts.Size <= 0 {
+		opts.Size = defaultSize
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = defaultTTL
+	}
+
+	return &Database{
+		Database: db,
+		gridSize: opts.GridSize,
+		matches:  newLRU[string, []models.Partner](opts.Size, opts.TTL),
+		partners: newLRU[uint, models.Partner](opts.Size, opts.TTL),
+	}
+}
+
+// GetMatches returns the cached matches for the materials and coordinates, or the ones returned by the wrapped
+// database if they are not cached.
+func (db *Database) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
+	// the matches are fetched for the exact coordinates, and the cell only keys them
+	key := matchesKey(materials, db.quantize(lat), db.quantize(long))
+
+	db.mu.Lock()
+	ps, ok := db.matches.get(key, time.Now())
+	generation := db.generation
+	db.mu.Unlock()
+
+	if ok {
+		atomic.AddUint64(&db.hits, 1)
+		return ps, nil
+	}
+	atomic.AddUint64(&db.misses, 1)
+
+	ps, err := db.Database.GetMatches(ctx, materials, lat, long)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	if generation == db.generation {
+		db.matches.add(key, ps, time.Now())
+	}
+	db.mu.Unlock()
+
+	return ps, nil
+}
+
+// GetPartnerById returns the cached partner, or the one returned by the wrapped database if it is not cached.
+func (db *Database) GetPartnerById(ctx context.Context, id uint) (models.Partner, error) {
+	db.mu.Lock()
+	p, ok := db.partners.get(id, time.Now())
+	generation := db.generation
+	db.mu.Unlock()
+
+	if ok {
+		atomic.AddUint64(&db.hits, 1)
+		return p, nil
+	}
+	atomic.AddUint64(&db.misses, 1)
+
+	p, err := db.Database.GetPartnerById(ctx, id)
+	if err != nil {
+		return models.Partner{}, err
+	}
+
+	db.mu.Lock()
+	if generation == db.generation {
+		db.partners.add(id, p, time.Now())
+	}
+	db.mu.Unlock()
+
+	return p, nil
+}
+
+// UpdatePartner updates the partner with the wrapped database and invalidates it, so it is not served outdated until
+// the database notifies the change.
+func (db *Database) UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error) {
+	p, err := db.Database.UpdatePartner(ctx, id, u)
+	if err != nil {
+		return models.Partner{}, err
+	}
+
+	db.InvalidatePartner(id)
+	return p, nil
+}
+
+// InvalidatePartner removes the partner from the cache, along with all cached matches, since a change to the
+// partner may change any of them.
+func (db *Database) InvalidatePartner(id uint) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.partners.remove(id)
+	db.matches.purge()
+	db.generation++
+}
+
+// Purge removes all entries from the cache.
+func (db *Database) Purge() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.partners.purge()
+	db.matches.purge()
+	db.generation++
+}
+
+// Len returns the number of cached results.
+func (db *Database) Len() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.matches.len() + db.partners.len()
+}
+
+// Stats returns the number of cache hits and misses since the Database was created.
+func (db *Database) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&db.hits),
+		Misses: atomic.LoadUint64(&db.misses),
+	}
+}
+
+// quantize rounds the coordinate to the center of its grid cell.
+func (db *Database) quantize(c float32) float32 {
+	if db.gridSize <= 0 {
+		return c
+	}
+	return float32((math.Floor(float64(c)/db.gridSize) + 0.5) * db.gridSize)
+}
+
+// matchesKey returns the cache key of a match request. The materials are sorted, since their order doesn't change
+// the matches, but duplicates are kept, since they do.
+func matchesKey(materials []uint, lat, long float32) string {
+	ms := make([]uint, len(materials))
+	copy(ms, materials)
+	sort.Slice(ms, func(i, j int) bool { return ms[i] < ms[j] })
+
+	var sb strings.Builder
+	for _, m := range ms {
+		sb.WriteString(strconv.FormatUint(uint64(m), 10))
+		sb.WriteByte(',')
+	}
+	sb.WriteString(fmt.Sprintf("%v:%v", lat, long))
+
+	return sb.String()
+}
@@ -0,0 +1,292 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/cache"
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testPartner = models.Partner{
+	ID: 1,
+	Address: models.Address{
+		Lat:  1.1,
+		Long: 1.2,
+	},
+	Radius: 100,
+	Rating: 4,
+}
+
+func TestGetMatches_CachesResult(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{2, 1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{testPartner}, nil).
+		Times(1)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	for _, materials := range [][]uint{{2, 1}, {2, 1}, {1, 2}} {
+		ps, err := c.GetMatches(context.Background(), materials, 1.1, 1.2)
+
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		if diff := cmp.Diff([]models.Partner{testPartner}, ps); diff != "" {
+			t.Errorf("partners mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	expectedStats := cache.Stats{Hits: 2, Misses: 1}
+	if c.Stats() != expectedStats {
+		t.Errorf("stats mismatch: want %+v got %+v", expectedStats, c.Stats())
+	}
+}
+
+func TestGetMatches_DuplicateMaterialsAreNotShared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{testPartner}, nil)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{}, nil)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+	_, _ = c.GetMatches(context.Background(), []uint{1, 1}, 1.1, 1.2)
+}
+
+func TestGetMatches_ErrorsAreNotCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	errQuery := errors.New("some error")
+
+	gomock.InOrder(
+		db.EXPECT().
+			GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+			Return(nil, errQuery),
+		db.EXPECT().
+			GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+			Return([]models.Partner{testPartner}, nil),
+	)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	_, err := c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+	if !errors.Is(err, errQuery) {
+		t.Errorf("error mismatch: want '%s' got '%s'", errQuery, err)
+	}
+
+	ps, err := c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff([]models.Partner{testPartner}, ps); diff != "" {
+		t.Errorf("partners mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetMatches_GridSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	// both requests fall in the cell whose center is (1.15, 1.25), and share the matches of the first one
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.11), float32(1.21)).
+		Return([]models.Partner{testPartner}, nil).
+		Times(1)
+
+	// this one falls in the next cell
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.21), float32(1.29)).
+		Return([]models.Partner{}, nil).
+		Times(1)
+
+	c := cache.NewDatabase(db, cache.Options{GridSize: 0.1})
+
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.11, 1.21)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.19, 1.29)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.21, 1.29)
+
+	expectedStats := cache.Stats{Hits: 1, Misses: 2}
+	if c.Stats() != expectedStats {
+		t.Errorf("stats mismatch: want %+v got %+v", expectedStats, c.Stats())
+	}
+}
+
+func TestGetMatches_Expires(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{testPartner}, nil).
+		Times(2)
+
+	c := cache.NewDatabase(db, cache.Options{TTL: 10 * time.Millisecond})
+
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+	time.Sleep(20 * time.Millisecond)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+}
+
+func TestGetMatches_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, gomock.Any(), gomock.Any()).
+		Return([]models.Partner{testPartner}, nil).
+		Times(4)
+
+	c := cache.NewDatabase(db, cache.Options{Size: 2})
+
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1, 1) // miss
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 2, 2) // miss
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1, 1) // hit, (2, 2) is now the least recently used
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 3, 3) // miss, evicts (2, 2)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1, 1) // hit
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 2, 2) // miss
+
+	expectedStats := cache.Stats{Hits: 2, Misses: 4}
+	if c.Stats() != expectedStats {
+		t.Errorf("stats mismatch: want %+v got %+v", expectedStats, c.Stats())
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("length mismatch: want 2 got %d", c.Len())
+	}
+}
+
+func TestGetPartnerById_CachesResult(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(testPartner, nil).
+		Times(1)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	for i := 0; i < 3; i++ {
+		p, err := c.GetPartnerById(context.Background(), 1)
+
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		if diff := cmp.Diff(testPartner, p); diff != "" {
+			t.Errorf("partner mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	if c.Stats().HitRate() != 2.0/3.0 {
+		t.Errorf("hit rate mismatch: want %v got %v", 2.0/3.0, c.Stats().HitRate())
+	}
+}
+
+func TestInvalidatePartner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(testPartner, nil).
+		Times(2)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(2)).
+		Return(models.Partner{ID: 2}, nil).
+		Times(1)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{}, nil).
+		Times(2)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	_, _ = c.GetPartnerById(context.Background(), 2)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+
+	c.InvalidatePartner(1)
+
+	// partner 1 and the matches are fetched again, partner 2 is still cached
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	_, _ = c.GetPartnerById(context.Background(), 2)
+	_, _ = c.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+}
+
+func TestInvalidatePartner_DuringFetch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		DoAndReturn(func(context.Context, uint) (models.Partner, error) {
+			// the partner changes while its previous version is being fetched
+			c.InvalidatePartner(1)
+			return testPartner, nil
+		}).
+		Times(2)
+
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	_, _ = c.GetPartnerById(context.Background(), 1)
+}
+
+func TestPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(testPartner, nil).
+		Times(2)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	c.Purge()
+
+	if c.Len() != 0 {
+		t.Errorf("length mismatch: want 0 got %d", c.Len())
+	}
+
+	_, _ = c.GetPartnerById(context.Background(), 1)
+}
+
+func TestStreamPartners_NotCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(2)
+
+	c := cache.NewDatabase(db, cache.Options{})
+
+	_ = c.StreamPartners(context.Background(), func(models.Partner) error { return nil })
+	_ = c.StreamPartners(context.Background(), func(models.Partner) error { return nil })
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a fixed size, least recently used, cache whose entries expire after a time to live.
+// It is not safe for concurrent use.
+type lru[K comparable, V any] struct {
+	size  int
+	ttl   time.Duration
+	items map[K]*list.Element
+	order *list.List
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration) *lru[K, V] {
+	return &lru[K, V]{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns the value of the key, if it is cached and not expired, and marks it as the most recently used.
+func (c *lru[K, V]) get(key K, now time.Time) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if !now.Before(e.expiresAt) {
+		c.removeElement(el)
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// add caches the value of the key, evicting the least recently used entry if the cache is full.
+func (c *lru[K, V]) add(key K, value V, now time.Time) {
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = now.Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		c.removeElement(c.order.Back())
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: now.Add(c.ttl)})
+}
+
+// remove removes the key from the cache.
+func (c *lru[K, V]) remove(key K) {
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// purge removes all entries from the cache.
+func (c *lru[K, V]) purge() {
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+func (c *lru[K, V]) len() int {
+	return c.order.Len()
+}
+
+func (c *lru[K, V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
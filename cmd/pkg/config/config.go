@@ -0,0 +1,446 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"match/cmd/pkg/apiversion"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of a secret when it is printed.
+const redacted = "[REDACTED]"
+
+// Secret is a string that is never printed, e.g. a password. Use Value to get the actual string.
+type Secret string
+
+// Value returns the secret's actual string.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// MarshalYAML redacts the secret.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// MarshalJSON redacts the secret.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Config is the configuration of the application.
+//
+// Every setting has a default, which is overridden by the configuration file, then by the environment variable in
+// its 'env' tag and then by the command-line flag in its 'flag' tag.
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	DB          DB          `yaml:"db"`
+	Match       Match       `yaml:"match"`
+	Cache       Cache       `yaml:"cache"`
+	Index       Index       `yaml:"index"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Log         Log         `yaml:"log"`
+	Auth        Auth        `yaml:"auth"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	API         API         `yaml:"api"`
+	GRPC        GRPC        `yaml:"grpc"`
+}
+
+// HTTP configures the HTTP server.
+type HTTP struct {
+	Port         int           `yaml:"port" env:"APP_PORT" flag:"port" usage:"the port the server listens on"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"http-read-timeout" usage:"the maximum time to read a request"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"http-write-timeout" usage:"the maximum time to write a response"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"http-idle-timeout" usage:"the maximum time to wait for the next request of a connection"`
+
+	// ShutdownTimeout is the time given to the in-flight requests, and then to the rest of the app, to finish on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" flag:"http-shutdown-timeout" usage:"the maximum time to finish the in-flight requests and stop the app on shutdown"`
+	// ShutdownDelay is the time the app reports it is not ready, so the load balancers stop sending it requests, before
+	// it stops accepting them on shutdown.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY" flag:"http-shutdown-delay" usage:"the time the app is not ready before it stops accepting requests on shutdown"`
+}
+
+// DB configures the database.
+//
+// The password has no flag, since the flags of a process can be seen by every user of the machine.
+type DB struct {
+	Host     string `yaml:"host" env:"PSQL_HOST" flag:"db-host" usage:"the database host"`
+	Port     int    `yaml:"port" env:"PSQL_PORT" flag:"db-port" usage:"the database port"`
+	User     string `yaml:"user" env:"PSQL_USER" flag:"db-user" usage:"the database user"`
+	Password Secret `yaml:"password" env:"PSQL_PASSWORD"`
+	Name     string `yaml:"name" env:"PSQL_DB_NAME" flag:"db-name" usage:"the database name"`
+
+	// ReplicaHosts are the read replicas, as 'host:port', with the same user, password and name as the primary.
+	ReplicaHosts         []string      `yaml:"replica_hosts" env:"PSQL_REPLICA_HOSTS" flag:"db-replica-hosts" usage:"the read replicas, as host:port separated by commas"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"PSQL_REPLICA_CHECK_INTERVAL" flag:"db-replica-check-interval" usage:"the time between health checks of the read replicas"`
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag" env:"PSQL_REPLICA_MAX_LAG" flag:"db-replica-max-lag" usage:"the time the partners are read from the primary after they change, or 0 to keep reading the replicas"`
+	ReadYourWrites       bool          `yaml:"read_your_writes" env:"PSQL_READ_YOUR_WRITES" flag:"db-read-your-writes" usage:"read from the primary after a write in the same request"`
+
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"PSQL_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"the maximum time to wait for the database at startup"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" env:"PSQL_QUERY_TIMEOUT" flag:"db-query-timeout" usage:"the maximum time of a query"`
+	SlowQuery       time.Duration `yaml:"slow_query" env:"PSQL_SLOW_QUERY" flag:"db-slow-query" usage:"the time above which a query is logged as slow, or 0 to never"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"PSQL_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"the maximum number of open connections"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"PSQL_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"the maximum number of idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"PSQL_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"the maximum time a connection is reused"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"PSQL_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" usage:"the maximum time a connection stays idle"`
+
+	ReadRetries      int           `yaml:"read_retries" env:"PSQL_READ_RETRIES" flag:"db-read-retries" usage:"the number of times a read is retried on transient errors"`
+	ReadRetryBackoff time.Duration `yaml:"read_retry_backoff" env:"PSQL_READ_RETRY_BACKOFF" flag:"db-read-retry-backoff" usage:"the time waited before the first retry of a read"`
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"PSQL_BREAKER_THRESHOLD" flag:"db-breaker-threshold" usage:"the number of consecutive failures that opens the circuit breaker"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"PSQL_BREAKER_COOLDOWN" flag:"db-breaker-cooldown" usage:"the time the circuit breaker stays open"`
+}
+
+// DSN returns the data source name of the database at the given host and port.
+func (db DB) DSN(host string, port int) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		host, port, db.User, db.Password.Value(), db.Name)
+}
+
+// Match configures how the matches of a customer's request are ranked.
+type Match struct {
+	Limit          int     `yaml:"limit" env:"MATCH_LIMIT" flag:"match-limit" usage:"the maximum number of matches"`
+	RatingWeight   float64 `yaml:"rating_weight" env:"MATCH_RATING_WEIGHT" flag:"match-rating-weight" usage:"the weight of the rating in the score of a match"`
+	DistanceWeight float64 `yaml:"distance_weight" env:"MATCH_DISTANCE_WEIGHT" flag:"match-distance-weight" usage:"the weight of the distance, in kilometers, in the score of a match"`
+}
+
+// Ranking returns the ranking of the matches.
+func (m Match) Ranking() models.MatchRanking {
+	return models.MatchRanking{
+		RatingWeight:   m.RatingWeight,
+		DistanceWeight: m.DistanceWeight,
+		Limit:          m.Limit,
+	}
+}
+
+// Cache configures the cache of the partners.
+type Cache struct {
+	Size     int           `yaml:"size" env:"CACHE_SIZE" flag:"cache-size" usage:"the maximum number of cached results of each query"`
+	TTL      time.Duration `yaml:"ttl" env:"CACHE_TTL" flag:"cache-ttl" usage:"the time a cached result is used for"`
+	GridSize float64       `yaml:"grid_size" env:"CACHE_GRID_SIZE" flag:"cache-grid-size" usage:"the size, in degrees, of the cells whose match requests share their cached matches, or 0 for the exact coordinates"`
+}
+
+// Index configures the in-memory index of the partners.
+type Index struct {
+	Enabled         bool          `yaml:"enabled" env:"PARTNERS_INDEX" flag:"index" usage:"load the partners into an in-memory index"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"PARTNERS_INDEX_REFRESH_INTERVAL" flag:"index-refresh-interval" usage:"the time between reloads of the index"`
+}
+
+// Tracing configures the tracing of the requests and of the database queries.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"the exporter of the spans: none, stdout or otlp"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" flag:"tracing-endpoint" usage:"the host:port of the OpenTelemetry collector, for the otlp exporter"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE" flag:"tracing-insecure" usage:"send the spans to the collector without TLS"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"the ratio, between 0 and 1, of the traces started by the app that are sampled"`
+}
+
+// Log configures the logs.
+type Log struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"the minimum level of the logs: debug, info, warn or error"`
+}
+
+// SlogLevel returns the minimum level of the logs, or info if the level is invalid.
+func (l Log) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// Auth configures the authentication of the requests.
+type Auth struct {
+	KeyCacheTTL time.Duration `yaml:"key_cache_ttl" env:"AUTH_KEY_CACHE_TTL" flag:"auth-key-cache-ttl" usage:"the time an authenticated API key is cached for"`
+	// BootstrapKey is an admin API key that is not stored, e.g. to create the first keys. Like the database password
+	// it has no flag.
+	BootstrapKey Secret `yaml:"bootstrap_key" env:"AUTH_BOOTSTRAP_KEY"`
+
+	// JWKSFile or JWKSURL, but not both, enable the JWTs, verified by the keys of the JWKS.
+	JWKSFile        string        `yaml:"jwks_file" env:"AUTH_JWKS_FILE" flag:"auth-jwks-file" usage:"the file with the JWKS that verifies the JWTs"`
+	JWKSURL         string        `yaml:"jwks_url" env:"AUTH_JWKS_URL" flag:"auth-jwks-url" usage:"the URL of the JWKS that verifies the JWTs"`
+	JWKSRefresh     time.Duration `yaml:"jwks_refresh" env:"AUTH_JWKS_REFRESH" flag:"auth-jwks-refresh" usage:"the interval the JWKS is loaded again at"`
+	JWTIssuer       string        `yaml:"jwt_issuer" env:"AUTH_JWT_ISSUER" flag:"auth-jwt-issuer" usage:"the required issuer of the JWTs, empty accepts any"`
+	JWTAudience     string        `yaml:"jwt_audience" env:"AUTH_JWT_AUDIENCE" flag:"auth-jwt-audience" usage:"the required audience of the JWTs, empty accepts any"`
+	JWTRoleClaim    string        `yaml:"jwt_role_claim" env:"AUTH_JWT_ROLE_CLAIM" flag:"auth-jwt-role-claim" usage:"the claim of the JWTs with the role"`
+	JWTPartnerClaim string        `yaml:"jwt_partner_claim" env:"AUTH_JWT_PARTNER_CLAIM" flag:"auth-jwt-partner-claim" usage:"the claim of the partners' JWTs with their partner id"`
+}
+
+// JWT reports whether the JWTs are enabled.
+func (a Auth) JWT() bool {
+	return a.JWKSFile != "" || a.JWKSURL != ""
+}
+
+// RateLimit configures the rate limits of the routes, per client, i.e. per API key or JWT, or per IP for the
+// requests without credentials. A limit is 'requests/period', e.g. '60/1m', and a route is named after its operation,
+// e.g. 'match'.
+type RateLimit struct {
+	Enabled           bool     `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit" usage:"limit the rate of the requests of each client"`
+	Default           string   `yaml:"default" env:"RATE_LIMIT_DEFAULT" flag:"rate-limit-default" usage:"the limit of the routes without their own, as requests/period, or empty for none"`
+	Routes            []string `yaml:"routes" env:"RATE_LIMIT_ROUTES" flag:"rate-limit-routes" usage:"the limits of the routes, as route=requests/period separated by commas"`
+	Store             string   `yaml:"store" env:"RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"where the requests are counted: memory, per instance, or postgres, shared by all instances"`
+	TrustForwardedFor bool     `yaml:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR" flag:"rate-limit-trust-forwarded-for" usage:"limit the requests without credentials by the first IP of their X-Forwarded-For header"`
+}
+
+// Idempotency configures the idempotency keys of the POST routes.
+type Idempotency struct {
+	TTL         time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"the time the response of a request with an idempotency key is replayed to its retries for"`
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT" flag:"idempotency-lock-timeout" usage:"the time after which a request with an idempotency key still in progress is considered abandoned"`
+}
+
+// API configures the versions of the API, which are served under their prefix, e.g. '/v1', while the unversioned
+// routes are the aliases of the v1 ones.
+type API struct {
+	Deprecated        []string `yaml:"deprecated" env:"API_DEPRECATED" flag:"api-deprecated" usage:"the deprecated versions, as version=date or version=date/sunset, e.g. unversioned=2026-01-01/2026-07-01, separated by commas"`
+	ValidateRequests  bool     `yaml:"validate_requests" env:"API_VALIDATE_REQUESTS" flag:"api-validate-requests" usage:"answer the requests that don't match openapi.yml with 400 Bad Request"`
+	ValidateResponses bool     `yaml:"validate_responses" env:"API_VALIDATE_RESPONSES" flag:"api-validate-responses" usage:"log the responses that don't match openapi.yml, e.g. in development, at the cost of buffering them"`
+}
+
+// GRPC configures the gRPC server, which serves the matches and the partners alongside the HTTP server.
+type GRPC struct {
+	Enabled bool `yaml:"enabled" env:"GRPC_ENABLED" flag:"grpc" usage:"serve the gRPC API"`
+	Port    int  `yaml:"port" env:"GRPC_PORT" flag:"grpc-port" usage:"the port the gRPC server listens on"`
+}
+
+// Deprecations returns the deprecations of the versions, by version.
+func (a API) Deprecations() (map[string]apiversion.Deprecation, error) {
+	deprecations := make(map[string]apiversion.Deprecation, len(a.Deprecated))
+	for _, vd := range a.Deprecated {
+		version, deprecation, ok := strings.Cut(vd, "=")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid deprecated version '%s', must be version=date or version=date/sunset", vd)
+		}
+
+		d, err := apiversion.ParseDeprecation(deprecation)
+		if err != nil {
+			return nil, err
+		}
+		deprecations[version] = d
+	}
+
+	return deprecations, nil
+}
+
+// Limits returns the default limit and the limits of the routes, by route.
+func (r RateLimit) Limits() (ratelimit.Limit, map[string]ratelimit.Limit, error) {
+	def, err := ratelimit.ParseLimit(r.Default)
+	if err != nil {
+		return ratelimit.Limit{}, nil, err
+	}
+
+	routes := make(map[string]ratelimit.Limit, len(r.Routes))
+	for _, rl := range r.Routes {
+		route, limit, ok := strings.Cut(rl, "=")
+		if !ok || route == "" {
+			return ratelimit.Limit{}, nil, fmt.Errorf("invalid route rate limit '%s', must be route=requests/period", rl)
+		}
+
+		routes[route], err = ratelimit.ParseLimit(limit)
+		if err != nil {
+			return ratelimit.Limit{}, nil, err
+		}
+	}
+
+	return def, routes, nil
+}
+
+// Default returns the default configuration. It is not valid on its own, since the database has no defaults for its
+// host, user, password and name.
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Port:            8080,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    90 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		DB: DB{
+			Port:                 5432,
+			ReplicaCheckInterval: 10 * time.Second,
+			ReplicaMaxLag:        5 * time.Second,
+			ReadYourWrites:       true,
+			ConnectTimeout:       2 * time.Minute,
+			QueryTimeout:         5 * time.Second,
+			SlowQuery:            200 * time.Millisecond,
+			MaxOpenConns:         20,
+			MaxIdleConns:         10,
+			ConnMaxLifetime:      30 * time.Minute,
+			ConnMaxIdleTime:      5 * time.Minute,
+			ReadRetries:          2,
+			ReadRetryBackoff:     100 * time.Millisecond,
+			BreakerThreshold:     5,
+			BreakerCooldown:      10 * time.Second,
+		},
+		Match: Match{
+			Limit: models.DefaultMatchLimit,
+		},
+		Cache: Cache{
+			Size: 10000,
+			TTL:  time.Minute,
+		},
+		Index: Index{
+			RefreshInterval: 5 * time.Minute,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4317",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Auth: Auth{
+			KeyCacheTTL:     time.Minute,
+			JWKSRefresh:     5 * time.Minute,
+			JWTRoleClaim:    "role",
+			JWTPartnerClaim: "partner_id",
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Default: "600/1m",
+			Routes:  []string{"match=60/1m", "match_batch=6/1m"},
+			Store:   "memory",
+		},
+		Idempotency: Idempotency{
+			TTL:         24 * time.Hour,
+			LockTimeout: 2 * time.Minute,
+		},
+		API: API{
+			ValidateRequests: true,
+		},
+		GRPC: GRPC{
+			Enabled: true,
+			Port:    9090,
+		},
+	}
+}
+
+// Errors are the errors of an invalid configuration, all reported at once.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(msgs, "; "))
+}
+
+// Validate checks every setting, returning Errors with all the invalid ones, or nil if the configuration is valid.
+func (c Config) Validate() error {
+	var errs Errors
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.HTTP.Port > 0 && c.HTTP.Port <= 65535, "http.port must be between 1 and 65535, got %d", c.HTTP.Port)
+	check(c.HTTP.ReadTimeout > 0, "http.read_timeout must be positive, got %v", c.HTTP.ReadTimeout)
+	check(c.HTTP.WriteTimeout > 0, "http.write_timeout must be positive, got %v", c.HTTP.WriteTimeout)
+	check(c.HTTP.IdleTimeout > 0, "http.idle_timeout must be positive, got %v", c.HTTP.IdleTimeout)
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive, got %v", c.HTTP.ShutdownTimeout)
+	check(c.HTTP.ShutdownDelay >= 0, "http.shutdown_delay must not be negative, got %v", c.HTTP.ShutdownDelay)
+
+	check(c.DB.Host != "", "db.host is required")
+	check(c.DB.Port > 0 && c.DB.Port <= 65535, "db.port must be between 1 and 65535, got %d", c.DB.Port)
+	check(c.DB.User != "", "db.user is required")
+	check(c.DB.Password != "", "db.password is required")
+	check(c.DB.Name != "", "db.name is required")
+	for _, h := range c.DB.ReplicaHosts {
+		_, _, err := net.SplitHostPort(h)
+		check(err == nil, "db.replica_hosts must be 'host:port', got '%s'", h)
+	}
+	check(c.DB.ReplicaCheckInterval > 0, "db.replica_check_interval must be positive, got %v", c.DB.ReplicaCheckInterval)
+	check(c.DB.ReplicaMaxLag >= 0, "db.replica_max_lag must not be negative, got %v", c.DB.ReplicaMaxLag)
+	check(c.DB.ConnectTimeout > 0, "db.connect_timeout must be positive, got %v", c.DB.ConnectTimeout)
+	check(c.DB.QueryTimeout >= 0, "db.query_timeout must not be negative, got %v", c.DB.QueryTimeout)
+	check(c.DB.SlowQuery >= 0, "db.slow_query must not be negative, got %v", c.DB.SlowQuery)
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns must not be negative, got %d", c.DB.MaxOpenConns)
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns must not be negative, got %d", c.DB.MaxIdleConns)
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative, got %v", c.DB.ConnMaxLifetime)
+	check(c.DB.ConnMaxIdleTime >= 0, "db.conn_max_idle_time must not be negative, got %v", c.DB.ConnMaxIdleTime)
+	check(c.DB.ReadRetries >= 0, "db.read_retries must not be negative, got %d", c.DB.ReadRetries)
+	check(c.DB.ReadRetryBackoff >= 0, "db.read_retry_backoff must not be negative, got %v", c.DB.ReadRetryBackoff)
+	check(c.DB.BreakerThreshold > 0, "db.breaker_threshold must be positive, got %d", c.DB.BreakerThreshold)
+	check(c.DB.BreakerCooldown > 0, "db.breaker_cooldown must be positive, got %v", c.DB.BreakerCooldown)
+
+	check(c.Match.Limit > 0, "match.limit must be positive, got %d", c.Match.Limit)
+	check(c.Match.RatingWeight >= 0, "match.rating_weight must not be negative, got %v", c.Match.RatingWeight)
+	check(c.Match.DistanceWeight >= 0, "match.distance_weight must not be negative, got %v", c.Match.DistanceWeight)
+
+	check(c.Cache.Size > 0, "cache.size must be positive, got %d", c.Cache.Size)
+	check(c.Cache.TTL > 0, "cache.ttl must be positive, got %v", c.Cache.TTL)
+	check(c.Cache.GridSize >= 0, "cache.grid_size must not be negative, got %v", c.Cache.GridSize)
+
+	check(c.Index.RefreshInterval > 0, "index.refresh_interval must be positive, got %v", c.Index.RefreshInterval)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "tracing.exporter must be none, stdout or otlp, got '%s'", c.Tracing.Exporter)
+	}
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint is required by the otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level must be debug, info, warn or error, got '%s'", c.Log.Level)
+
+	check(c.Auth.KeyCacheTTL > 0, "auth.key_cache_ttl must be positive, got %v", c.Auth.KeyCacheTTL)
+	check(c.Auth.JWKSFile == "" || c.Auth.JWKSURL == "", "auth.jwks_file and auth.jwks_url are mutually exclusive")
+	if c.Auth.JWKSURL != "" {
+		u, err := url.Parse(c.Auth.JWKSURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "auth.jwks_url must be an http or https URL, got '%s'", c.Auth.JWKSURL)
+	}
+	check(c.Auth.JWKSRefresh > 0, "auth.jwks_refresh must be positive, got %v", c.Auth.JWKSRefresh)
+	check(c.Auth.JWTRoleClaim != "", "auth.jwt_role_claim is required")
+	check(c.Auth.JWTPartnerClaim != "", "auth.jwt_partner_claim is required")
+
+	_, _, err := c.RateLimit.Limits()
+	check(err == nil, "rate_limit: %v", err)
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres", "rate_limit.store must be memory or postgres, got '%s'", c.RateLimit.Store)
+
+	check(c.Idempotency.TTL > 0, "idempotency.ttl must be positive, got %v", c.Idempotency.TTL)
+	check(c.Idempotency.LockTimeout > 0, "idempotency.lock_timeout must be positive, got %v", c.Idempotency.LockTimeout)
+	check(c.Idempotency.LockTimeout >= c.HTTP.WriteTimeout, "idempotency.lock_timeout must be at least http.write_timeout, %v, got %v", c.HTTP.WriteTimeout, c.Idempotency.LockTimeout)
+
+	_, err = c.API.Deprecations()
+	check(err == nil, "api: %v", err)
+	check(c.API.ValidateRequests || !c.API.ValidateResponses, "api.validate_responses requires api.validate_requests")
+
+	if c.GRPC.Enabled {
+		check(c.GRPC.Port > 0 && c.GRPC.Port <= 65535, "grpc.port must be between 1 and 65535, got %d", c.GRPC.Port)
+		check(c.GRPC.Port != c.HTTP.Port, "grpc.port must not be http.port, %d", c.HTTP.Port)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// String returns the configuration as YAML, with its secrets redacted.
+func (c Config) String() string {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("error marshalling the configuration: %v", err)
+	}
+	return string(b)
+}
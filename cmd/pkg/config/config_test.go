@@ -0,0 +1,337 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/apiversion"
+	"match/cmd/pkg/config"
+	"match/cmd/pkg/ratelimit"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setRequiredEnv sets the settings that have no default.
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("PSQL_HOST", "localhost")
+	t.Setenv("PSQL_USER", "root")
+	t.Setenv("PSQL_PASSWORD", "password")
+	t.Setenv("PSQL_DB_NAME", "match")
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("error writing the configuration file: '%s'", err)
+	}
+	return path
+}
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	setRequiredEnv(t)
+
+	cfg, err := config.Load(newFlagSet(), nil)
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := config.Default()
+	expected.DB.Host = "localhost"
+	expected.DB.User = "root"
+	expected.DB.Password = "password"
+	expected.DB.Name = "match"
+
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	setRequiredEnv(t)
+
+	path := writeFile(t, `
+http:
+  port: 9000
+  read_timeout: 10s
+db:
+  replica_hosts: [replica-1:5432]
+match:
+  limit: 5
+  rating_weight: 2
+`)
+	t.Setenv("APP_PORT", "9001")
+	t.Setenv("MATCH_LIMIT", "6")
+	t.Setenv("PSQL_REPLICA_HOSTS", "replica-2:5432, replica-3:5432")
+
+	cfg, err := config.Load(newFlagSet(), []string{"-config", path, "-match-limit", "7", "-index"})
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	// the file overrides the defaults
+	if cfg.HTTP.ReadTimeout != 10*time.Second {
+		t.Errorf("read timeout mismatch: want %v got %v", 10*time.Second, cfg.HTTP.ReadTimeout)
+	}
+	if cfg.Match.RatingWeight != 2 {
+		t.Errorf("rating weight mismatch: want 2 got %v", cfg.Match.RatingWeight)
+	}
+
+	// the env variables override the file
+	if cfg.HTTP.Port != 9001 {
+		t.Errorf("port mismatch: want 9001 got %v", cfg.HTTP.Port)
+	}
+	if diff := cmp.Diff([]string{"replica-2:5432", "replica-3:5432"}, cfg.DB.ReplicaHosts); diff != "" {
+		t.Errorf("replica hosts mismatch (-want +got):\n%s", diff)
+	}
+
+	// the flags override the env variables
+	if cfg.Match.Limit != 7 {
+		t.Errorf("match limit mismatch: want 7 got %v", cfg.Match.Limit)
+	}
+	if !cfg.Index.Enabled {
+		t.Errorf("index mismatch: want true got false")
+	}
+}
+
+func TestLoad_FileFromEnv(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv(config.FileEnv, writeFile(t, "cache:\n  ttl: 2m\n"))
+
+	cfg, err := config.Load(newFlagSet(), nil)
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if cfg.Cache.TTL != 2*time.Minute {
+		t.Errorf("cache ttl mismatch: want %v got %v", 2*time.Minute, cfg.Cache.TTL)
+	}
+}
+
+func TestLoad_UnknownSetting(t *testing.T) {
+	setRequiredEnv(t)
+
+	_, err := config.Load(newFlagSet(), []string{"-config", writeFile(t, "http:\n  prot: 9000\n")})
+
+	if err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("error mismatch: want an unknown setting error got '%v'", err)
+	}
+}
+
+func TestLoad_InvalidFlag(t *testing.T) {
+	setRequiredEnv(t)
+
+	_, err := config.Load(newFlagSet(), []string{"-db-query-timeout", "5"})
+
+	if err == nil {
+		t.Errorf("error mismatch: want an invalid duration error got 'nil'")
+	}
+}
+
+func TestLoad_AllErrorsAtOnce(t *testing.T) {
+	t.Setenv("PSQL_HOST", "localhost")
+	t.Setenv("PSQL_USER", "root")
+	t.Setenv("PSQL_PORT", "five")
+	t.Setenv("CACHE_TTL", "1")
+	t.Setenv("PSQL_REPLICA_HOSTS", "replica")
+	t.Setenv("MATCH_DISTANCE_WEIGHT", "-1")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error mismatch: want config.Errors got '%v'", err)
+	}
+
+	for _, want := range []string{"PSQL_PORT", "CACHE_TTL", "db.password", "db.name", "db.replica_hosts", "match.distance_weight"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error mismatch: want '%s' in '%s'", want, err)
+		}
+	}
+
+	if len(errs) != 6 {
+		t.Errorf("number of errors mismatch: want 6 got %d: %s", len(errs), err)
+	}
+}
+
+func TestLoad_InvalidTracing(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TRACING_EXPORTER", "jaeger")
+	t.Setenv("TRACING_SAMPLE_RATIO", "1.5")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	for _, want := range []string{"tracing.exporter", "tracing.sample_ratio"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error mismatch: want '%s' in '%v'", want, err)
+		}
+	}
+}
+
+func TestLoad_InvalidJWKS(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("AUTH_JWKS_FILE", "/etc/match/jwks.json")
+	t.Setenv("AUTH_JWKS_URL", "id.example.com/jwks.json")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	for _, want := range []string{"mutually exclusive", "auth.jwks_url must be"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error mismatch: want '%s' in '%v'", want, err)
+		}
+	}
+}
+
+func TestLoad_RateLimits(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("RATE_LIMIT_ROUTES", "match=10/1s,jobs=5/1m")
+
+	cfg, err := config.Load(newFlagSet(), nil)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	def, routes, err := cfg.RateLimit.Limits()
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if def != (ratelimit.Limit{Requests: 600, Period: time.Minute}) {
+		t.Errorf("default limit mismatch: want 600/1m got %v", def)
+	}
+
+	expected := map[string]ratelimit.Limit{
+		"match": {Requests: 10, Period: time.Second},
+		"jobs":  {Requests: 5, Period: time.Minute},
+	}
+	if diff := cmp.Diff(expected, routes); diff != "" {
+		t.Errorf("route limits mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Setenv("RATE_LIMIT_ROUTES", "match:10/1s")
+
+	_, err = config.Load(newFlagSet(), nil)
+	if err == nil || !strings.Contains(err.Error(), "rate_limit") {
+		t.Errorf("error mismatch: want an invalid rate limit error got '%v'", err)
+	}
+}
+
+func TestLoad_InvalidIdempotency(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("IDEMPOTENCY_TTL", "0s")
+	t.Setenv("IDEMPOTENCY_LOCK_TIMEOUT", "30s")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	for _, want := range []string{"idempotency.ttl", "idempotency.lock_timeout must be at least http.write_timeout"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error mismatch: want '%s' in '%v'", want, err)
+		}
+	}
+}
+
+func TestLoad_APIDeprecations(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("API_DEPRECATED", "unversioned=2026-01-01/2026-07-01,v1=2027-01-01")
+
+	cfg, err := config.Load(newFlagSet(), nil)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	deprecations, err := cfg.API.Deprecations()
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := map[string]apiversion.Deprecation{
+		"unversioned": {Date: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Sunset: time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		"v1":          {Date: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(expected, deprecations); diff != "" {
+		t.Errorf("deprecations mismatch (-want +got):\n%s", diff)
+	}
+
+	t.Setenv("API_DEPRECATED", "v1:2027-01-01")
+
+	_, err = config.Load(newFlagSet(), nil)
+	if err == nil || !strings.Contains(err.Error(), "api") {
+		t.Errorf("error mismatch: want an invalid deprecation error got '%v'", err)
+	}
+}
+
+func TestLoad_InvalidAPIValidation(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("API_VALIDATE_REQUESTS", "false")
+	t.Setenv("API_VALIDATE_RESPONSES", "true")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	if err == nil || !strings.Contains(err.Error(), "api.validate_responses") {
+		t.Errorf("error mismatch: want an invalid validation error got '%v'", err)
+	}
+}
+
+func TestLoad_InvalidGRPC(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("GRPC_PORT", "8080")
+
+	_, err := config.Load(newFlagSet(), nil)
+
+	if err == nil || !strings.Contains(err.Error(), "grpc.port must not be http.port") {
+		t.Errorf("error mismatch: want a port conflict error got '%v'", err)
+	}
+
+	// the port of a disabled server is not used
+	t.Setenv("GRPC_ENABLED", "false")
+
+	_, err = config.Load(newFlagSet(), nil)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+}
+
+func TestString_RedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.DB.Password = "s3cr3t"
+
+	s := cfg.String()
+
+	if strings.Contains(s, "s3cr3t") {
+		t.Errorf("the password was printed:\n%s", s)
+	}
+	if !strings.Contains(s, "password: '[REDACTED]'") {
+		t.Errorf("the password was not redacted:\n%s", s)
+	}
+
+	if cfg.DB.Password.Value() != "s3cr3t" {
+		t.Errorf("password mismatch: want 's3cr3t' got '%s'", cfg.DB.Password.Value())
+	}
+}
+
+func TestDSN(t *testing.T) {
+	db := config.DB{User: "root", Password: "password", Name: "match"}
+
+	dsn := db.DSN("replica", 5433)
+
+	expected := "host=replica port=5433 user=root password=password dbname=match sslmode=disable"
+	if dsn != expected {
+		t.Errorf("dsn mismatch: want '%s' got '%s'", expected, dsn)
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable with the path of the configuration file, when the '-config' flag is not set.
+const FileEnv = "APP_CONFIG"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load loads the configuration, validates it and parses the command-line arguments with the given flag set, to
+// which it adds the '-config' flag, with the path of the configuration file, and a flag for every setting with a
+// 'flag' tag. The flag set may have flags of its own.
+//
+// The settings start with their defaults, which are overridden by the YAML configuration file, if any, then by
+// their environment variables and then by their flags. All the invalid settings are reported at once, as Errors.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+	fields := settings(reflect.ValueOf(&cfg).Elem())
+
+	path := fs.String("config", "", fmt.Sprintf("the path of the YAML configuration file (or the env variable '%s')", FileEnv))
+	flags := map[string]*flagValue{}
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		fv := &flagValue{setting: f, def: format(f.value)}
+		flags[f.flag] = fv
+		fs.Var(fv, f.flag, fmt.Sprintf("%s (or the env variable '%s')", f.usage, f.env))
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if *path == "" {
+		*path = os.Getenv(FileEnv)
+	}
+	if *path != "" {
+		err = loadFile(*path, &cfg)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	var errs Errors
+	for _, f := range fields {
+		v, ok := os.LookupEnv(f.env)
+		if !ok || v == "" {
+			continue
+		}
+
+		parsed, err := parse(f.value.Type(), v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("env variable '%s': %w", f.env, err))
+			continue
+		}
+		f.value.Set(parsed)
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		if fv, ok := flags[fl.Name]; ok {
+			fv.setting.value.Set(fv.value)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+	if len(errs) > 0 {
+		return Config{}, errs
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides the configuration with the settings of a YAML file. Unknown settings are an error, since they
+// are most likely a typo.
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening the configuration file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	err = dec.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error decoding the configuration file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// setting is a single setting of the configuration.
+type setting struct {
+	value reflect.Value
+	env   string
+	flag  string
+	usage string
+}
+
+// settings returns the settings of a configuration struct, i.e. its fields with an 'env' tag, walking into its
+// nested structs.
+func settings(v reflect.Value) []setting {
+	var res []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			res = append(res, settings(v.Field(i))...)
+			continue
+		}
+
+		env, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		res = append(res, setting{
+			value: v.Field(i),
+			env:   env,
+			flag:  field.Tag.Get("flag"),
+			usage: field.Tag.Get("usage"),
+		})
+	}
+	return res
+}
+
+// parse parses the string value of a setting of the given type. Lists are separated by commas.
+func parse(typ reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(typ).Elem()
+
+	if typ == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, fmt.Errorf("invalid duration '%s', e.g. '5s'", s)
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return v, fmt.Errorf("invalid integer '%s'", s)
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid number '%s'", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, fmt.Errorf("invalid boolean '%s', e.g. 'true'", s)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return v, fmt.Errorf("unsupported setting type %v", typ)
+	}
+
+	return v, nil
+}
+
+// format returns the string value of a setting, as parsed by parse.
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// flagValue is the value of a setting's flag, which is only applied to the configuration after the file and the env
+// variables.
+type flagValue struct {
+	setting setting
+	def     string
+	value   reflect.Value
+}
+
+func (f *flagValue) String() string {
+	if !f.value.IsValid() {
+		return f.def
+	}
+	return format(f.value)
+}
+
+func (f *flagValue) Set(s string) error {
+	v, err := parse(f.setting.value.Type(), s)
+	if err != nil {
+		return err
+	}
+	f.value = v
+	return nil
+}
+
+// IsBoolFlag lets boolean flags be set without a value, e.g. '-index'.
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,94 @@
+// Package graphql serves the partners over GraphQL, at '/graphql', so the clients fetch exactly the fields they need,
+// e.g. only the ids and the distances of the matches for map pins.
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/ratelimit"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxBodySize is the maximum size, in bytes, of the body of '/graphql' requests.
+	maxBodySize = 1 << 20
+
+	// maxDepth is the maximum nesting of the fields of a query, e.g. 3 for 'match { materials { id } }'.
+	maxDepth = 5
+
+	// maxParallelism is the maximum number of fields of a query that are resolved concurrently.
+	maxParallelism = 10
+)
+
+//go:embed schema.graphql
+var schema string
+
+// Handler handles '/graphql' requests.
+type Handler struct {
+	db     partners.Database
+	schema *graphqlgo.Schema
+}
+
+// NewHandler creates a new Handler. Every 'match' and 'partner' field of a query, e.g. with aliases, counts as a
+// request to the 'match' and 'get_partner' routes of l.
+func NewHandler(db partners.Database, l *ratelimit.Limiter) Handler {
+	return Handler{
+		db: db,
+		schema: graphqlgo.MustParseSchema(schema, &resolver{db: db, match: l.Route("match"), partner: l.Route("get_partner")},
+			graphqlgo.UseStringDescriptions(),
+			graphqlgo.MaxDepth(maxDepth),
+			graphqlgo.MaxParallelism(maxParallelism),
+			graphqlgo.Logger(panicLogger{}),
+		),
+	}
+}
+
+// Query executes the GraphQL query of the request's body, '{"query": ..., "operationName": ..., "variables": ...}',
+// and answers with its data and errors. The fields are authorized by the scopes of the request's principal, so it
+// must be wrapped by auth.Require.
+func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&params)
+	if err != nil || params.Query == "" {
+		logging.FromContext(ctx).Warn("error decoding request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	// the partners looked up by the query are loaded together
+	ctx = withLoader(ctx, newLoader(h.db))
+
+	res := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Write(w, b)
+}
+
+// panicLogger logs the panics of the resolvers, which are answered as errors of their field.
+type panicLogger struct{}
+
+func (panicLogger) LogPanic(ctx context.Context, value interface{}) {
+	logging.FromContext(ctx).Error("panic resolving a graphql field", "panic", value)
+}
@@ -0,0 +1,262 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/graphql"
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	admin   = auth.Principal{Name: "admin", Role: auth.RoleAdmin, Scopes: []auth.Scope{auth.ScopeMatch, auth.ScopePartnersRead}}
+	client  = auth.Principal{Name: "shop", Role: auth.RoleClient, Scopes: []auth.Scope{auth.ScopeMatch}}
+	partner = auth.Principal{Name: "acme", Role: auth.RolePartner, Scopes: []auth.Scope{auth.ScopePartnersRead}, PartnerID: 1}
+)
+
+// unlimited doesn't limit the fields.
+var unlimited = ratelimit.New(nil, ratelimit.Options{})
+
+// queryError is an error of the response, with its code.
+type queryError struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+// query serves the query of the principal and returns the data and the errors of the response.
+func query(t *testing.T, h graphql.Handler, p auth.Principal, q string) (string, []queryError) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"query": q})
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+
+	rr := httptest.NewRecorder()
+	h.Query(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want %v got %v", http.StatusOK, rr.Code)
+	}
+
+	var res struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []queryError    `json:"errors"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	return string(res.Data), res.Errors
+}
+
+func TestQuery_Match(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 2}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{
+			{
+				ID:         3,
+				Categories: []models.Category{{ID: 1, PartnerID: 3, Description: "flooring"}},
+				Materials:  []models.Material{{ID: 1, PartnerID: 3, Description: "wood"}, {ID: 2, PartnerID: 3, Description: "tiles"}},
+				Address:    models.Address{Lat: 1.2, Long: 1.2},
+				Radius:     20,
+				Rating:     5,
+			},
+		}, nil)
+
+	h := graphql.NewHandler(db, unlimited)
+
+	data, errs := query(t, h, client, `{
+		match(materials: ["1", "2"], address: {lat: 1.1, long: 1.2}, squareMeters: 5) {
+			id
+			distance
+			materials { id description }
+		}
+	}`)
+
+	if len(errs) > 0 {
+		t.Fatalf("errors mismatch: want none got %v", errs)
+	}
+
+	// only the fields of the query are returned
+	expected := `{"match":[{"id":"3","distance":11,"materials":[{"id":"1","description":"wood"},{"id":"2","description":"tiles"}]}]}`
+	if data != expected {
+		t.Errorf("data mismatch: want %s got %s", expected, data)
+	}
+}
+
+func TestQuery_PartnersAreBatched(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	// the three partners are loaded, with their categories and materials, by a single call
+	db.EXPECT().
+		ListPartners(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, filter models.PartnerFilter) ([]models.Partner, error) {
+			ids := append([]uint(nil), filter.IDs...)
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+			if diff := cmp.Diff([]uint{1, 2, 5}, ids); diff != "" {
+				t.Errorf("ids mismatch (-want +got):\n%s", diff)
+			}
+			if filter.Limit != 3 {
+				t.Errorf("limit mismatch: want 3 got %d", filter.Limit)
+			}
+
+			return []models.Partner{
+				{ID: 1, Categories: []models.Category{{ID: 1, Description: "flooring"}}, Radius: 10, Rating: 4},
+				{ID: 2, Categories: []models.Category{{ID: 2, Description: "painting"}}, Radius: 20, Rating: 3},
+			}, nil
+		})
+
+	h := graphql.NewHandler(db, unlimited)
+
+	data, errs := query(t, h, admin, `{
+		a: partner(id: "1") { id categories { description } }
+		b: partner(id: "2") { id categories { description } }
+		c: partner(id: "5") { id }
+		d: partner(id: "1") { rating distance }
+	}`)
+
+	if len(errs) > 0 {
+		t.Fatalf("errors mismatch: want none got %v", errs)
+	}
+
+	expected := `{"a":{"id":"1","categories":[{"description":"flooring"}]},"b":{"id":"2","categories":[{"description":"painting"}]},"c":null,"d":{"rating":4,"distance":null}}`
+	if data != expected {
+		t.Errorf("data mismatch: want %s got %s", expected, data)
+	}
+}
+
+func TestQuery_Partner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(models.Partner{ID: 1, Address: models.Address{Lat: 1.5, Long: -2.25}, Radius: 10, Rating: 4}, nil)
+
+	h := graphql.NewHandler(db, unlimited)
+
+	data, errs := query(t, h, partner, `{ partner(id: "1") { id address { lat long } radius } }`)
+
+	if len(errs) > 0 {
+		t.Fatalf("errors mismatch: want none got %v", errs)
+	}
+
+	expected := `{"partner":{"id":"1","address":{"lat":1.5,"long":-2.25},"radius":10}}`
+	if data != expected {
+		t.Errorf("data mismatch: want %s got %s", expected, data)
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(1.1), float32(1.2)).
+		Return(nil, repository.ErrUnavailable)
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(4)).
+		Return(models.Partner{}, errors.New("connection reset"))
+
+	h := graphql.NewHandler(db, unlimited)
+
+	for _, tc := range []struct {
+		name      string
+		principal auth.Principal
+		query     string
+		code      string
+	}{
+		// the clients can't look the partners up
+		{"forbidden scope", client, `{ partner(id: "1") { id } }`, "forbidden"},
+		// a partner can only get itself
+		{"other partner", partner, `{ partner(id: "2") { id } }`, "forbidden"},
+		{"invalid id", admin, `{ partner(id: "abc") { id } }`, "bad_request"},
+		{"no materials", client, `{ match(materials: [], address: {lat: 1.1, long: 1.2}) { id } }`, "bad_request"},
+		{"database unavailable", client, `{ match(materials: ["3"], address: {lat: 1.1, long: 1.2}) { id } }`, "service_unavailable"},
+		{"database error", admin, `{ partner(id: "4") { id } }`, "internal_server_error"},
+	} {
+		_, errs := query(t, h, tc.principal, tc.query)
+
+		if len(errs) != 1 {
+			t.Errorf("%s errors mismatch: want 1 got %v", tc.name, errs)
+			continue
+		}
+
+		if errs[0].Extensions.Code != tc.code {
+			t.Errorf("%s code mismatch: want %s got %s", tc.name, tc.code, errs[0].Extensions.Code)
+		}
+	}
+}
+
+func TestQuery_RateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{}, nil).
+		Times(2)
+
+	l := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Options{
+		Routes: map[string]ratelimit.Limit{"match": {Requests: 2, Period: time.Minute}},
+	})
+	h := graphql.NewHandler(db, l)
+
+	// every aliased field counts as a match, so the third one is not matched
+	_, errs := query(t, h, client, `{
+		a: match(materials: ["3"], address: {lat: 1.1, long: 1.2}) { id }
+		b: match(materials: ["3"], address: {lat: 1.1, long: 1.2}) { id }
+		c: match(materials: ["3"], address: {lat: 1.1, long: 1.2}) { id }
+	}`)
+
+	if len(errs) != 1 || errs[0].Extensions.Code != "too_many_requests" {
+		t.Fatalf("errors mismatch: want 1 too_many_requests got %v", errs)
+	}
+}
+
+func TestQuery_InvalidBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	h := graphql.NewHandler(db, unlimited)
+
+	for _, body := range []string{"", `{"query": ""}`, `{"query": 1}`} {
+		rr := httptest.NewRecorder()
+		h.Query(rr, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("'%s' status code mismatch: want %v got %v", body, http.StatusBadRequest, rr.Code)
+		}
+
+		expectedBody := `{"error":"bad_request"}`
+		if rr.Body.String() != expectedBody {
+			t.Errorf("'%s' body mismatch: want %v got %v", body, expectedBody, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+const (
+	// batchWait is the time the first partner lookup of a batch waits for the others, which are resolved concurrently.
+	batchWait = 2 * time.Millisecond
+
+	// maxBatchSize is the maximum number of partners loaded together.
+	maxBatchSize = 100
+)
+
+type loaderKey struct{}
+
+// loader batches the partner lookups of a query, e.g. 'a: partner(id: 1) {...} b: partner(id: 2) {...}', into a
+// single ListPartners, which loads the categories and the materials of all the partners at once, instead of a
+// GetPartnerById, and its categories and materials queries, for each of them.
+type loader struct {
+	db partners.Database
+
+	mu    sync.Mutex
+	batch *batch
+}
+
+// batch is the partner lookups waiting to be loaded together.
+type batch struct {
+	ids  []uint
+	done chan struct{}
+
+	partners map[uint]models.Partner
+	err      error
+}
+
+func newLoader(db partners.Database) *loader {
+	return &loader{db: db}
+}
+
+func withLoader(ctx context.Context, l *loader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+// loaderFrom returns the loader of the request's context, or a loader of the database if it has none.
+func loaderFrom(ctx context.Context, db partners.Database) *loader {
+	if l, ok := ctx.Value(loaderKey{}).(*loader); ok {
+		return l
+	}
+	return newLoader(db)
+}
+
+// load returns the partner with the given id, and whether there is one, once its batch is loaded.
+func (l *loader) load(ctx context.Context, id uint) (models.Partner, bool, error) {
+	l.mu.Lock()
+	b := l.batch
+	if b == nil {
+		b = &batch{done: make(chan struct{})}
+		l.batch = b
+		time.AfterFunc(batchWait, func() { l.run(ctx, b) })
+	}
+	b.ids = append(b.ids, id)
+	if len(b.ids) == maxBatchSize {
+		// the lookups after this one start another batch
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return models.Partner{}, false, ctx.Err()
+	}
+
+	if b.err != nil {
+		return models.Partner{}, false, b.err
+	}
+	p, ok := b.partners[id]
+	return p, ok, nil
+}
+
+// run loads the partners of the batch.
+func (l *loader) run(ctx context.Context, b *batch) {
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	ids := unique(b.ids)
+	l.mu.Unlock()
+
+	defer close(b.done)
+	b.partners = make(map[uint]models.Partner, len(ids))
+
+	// a single partner is looked up by id, which the index and the cache answer from memory
+	if len(ids) == 1 {
+		p, err := l.db.GetPartnerById(ctx, ids[0])
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+		case err != nil:
+			b.err = err
+		default:
+			b.partners[p.ID] = p
+		}
+		return
+	}
+
+	ps, err := l.db.ListPartners(ctx, models.PartnerFilter{IDs: ids, Limit: len(ids)})
+	if err != nil {
+		b.err = err
+		return
+	}
+	for _, p := range ps {
+		b.partners[p.ID] = p
+	}
+}
+
+func unique(ids []uint) []uint {
+	seen := make(map[uint]struct{}, len(ids))
+	res := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			res = append(res, id)
+		}
+	}
+	return res
+}
@@ -0,0 +1,267 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/geo"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+// queryError is the error of a field, with the code of the REST API's error for the same cause, e.g. 'forbidden', in
+// its extensions.
+type queryError struct {
+	code    string
+	message string
+}
+
+func (e queryError) Error() string {
+	return e.message
+}
+
+func (e queryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+var (
+	errForbidden          = queryError{code: "forbidden", message: "the credentials are not allowed to use this field"}
+	errInvalidMatch       = queryError{code: "bad_request", message: "the match must have an address and at least one material"}
+	errInvalidID          = queryError{code: "bad_request", message: "invalid id"}
+	errTooManyRequests    = queryError{code: "too_many_requests", message: "too many requests"}
+	errServiceUnavailable = queryError{code: "service_unavailable", message: "the database is unavailable"}
+	errInternal           = queryError{code: "internal_server_error", message: "internal error"}
+)
+
+// resolver resolves the fields of the Query type.
+type resolver struct {
+	db partners.Database
+
+	// match and partner are the limits of the fields, see limit.
+	match   ratelimit.Route
+	partner ratelimit.Route
+}
+
+type addressInput struct {
+	Lat  float64
+	Long float64
+}
+
+// Match returns the partners that best match the customer's request, with their distance to its address. Their
+// categories and materials are returned by the same query.
+func (r *resolver) Match(ctx context.Context, args struct {
+	Materials    []graphqlgo.ID
+	Address      addressInput
+	SquareMeters *int32
+	PhoneNumber  *string
+}) ([]*partnerResolver, error) {
+	p, err := authorize(ctx, auth.ScopeMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = limit(ctx, r.match, p)
+	if err != nil {
+		return nil, err
+	}
+
+	req := models.MatchRequest{
+		Materials: make([]uint, len(args.Materials)),
+		Address:   models.Address{Lat: float32(args.Address.Lat), Long: float32(args.Address.Long)},
+	}
+	for i, id := range args.Materials {
+		req.Materials[i], err = parseID(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !partners.IsValidMatchRequest(req) {
+		return nil, errInvalidMatch
+	}
+
+	ps, err := r.db.GetMatches(ctx, req.Materials, req.Address.Lat, req.Address.Long)
+	if err != nil {
+		logging.FromContext(ctx).Error("error retrieving matches from the database", "error", err)
+		return nil, databaseError(err)
+	}
+
+	res := make([]*partnerResolver, len(ps))
+	for i, p := range ps {
+		d := distance(p.Address, req.Address)
+		res[i] = &partnerResolver{p: p, distance: &d}
+	}
+	return res, nil
+}
+
+// Partner returns a partner by id, or nil if there is none. The partners looked up by a query, e.g. with aliases,
+// are loaded together.
+func (r *resolver) Partner(ctx context.Context, args struct{ ID graphqlgo.ID }) (*partnerResolver, error) {
+	p, err := authorize(ctx, auth.ScopePartnersRead)
+	if err != nil {
+		return nil, err
+	}
+
+	err = limit(ctx, r.partner, p)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// a partner can only get itself, like with '/partners/{id}'
+	if !p.CanAccessPartner(id) {
+		logging.FromContext(ctx).Warn("forbidden field", "role", string(p.Role), "partner_id", p.PartnerID)
+		return nil, errForbidden
+	}
+
+	partner, ok, err := loaderFrom(ctx, r.db).load(ctx, id)
+	if err != nil {
+		logging.FromContext(ctx).Error("error retrieving the partner from the database", "error", err)
+		return nil, databaseError(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &partnerResolver{p: partner}, nil
+}
+
+// authorize returns the principal of the request if it is allowed to use the scope.
+func authorize(ctx context.Context, scope auth.Scope) (auth.Principal, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok || !p.Can(scope) {
+		logging.FromContext(ctx).Warn("forbidden field", "role", string(p.Role), "scope", string(scope))
+		return auth.Principal{}, errForbidden
+	}
+	return p, nil
+}
+
+// limit takes a request of the principal from its bucket for the route, so the fields of a query count against the
+// same limits as the routes. If the limit can't be checked, e.g. because the shared store is unavailable, the field
+// is resolved.
+func limit(ctx context.Context, route ratelimit.Route, p auth.Principal) error {
+	res, err := route.Take(ctx, ratelimit.PrincipalClient(p))
+	if err != nil {
+		logging.FromContext(ctx).Error("error checking the rate limit", "error", err)
+		return nil
+	}
+
+	if !res.Allowed {
+		logging.FromContext(ctx).Warn("rate limited field", "limit", route.Limit().String())
+		return errTooManyRequests
+	}
+	return nil
+}
+
+func parseID(id graphqlgo.ID) (uint, error) {
+	n, err := strconv.ParseUint(string(id), 10, 32)
+	if err != nil {
+		return 0, errInvalidID
+	}
+	return uint(n), nil
+}
+
+func databaseError(err error) error {
+	if errors.Is(err, repository.ErrUnavailable) {
+		return errServiceUnavailable
+	}
+	return errInternal
+}
+
+// distance returns the distance, in kilometers, between two addresses, rounded like the database's 'haversine'
+// function does.
+func distance(a, b models.Address) int32 {
+	return int32(math.RoundToEven(geo.Distance(float64(a.Lat), float64(a.Long), float64(b.Lat), float64(b.Long))))
+}
+
+// partnerResolver resolves the fields of the Partner type from a partner loaded with its categories and materials,
+// so they don't need a query of their own.
+type partnerResolver struct {
+	p models.Partner
+	// distance is the distance to the address of the match query, nil outside of it.
+	distance *int32
+}
+
+func (r *partnerResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(strconv.FormatUint(uint64(r.p.ID), 10))
+}
+
+func (r *partnerResolver) Categories() []*categoryResolver {
+	res := make([]*categoryResolver, len(r.p.Categories))
+	for i := range r.p.Categories {
+		res[i] = &categoryResolver{c: r.p.Categories[i]}
+	}
+	return res
+}
+
+func (r *partnerResolver) Materials() []*materialResolver {
+	res := make([]*materialResolver, len(r.p.Materials))
+	for i := range r.p.Materials {
+		res[i] = &materialResolver{m: r.p.Materials[i]}
+	}
+	return res
+}
+
+func (r *partnerResolver) Address() *addressResolver {
+	return &addressResolver{a: r.p.Address}
+}
+
+func (r *partnerResolver) Radius() int32 {
+	return int32(r.p.Radius)
+}
+
+func (r *partnerResolver) Rating() int32 {
+	return int32(r.p.Rating)
+}
+
+func (r *partnerResolver) Distance() *int32 {
+	return r.distance
+}
+
+type addressResolver struct {
+	a models.Address
+}
+
+func (r *addressResolver) Lat() float64 {
+	return float64(r.a.Lat)
+}
+
+func (r *addressResolver) Long() float64 {
+	return float64(r.a.Long)
+}
+
+type categoryResolver struct {
+	c models.Category
+}
+
+func (r *categoryResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(strconv.FormatUint(uint64(r.c.ID), 10))
+}
+
+func (r *categoryResolver) Description() string {
+	return r.c.Description
+}
+
+type materialResolver struct {
+	m models.Material
+}
+
+func (r *materialResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(strconv.FormatUint(uint64(r.m.ID), 10))
+}
+
+func (r *materialResolver) Description() string {
+	return r.m.Description
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+)
+
+// checkTimeout is the maximum time of each readiness check.
+const checkTimeout = 2 * time.Second
+
+// The statuses of the reports and of their checks.
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker checks whether a dependency of the app, e.g. the database, works.
+type Checker interface {
+	// Check returns an error if the dependency does not work.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is a function that is a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Report is the body of the health responses.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is the result of a single readiness check. The error of a failing check is only logged, since the
+// reports are public.
+type CheckResult struct {
+	Status string `json:"status"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+// Handler handles the '/healthz' and '/readyz' requests. The readiness depends on the checkers registered with it.
+type Handler struct {
+	mu     sync.RWMutex
+	checks []check
+
+	// shuttingDown is 1 once the app started to shut down, 0 before.
+	shuttingDown int32
+}
+
+// NewHandler creates a new Handler, without checkers.
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Register adds a readiness check with the given name.
+func (h *Handler) Register(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, check{name: name, checker: c})
+}
+
+// ShuttingDown makes the app not ready from now on, so no new requests are sent to it while it shuts down.
+func (h *Handler) ShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// Liveness reports that the app is alive, i.e. that it answers requests.
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeReport(w, r, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readiness reports whether the app is ready to serve requests, i.e. whether every check passes, with the result of
+// each check. The checks run concurrently.
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		writeReport(w, r, http.StatusServiceUnavailable, Report{Status: StatusShuttingDown})
+		return
+	}
+
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+	code := http.StatusOK
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusUnavailable
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	writeReport(w, r, code, report)
+}
+
+func runCheck(ctx context.Context, c check) CheckResult {
+	err := c.checker.Check(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("error checking the readiness", "check", c.name, "error", err)
+		return CheckResult{Status: StatusFailing}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, code int, report Report) {
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.WriteHeader(code)
+	response.Write(w, jsonBytes)
+}
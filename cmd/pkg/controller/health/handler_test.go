@@ -0,0 +1,122 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"match/cmd/pkg/controller/health"
+)
+
+func ok(ctx context.Context) error {
+	return nil
+}
+
+func TestLiveness(t *testing.T) {
+	handler := health.NewHandler()
+	handler.Register("database", health.CheckerFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+	handler.Liveness(rr, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"status":"ok"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestReadiness_Ready(t *testing.T) {
+	handler := health.NewHandler()
+	handler.Register("database", health.CheckerFunc(ok))
+	handler.Register("migrations", health.CheckerFunc(ok))
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	handler.Readiness(rr, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"status":"ok","checks":{"database":{"status":"ok"},"migrations":{"status":"ok"}}}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestReadiness_CheckFailing(t *testing.T) {
+	handler := health.NewHandler()
+	handler.Register("database", health.CheckerFunc(ok))
+	handler.Register("partners index", health.CheckerFunc(func(ctx context.Context) error {
+		return errors.New("not loaded")
+	}))
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	handler.Readiness(rr, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"status":"unavailable","checks":{"database":{"status":"ok"},"partners index":{"status":"failing"}}}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestReadiness_CheckTimeout(t *testing.T) {
+	handler := health.NewHandler()
+	handler.Register("database", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	rr := httptest.NewRecorder()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(ctx)
+
+	handler.Readiness(rr, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+}
+
+func TestReadiness_ShuttingDown(t *testing.T) {
+	handler := health.NewHandler()
+	handler.Register("database", health.CheckerFunc(ok))
+	handler.ShuttingDown()
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	handler.Readiness(rr, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"status":"shutting_down"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
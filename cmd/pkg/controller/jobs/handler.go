@@ -0,0 +1,320 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"match/cmd/pkg/apiversion"
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// MaxJobSize is the maximum number of match requests in a single job.
+	MaxJobSize = 100000
+
+	// maxBodySize is the maximum size, in bytes, of the body of '/jobs' requests.
+	maxBodySize = 64 << 20
+
+	// maxMultipartMemory is the maximum size, in bytes, of a multipart form held in memory. The rest is stored on disk.
+	maxMultipartMemory = 8 << 20
+)
+
+var (
+	errNoRequests       = errors.New("no match requests")
+	errTooManyRequests  = fmt.Errorf("more than %d match requests", MaxJobSize)
+	errUnsupportedMedia = errors.New("unsupported media type")
+)
+
+// Database can communicate with the persistent storage for our jobs.
+type Database interface {
+	// CreateJob persists a new pending job of the owner for the given match requests.
+	CreateJob(ctx context.Context, owner string, reqs []models.MatchRequest) (models.Job, error)
+
+	// GetJob returns a job by id.
+	GetJob(ctx context.Context, id string) (models.Job, error)
+
+	// StreamJobResults calls fn for every result of the job, in the same order as the job's requests.
+	StreamJobResults(ctx context.Context, id string, fn func(r models.MatchResult) error) error
+}
+
+// Handler handles '/jobs' requests.
+type Handler struct {
+	db Database
+}
+
+// NewHandler creates a new Handler.
+func NewHandler(db Database) Handler {
+	return Handler{db: db}
+}
+
+// CreateJob creates a job that matches, in the background, each of the customers' requests. The job is owned by
+// the client that creates it, which is the only one, besides the admins, that can see it.
+//
+// The requests are sent either as a JSON array, as JSON Lines or as a multipart form with a 'file' field holding
+// any of the previous.
+func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	reqs, err := decodeMatchRequests(r)
+	if err != nil {
+		logging.FromContext(ctx).Warn("error decoding request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	p, _ := auth.FromContext(ctx)
+	j, err := h.db.CreateJob(ctx, p.ID, reqs)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating the job in the database", "error", err)
+		writeDatabaseError(w, err)
+		return
+	}
+
+	var jsonBytes []byte
+	jsonBytes, err = json.Marshal(j)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.Header().Set("Location", apiversion.Path(ctx, "/jobs/"+j.ID))
+	w.WriteHeader(http.StatusAccepted)
+	response.Write(w, jsonBytes)
+}
+
+// GetJob returns the status and progress of a job.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	j, ok := h.getJob(ctx, w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	jsonBytes, err := json.Marshal(j)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Write(w, jsonBytes)
+}
+
+// GetJobResults streams the results of a done job, as a JSON array in the same order as the job's requests.
+func (h *Handler) GetJobResults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	j, ok := h.getJob(ctx, w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	if j.Status != models.JobDone {
+		w.WriteHeader(http.StatusConflict)
+		response.Write(w, []byte(response.ErrConflict))
+		return
+	}
+
+	var n int
+	err := h.db.StreamJobResults(ctx, j.ID, func(res models.MatchResult) error {
+		b, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+
+		sep := ","
+		if n == 0 {
+			w.WriteHeader(http.StatusOK)
+			sep = "["
+		}
+		n++
+
+		_, err = io.WriteString(w, sep)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	})
+
+	if err != nil {
+		// once the first result is written the status code was already sent, so the response is just cut short
+		logging.FromContext(ctx).Error("error streaming the results of the job", "job_id", j.ID, "streamed", n, "error", err)
+		if n == 0 {
+			writeDatabaseError(w, err)
+		}
+		return
+	}
+
+	if n == 0 {
+		w.WriteHeader(http.StatusOK)
+		response.Write(w, []byte("[]"))
+		return
+	}
+
+	response.Write(w, []byte("]"))
+}
+
+// getJob returns the job with the given id, or writes the error response and returns false if it can't. The jobs
+// of other clients are not found, unless the client is an admin, so their ids can't be probed.
+func (h *Handler) getJob(ctx context.Context, w http.ResponseWriter, id string) (models.Job, bool) {
+	j, err := h.db.GetJob(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		logging.FromContext(ctx).Error("error retrieving the job from the database", "error", err)
+		writeDatabaseError(w, err)
+		return models.Job{}, false
+	}
+
+	if err != nil || !canSee(ctx, j) {
+		w.WriteHeader(http.StatusNotFound)
+		response.Write(w, []byte(response.ErrNotFound))
+		return models.Job{}, false
+	}
+
+	return j, true
+}
+
+// canSee reports whether the principal of the request is allowed to see the job, i.e. it owns it or is an admin.
+func canSee(ctx context.Context, j models.Job) bool {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	return p.Role == auth.RoleAdmin || (p.ID != "" && p.ID == j.Owner)
+}
+
+// writeDatabaseError writes the response for an error of the database, which is service unavailable when the
+// database can't be reached.
+func writeDatabaseError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrUnavailable) {
+		response.WriteServiceUnavailable(w)
+		return
+	}
+	response.WriteInternalServerError(w)
+}
+
+// decodeMatchRequests decodes the match requests of the body, according to its content type.
+func decodeMatchRequests(r *http.Request) ([]models.MatchRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	var reqs []models.MatchRequest
+
+	switch mediaType {
+	case "application/json":
+		err = json.NewDecoder(r.Body).Decode(&reqs)
+	case "application/jsonl", "application/x-ndjson":
+		reqs, err = decodeLines(r.Body)
+	case "multipart/form-data":
+		reqs, err = decodeFormFile(r)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", errUnsupportedMedia, mediaType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reqs) == 0 {
+		return nil, errNoRequests
+	}
+
+	if len(reqs) > MaxJobSize {
+		return nil, errTooManyRequests
+	}
+
+	return reqs, nil
+}
+
+// decodeFormFile decodes the match requests of the 'file' field of a multipart form.
+func decodeFormFile(r *http.Request) ([]models.MatchRequest, error) {
+	err := r.ParseMultipartForm(maxMultipartMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	f, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decodeFile(f)
+}
+
+// decodeFile decodes match requests from a file holding either a JSON array or JSON Lines.
+func decodeFile(r io.Reader) ([]models.MatchRequest, error) {
+	br := bufio.NewReader(r)
+
+	// the first non blank character tells whether the file is a JSON array or JSON Lines
+	var first byte
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errNoRequests
+			}
+			return nil, err
+		}
+
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			first = c
+			_ = br.UnreadByte()
+			break
+		}
+	}
+
+	if first == '[' {
+		var reqs []models.MatchRequest
+		err := json.NewDecoder(br).Decode(&reqs)
+		return reqs, err
+	}
+
+	return decodeLines(br)
+}
+
+// decodeLines decodes match requests from JSON Lines, i.e. one request per line.
+func decodeLines(r io.Reader) ([]models.MatchRequest, error) {
+	var reqs []models.MatchRequest
+
+	dec := json.NewDecoder(r)
+	for {
+		var req models.MatchRequest
+		err := dec.Decode(&req)
+		if err == io.EOF {
+			return reqs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, req)
+		if len(reqs) > MaxJobSize {
+			return nil, errTooManyRequests
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package jobs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/apiversion"
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/jobs"
+	"match/cmd/pkg/controller/jobs/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/openapi/openapitest"
+	"match/cmd/pkg/repository"
+
+	"github.com/golang/mock/gomock"
+)
+
+var testRequests = []models.MatchRequest{
+	{
+		Materials: []uint{1, 2},
+		Address: models.Address{
+			Lat:  1.1,
+			Long: 1.2,
+		},
+		SquareMeters: 5,
+	},
+	{
+		Materials: []uint{3},
+		Address: models.Address{
+			Lat:  2.1,
+			Long: 2.2,
+		},
+	},
+}
+
+var testClient = auth.Principal{ID: "key:1", Name: "client", Role: auth.RoleClient, Scopes: []auth.Scope{auth.ScopeJobs}}
+
+var testJob = models.Job{
+	ID:        "0123456789abcdef0123456789abcdef",
+	Owner:     testClient.ID,
+	Status:    models.JobPending,
+	Total:     2,
+	CreatedAt: time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC),
+}
+
+// as returns the request authenticated as the principal.
+func as(req *http.Request, p auth.Principal) *http.Request {
+	return req.WithContext(auth.WithPrincipal(req.Context(), p))
+}
+
+const testJobBody string = `{"id":"0123456789abcdef0123456789abcdef","status":"pending","total":2,"processed":0,"created_at":"2022-07-01T10:00:00Z","updated_at":"2022-07-01T10:00:00Z"}`
+
+func TestCreateJob_InvalidBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", ""},
+		{"application/json", "[]"},
+		{"application/json", `{"materials": [1]}`},
+		{"application/jsonl", `{"materials": [1]}` + "\n" + "{"},
+		{"text/plain", `[{"materials": [1]}]`},
+		{"", `[{"materials": [1]}]`},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		db := mock.NewMockDatabase(ctrl)
+
+		handler := jobs.NewHandler(db)
+		rr := httptest.NewRecorder()
+
+		req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+
+		handler.CreateJob(rr, req)
+
+		expectedCode := http.StatusBadRequest
+		if rr.Code != expectedCode {
+			t.Errorf("%s %s: status code mismatch: want %v got %v", test.contentType, test.body, expectedCode, rr.Code)
+		}
+
+		expectedBody := `{"error":"bad_request"}`
+		if rr.Body.String() != expectedBody {
+			t.Errorf("%s %s: body mismatch: want %v got %v", test.contentType, test.body, expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestCreateJob_VersionedLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		CreateJob(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(testJob, nil)
+
+	handler := jobs.NewHandler(db)
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", strings.NewReader(`[{"materials": [1]}]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(apiversion.WithVersion(req.Context(), apiversion.Version{Name: "v1"}))
+
+	handler.CreateJob(rr, req)
+
+	expectedLocation := "/v1/jobs/" + testJob.ID
+	if rr.Header().Get("Location") != expectedLocation {
+		t.Errorf("location mismatch: want %v got %v", expectedLocation, rr.Header().Get("Location"))
+	}
+}
+
+func TestCreateJob_DatabaseFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		CreateJob(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(models.Job{}, errors.New("some error"))
+
+	handler := jobs.NewHandler(db)
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`[{"materials": [1]}]`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := openapitest.Serve(t, "create_job", handler.CreateJob, req)
+
+	expectedCode := http.StatusInternalServerError
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"internal_server_error"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestCreateJob_DatabaseUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		CreateJob(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(models.Job{}, repository.ErrUnavailable)
+
+	handler := jobs.NewHandler(db)
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`[{"materials": [1]}]`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := openapitest.Serve(t, "create_job", handler.CreateJob, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	if rr.Header().Get("Retry-After") == "" {
+		t.Errorf("missing Retry-After header")
+	}
+}
+
+func TestCreateJob_Success(t *testing.T) {
+	jsonArray := `
+	[
+		{"materials": [1, 2], "address": {"lat": 1.1, "long": 1.2}, "square_meters": 5},
+		{"materials": [3], "address": {"lat": 2.1, "long": 2.2}}
+	]
+	`
+	jsonLines := `{"materials": [1, 2], "address": {"lat": 1.1, "long": 1.2}, "square_meters": 5}` + "\n" +
+		`{"materials": [3], "address": {"lat": 2.1, "long": 2.2}}` + "\n"
+
+	multipartBody := func(content string) (string, string) {
+		buffer := new(bytes.Buffer)
+		mw := multipart.NewWriter(buffer)
+		fw, _ := mw.CreateFormFile("file", "requests.jsonl")
+		_, _ = fw.Write([]byte(content))
+		_ = mw.Close()
+		return mw.FormDataContentType(), buffer.String()
+	}
+
+	multipartJsonLinesContentType, multipartJsonLines := multipartBody(jsonLines)
+	multipartJsonArrayContentType, multipartJsonArray := multipartBody(jsonArray)
+
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", jsonArray},
+		{"application/json; charset=utf-8", jsonArray},
+		{"application/jsonl", jsonLines},
+		{"application/x-ndjson", jsonLines},
+		{multipartJsonLinesContentType, multipartJsonLines},
+		{multipartJsonArrayContentType, multipartJsonArray},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		db := mock.NewMockDatabase(ctrl)
+
+		db.EXPECT().
+			CreateJob(gomock.Any(), testClient.ID, testRequests).
+			Return(testJob, nil)
+
+		handler := jobs.NewHandler(db)
+		rr := httptest.NewRecorder()
+
+		req := as(httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(test.body)), testClient)
+		req.Header.Set("Content-Type", test.contentType)
+
+		handler.CreateJob(rr, req)
+
+		expectedCode := http.StatusAccepted
+		if rr.Code != expectedCode {
+			t.Errorf("%s: status code mismatch: want %v got %v", test.contentType, expectedCode, rr.Code)
+		}
+
+		expectedLocation := "/jobs/" + testJob.ID
+		if rr.Header().Get("Location") != expectedLocation {
+			t.Errorf("%s: location mismatch: want %v got %v", test.contentType, expectedLocation, rr.Header().Get("Location"))
+		}
+
+		if rr.Body.String() != testJobBody {
+			t.Errorf("%s: body mismatch: want %v got %v", test.contentType, testJobBody, rr.Body.String())
+		}
+	}
+}
+
+func TestGetJob_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(models.Job{}, repository.ErrNotFound)
+
+	handler := jobs.NewHandler(db)
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID, nil), testClient)
+
+	rr := openapitest.Serve(t, "get_job", handler.GetJob, req)
+
+	expectedCode := http.StatusNotFound
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"not_found"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestGetJob_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(testJob, nil)
+
+	handler := jobs.NewHandler(db)
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID, nil), testClient)
+
+	rr := openapitest.Serve(t, "get_job", handler.GetJob, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	if rr.Body.String() != testJobBody {
+		t.Errorf("body mismatch: want %v got %v", testJobBody, rr.Body.String())
+	}
+}
+
+func TestGetJob_OtherOwner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(testJob, nil).
+		Times(2)
+
+	handler := jobs.NewHandler(db)
+	other := auth.Principal{ID: "key:2", Name: "other", Role: auth.RoleClient, Scopes: []auth.Scope{auth.ScopeJobs}}
+
+	tests := []struct {
+		operationID string
+		path        string
+		h           http.HandlerFunc
+	}{
+		{"get_job", "/jobs/" + testJob.ID, handler.GetJob},
+		{"get_job_results", "/jobs/" + testJob.ID + "/results", handler.GetJobResults},
+	}
+
+	for _, test := range tests {
+		req := as(httptest.NewRequest(http.MethodGet, test.path, nil), other)
+
+		rr := openapitest.Serve(t, test.operationID, test.h, req)
+
+		expectedCode := http.StatusNotFound
+		if rr.Code != expectedCode {
+			t.Errorf("%s: status code mismatch: want %v got %v", test.operationID, expectedCode, rr.Code)
+		}
+
+		expectedBody := `{"error":"not_found"}`
+		if rr.Body.String() != expectedBody {
+			t.Errorf("%s: body mismatch: want %v got %v", test.operationID, expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestGetJob_Admin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(testJob, nil)
+
+	handler := jobs.NewHandler(db)
+	admin := auth.Principal{ID: "key:2", Name: "admin", Role: auth.RoleAdmin}
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID, nil), admin)
+
+	rr := openapitest.Serve(t, "get_job", handler.GetJob, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	if rr.Body.String() != testJobBody {
+		t.Errorf("body mismatch: want %v got %v", testJobBody, rr.Body.String())
+	}
+}
+
+func TestGetJobResults_NotDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(testJob, nil)
+
+	handler := jobs.NewHandler(db)
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID+"/results", nil), testClient)
+
+	rr := openapitest.Serve(t, "get_job_results", handler.GetJobResults, req)
+
+	expectedCode := http.StatusConflict
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"conflict"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestGetJobResults_DatabaseFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	j := testJob
+	j.Status = models.JobDone
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(j, nil)
+
+	db.EXPECT().
+		StreamJobResults(gomock.Any(), testJob.ID, gomock.Any()).
+		Return(errors.New("some error"))
+
+	handler := jobs.NewHandler(db)
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID+"/results", nil), testClient)
+
+	rr := openapitest.Serve(t, "get_job_results", handler.GetJobResults, req)
+
+	expectedCode := http.StatusInternalServerError
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"internal_server_error"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestGetJobResults_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	j := testJob
+	j.Status = models.JobDone
+	j.Processed = 2
+
+	db.EXPECT().
+		GetJob(gomock.Any(), testJob.ID).
+		Return(j, nil)
+
+	db.EXPECT().
+		StreamJobResults(gomock.Any(), testJob.ID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, fn func(r models.MatchResult) error) error {
+			_ = fn(models.MatchResult{Partners: []models.Partner{}})
+			return fn(models.MatchResult{Error: "bad_request"})
+		})
+
+	handler := jobs.NewHandler(db)
+	req := as(httptest.NewRequest(http.MethodGet, "/jobs/"+testJob.ID+"/results", nil), testClient)
+
+	rr := openapitest.Serve(t, "get_job_results", handler.GetJobResults, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `[{"partners":[]},{"partners":null,"error":"bad_request"}]`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../handler.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	models "match/cmd/pkg/models"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDatabase is a mock of Database interface.
+type MockDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatabaseMockRecorder
+}
+
+// MockDatabaseMockRecorder is the mock recorder for MockDatabase.
+type MockDatabaseMockRecorder struct {
+	mock *MockDatabase
+}
+
+// NewMockDatabase creates a new mock instance.
+func NewMockDatabase(ctrl *gomock.Controller) *MockDatabase {
+	mock := &MockDatabase{ctrl: ctrl}
+	mock.recorder = &MockDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
+	return m.recorder
+}
+
+// CreateJob mocks base method.
+func (m *MockDatabase) CreateJob(ctx context.Context, owner string, reqs []models.MatchRequest) (models.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateJob", ctx, owner, reqs)
+	ret0, _ := ret[0].(models.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateJob indicates an expected call of CreateJob.
+func (mr *MockDatabaseMockRecorder) CreateJob(ctx, owner, reqs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJob", reflect.TypeOf((*MockDatabase)(nil).CreateJob), ctx, owner, reqs)
+}
+
+// GetJob mocks base method.
+func (m *MockDatabase) GetJob(ctx context.Context, id string) (models.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJob", ctx, id)
+	ret0, _ := ret[0].(models.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJob indicates an expected call of GetJob.
+func (mr *MockDatabaseMockRecorder) GetJob(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJob", reflect.TypeOf((*MockDatabase)(nil).GetJob), ctx, id)
+}
+
+// StreamJobResults mocks base method.
+func (m *MockDatabase) StreamJobResults(ctx context.Context, id string, fn func(models.MatchResult) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamJobResults", ctx, id, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamJobResults indicates an expected call of StreamJobResults.
+func (mr *MockDatabaseMockRecorder) StreamJobResults(ctx, id, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamJobResults", reflect.TypeOf((*MockDatabase)(nil).StreamJobResults), ctx, id, fn)
+}
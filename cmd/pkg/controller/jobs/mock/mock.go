@@ -0,0 +1,3 @@
+//go:generate mockgen -package=mock -source=../handler.go -destination=./handler.go
+
+package mock
@@ -0,0 +1,102 @@
+package partners
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+const (
+	// MaxBatchSize is the maximum number of match requests in a single '/partners/match/batch' request.
+	MaxBatchSize = 1000
+
+	// batchWorkers is the maximum number of match requests of a batch processed at the same time.
+	batchWorkers = 8
+)
+
+// Error codes of the match results.
+const (
+	MatchErrBadRequest          = "bad_request"
+	MatchErrInternalServerError = "internal_server_error"
+	MatchErrCanceled            = "canceled"
+	MatchErrServiceUnavailable  = "service_unavailable"
+)
+
+// MatchBatch returns the best matches for each of the given match requests, in the same order as the requests.
+// Up to workers requests are processed concurrently. If the context is done before all requests are processed,
+// the remaining requests are not processed and their results have the error MatchErrCanceled.
+func MatchBatch(ctx context.Context, db Database, reqs []models.MatchRequest, workers int) []models.MatchResult {
+	results := make([]models.MatchResult, len(reqs))
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(reqs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range indexes {
+				results[j] = match(ctx, db, reqs[j])
+			}
+		}()
+	}
+
+dispatch:
+	for i := range reqs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			for j := i; j < len(reqs); j++ {
+				results[j] = models.MatchResult{Error: MatchErrCanceled}
+			}
+			break dispatch
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func match(ctx context.Context, db Database, req models.MatchRequest) models.MatchResult {
+	if ctx.Err() != nil {
+		return models.MatchResult{Error: MatchErrCanceled}
+	}
+
+	if !IsValidMatchRequest(req) {
+		return models.MatchResult{Error: MatchErrBadRequest}
+	}
+
+	ps, err := db.GetMatches(ctx, req.Materials, req.Address.Lat, req.Address.Long)
+	if err != nil {
+		if ctx.Err() != nil {
+			return models.MatchResult{Error: MatchErrCanceled}
+		}
+		logging.FromContext(ctx).Error("error retrieving matches from the database", "error", err)
+		if errors.Is(err, repository.ErrUnavailable) {
+			return models.MatchResult{Error: MatchErrServiceUnavailable}
+		}
+		return models.MatchResult{Error: MatchErrInternalServerError}
+	}
+
+	if ps == nil {
+		ps = []models.Partner{}
+	}
+
+	return models.MatchResult{Partners: ps}
+}
+
+// IsValidMatchRequest reports whether the match request has an address and at least one material.
+func IsValidMatchRequest(req models.MatchRequest) bool {
+	var a models.Address
+	return req.Address != a && len(req.Materials) > 0
+}
@@ -0,0 +1,220 @@
+package partners_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatchBatch_PreservesOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	var reqs []models.MatchRequest
+	var expected []models.MatchResult
+	for i := 1; i <= 20; i++ {
+		reqs = append(reqs, models.MatchRequest{
+			Materials: []uint{uint(i)},
+			Address:   models.Address{Lat: float32(i), Long: 1},
+		})
+		expected = append(expected, models.MatchResult{Partners: []models.Partner{{ID: uint(i)}}})
+	}
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), gomock.Any(), gomock.Any(), float32(1)).
+		DoAndReturn(func(_ context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
+			// the first requests take longer, so they finish after the later ones
+			time.Sleep(time.Duration(20-materials[0]) * time.Millisecond)
+			return []models.Partner{{ID: materials[0]}}, nil
+		}).
+		Times(len(reqs))
+
+	results := partners.MatchBatch(context.Background(), db, reqs, 4)
+
+	if diff := cmp.Diff(expected, results); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMatchBatch_PartialFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	reqs := []models.MatchRequest{
+		{Materials: []uint{1}, Address: models.Address{Lat: 1, Long: 1}},
+		{Materials: []uint{1}},
+		{Materials: []uint{2}, Address: models.Address{Lat: 2, Long: 2}},
+		{Materials: []uint{3}, Address: models.Address{Lat: 3, Long: 3}},
+		{Materials: []uint{4}, Address: models.Address{Lat: 4, Long: 4}},
+	}
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1), float32(1)).
+		Return([]models.Partner{{ID: 1}}, nil)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{4}, float32(4), float32(4)).
+		Return(nil, repository.ErrUnavailable)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{2}, float32(2), float32(2)).
+		Return(nil, errors.New("some error"))
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(3), float32(3)).
+		Return(nil, nil)
+
+	results := partners.MatchBatch(context.Background(), db, reqs, 2)
+
+	expected := []models.MatchResult{
+		{Partners: []models.Partner{{ID: 1}}},
+		{Error: partners.MatchErrBadRequest},
+		{Error: partners.MatchErrInternalServerError},
+		{Partners: []models.Partner{}},
+		{Error: partners.MatchErrServiceUnavailable},
+	}
+
+	if diff := cmp.Diff(expected, results); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMatchBatch_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	reqs := []models.MatchRequest{
+		{Materials: []uint{1}, Address: models.Address{Lat: 1, Long: 1}},
+		{Materials: []uint{2}, Address: models.Address{Lat: 2, Long: 2}},
+		{Materials: []uint{3}, Address: models.Address{Lat: 3, Long: 3}},
+	}
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1), float32(1)).
+		DoAndReturn(func(ctx context.Context, _ []uint, _, _ float32) ([]models.Partner, error) {
+			cancel()
+			return nil, ctx.Err()
+		})
+
+	results := partners.MatchBatch(ctx, db, reqs, 1)
+
+	for i, r := range results {
+		if r.Error != partners.MatchErrCanceled {
+			t.Errorf("result %d error mismatch: want %v got %v", i, partners.MatchErrCanceled, r.Error)
+		}
+	}
+}
+
+func TestGetBatchMatches_InvalidBody(t *testing.T) {
+	bodies := []string{
+		"",
+		"{}",
+		"[]",
+		"[" + strings.TrimSuffix(strings.Repeat(`{"materials":[1]},`, partners.MaxBatchSize+1), ",") + "]",
+	}
+
+	for _, body := range bodies {
+		ctrl := gomock.NewController(t)
+		db := mock.NewMockDatabase(ctrl)
+
+		handler := partners.NewHandler(db)
+		rr := httptest.NewRecorder()
+
+		req := httptest.NewRequest(http.MethodPost, "/partners/match/batch", strings.NewReader(body))
+
+		handler.GetBatchMatches(rr, req)
+
+		expectedCode := http.StatusBadRequest
+		if rr.Code != expectedCode {
+			t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+		}
+
+		expectedBody := `{"error":"bad_request"}`
+		if rr.Body.String() != expectedBody {
+			t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestGetBatchMatches_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 2}, float32(1.1), float32(1.2)).
+		DoAndReturn(func(ctx context.Context, _ []uint, _, _ float32) ([]models.Partner, error) {
+			cancel()
+			return nil, ctx.Err()
+		}).
+		MinTimes(1)
+
+	handler := partners.NewHandler(db)
+	rr := httptest.NewRecorder()
+
+	reqBody := fmt.Sprintf(`[%s, %s]`, testMatchRequestBody, testMatchRequestBody)
+	req := httptest.NewRequest(http.MethodPost, "/partners/match/batch", strings.NewReader(reqBody)).WithContext(ctx)
+
+	handler.GetBatchMatches(rr, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"service_unavailable"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestGetBatchMatches_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 2}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{{ID: 3, Radius: 100, Rating: 5}}, nil)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(2.1), float32(2.2)).
+		Return(nil, errors.New("some error"))
+
+	handler := partners.NewHandler(db)
+	rr := httptest.NewRecorder()
+
+	reqBody := fmt.Sprintf(`[%s, {"materials": [1]}, {"materials": [3], "address": {"lat": 2.1, "long": 2.2}}]`, testMatchRequestBody)
+	req := httptest.NewRequest(http.MethodPost, "/partners/match/batch", strings.NewReader(reqBody))
+
+	handler.GetBatchMatches(rr, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `[` +
+		`{"partners":[{"id":3,"categories":null,"materials":null,"address":{"lat":0,"long":0},"radius":100,"rating":5}]},` +
+		`{"partners":null,"error":"bad_request"},` +
+		`{"partners":null,"error":"internal_server_error"}` +
+		`]`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
@@ -0,0 +1,197 @@
+package partners
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"match/cmd/pkg/models"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var (
+	errInvalidFilter = errors.New("invalid filter")
+)
+
+// parsePartnerFilter parses the query parameters of '/partners' requests:
+//
+//   - material, category: ids that the partner must have, can be repeated;
+//   - min_rating, max_rating: the rating range, inclusive;
+//   - lat, long, distance: the point, and the distance in kilometers around it, the partner must be located within;
+//   - bbox: the bounding box, as 'min_long,min_lat,max_long,max_lat', the partner must be located inside of;
+//   - sort: one of 'id', 'rating', 'radius' or 'distance', prefixed by '-' for descending order;
+//   - limit, offset: the pagination.
+func parsePartnerFilter(q url.Values) (models.PartnerFilter, error) {
+	f := models.PartnerFilter{
+		Sort:  models.SortByID,
+		Limit: defaultListLimit,
+	}
+
+	var err error
+
+	f.Materials, err = parseIds(q["material"])
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	f.Categories, err = parseIds(q["category"])
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	f.MinRating, err = parseOptionalInt(q, "min_rating")
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	f.MaxRating, err = parseOptionalInt(q, "max_rating")
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	if f.MinRating != nil && f.MaxRating != nil && *f.MinRating > *f.MaxRating {
+		return models.PartnerFilter{}, fmt.Errorf("%w: 'min_rating' is greater than 'max_rating'", errInvalidFilter)
+	}
+
+	f.Near, err = parseProximity(q)
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	f.Box, err = parseBoundingBox(q.Get("bbox"))
+	if err != nil {
+		return models.PartnerFilter{}, err
+	}
+
+	if s := q.Get("sort"); s != "" {
+		f.Descending = strings.HasPrefix(s, "-")
+		f.Sort = models.PartnerSort(strings.TrimPrefix(s, "-"))
+	}
+
+	switch f.Sort {
+	case models.SortByID, models.SortByRating, models.SortByRadius:
+	case models.SortByDistance:
+		if f.Near == nil {
+			return models.PartnerFilter{}, fmt.Errorf("%w: sorting by distance requires 'lat', 'long' and 'distance'", errInvalidFilter)
+		}
+	default:
+		return models.PartnerFilter{}, fmt.Errorf("%w: unknown sort '%s'", errInvalidFilter, f.Sort)
+	}
+
+	if v := q.Get("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 1 || f.Limit > maxListLimit {
+			return models.PartnerFilter{}, fmt.Errorf("%w: 'limit' must be between 1 and %d", errInvalidFilter, maxListLimit)
+		}
+	}
+
+	if v := q.Get("offset"); v != "" {
+		f.Offset, err = strconv.Atoi(v)
+		if err != nil || f.Offset < 0 {
+			return models.PartnerFilter{}, fmt.Errorf("%w: 'offset' must be a non negative integer", errInvalidFilter)
+		}
+	}
+
+	return f, nil
+}
+
+func parseIds(vs []string) ([]uint, error) {
+	var ids []uint
+	for _, v := range vs {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid id '%s'", errInvalidFilter, v)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func parseOptionalInt(q url.Values, key string) (*int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s' must be an integer", errInvalidFilter, key)
+	}
+	return &i, nil
+}
+
+func parseProximity(q url.Values) (*models.Proximity, error) {
+	lat, long, distance := q.Get("lat"), q.Get("long"), q.Get("distance")
+	if lat == "" && long == "" && distance == "" {
+		return nil, nil
+	}
+
+	if lat == "" || long == "" || distance == "" {
+		return nil, fmt.Errorf("%w: 'lat', 'long' and 'distance' must be given together", errInvalidFilter)
+	}
+
+	var p models.Proximity
+	var err error
+
+	p.Address.Lat, err = parseCoordinate(lat, 90)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Address.Long, err = parseCoordinate(long, 180)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Distance, err = strconv.Atoi(distance)
+	if err != nil || p.Distance < 0 {
+		return nil, fmt.Errorf("%w: 'distance' must be a non negative integer", errInvalidFilter)
+	}
+
+	return &p, nil
+}
+
+func parseBoundingBox(v string) (*models.BoundingBox, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(v, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%w: 'bbox' must be 'min_long,min_lat,max_long,max_lat'", errInvalidFilter)
+	}
+
+	var cs [4]float32
+	for i, part := range parts {
+		limit := float32(180)
+		if i%2 == 1 {
+			limit = 90
+		}
+
+		var err error
+		cs[i], err = parseCoordinate(strings.TrimSpace(part), limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cs[1] > cs[3] {
+		return nil, fmt.Errorf("%w: the minimum latitude of 'bbox' is greater than the maximum", errInvalidFilter)
+	}
+
+	return &models.BoundingBox{MinLong: cs[0], MinLat: cs[1], MaxLong: cs[2], MaxLat: cs[3]}, nil
+}
+
+func parseCoordinate(v string, limit float32) (float32, error) {
+	c, err := strconv.ParseFloat(v, 32)
+	if err != nil || float32(c) < -limit || float32(c) > limit {
+		return 0, fmt.Errorf("%w: invalid coordinate '%s'", errInvalidFilter, v)
+	}
+	return float32(c), nil
+}
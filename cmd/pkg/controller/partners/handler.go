@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/export"
+	"match/cmd/pkg/logging"
 	"match/cmd/pkg/models"
 	"match/cmd/pkg/repository"
+	"match/cmd/pkg/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -23,8 +27,30 @@ type Database interface {
 
 	// GetPartnerById returns a partner by id.
 	GetPartnerById(ctx context.Context, id uint) (models.Partner, error)
+
+	// UpdatePartner updates the fields of a partner that are set and returns the updated partner.
+	UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error)
+
+	// ListPartners returns the partners that match the given filter, sorted and paginated as requested.
+	ListPartners(ctx context.Context, filter models.PartnerFilter) ([]models.Partner, error)
+
+	// CountPartners returns the number of partners that match the given filter.
+	CountPartners(ctx context.Context, filter models.PartnerFilter) (int64, error)
+
+	// StreamPartners calls fn for every partner, with its categories and materials, in ascending order of id.
+	StreamPartners(ctx context.Context, fn func(p models.Partner) error) error
 }
 
+const (
+	// exportFlushInterval is the number of partners written between flushes of the export response.
+	exportFlushInterval = 100
+
+	// exportWriteTimeout is the time the export response has to write the partners up to its next flush. The deadline
+	// is set when the export starts and extended at every flush, so an export isn't cut short by the server's write
+	// timeout however long it takes.
+	exportWriteTimeout = 30 * time.Second
+)
+
 // Handler handles '/partners' requests.
 type Handler struct {
 	db Database
@@ -42,16 +68,17 @@ func (h *Handler) GetMatches(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var reqBody models.MatchRequest
+	_, span := tracing.Start(ctx, "decode match request")
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	span.End()
 	if err != nil {
-		log.Printf("error decoding request body: %v\n", err)
+		logging.FromContext(ctx).Warn("error decoding request body", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		response.Write(w, []byte(response.ErrBadRequest))
 		return
 	}
 
-	var a models.Address
-	if reqBody.Address == a || len(reqBody.Materials) == 0 {
+	if !IsValidMatchRequest(reqBody) {
 		w.WriteHeader(http.StatusBadRequest)
 		response.Write(w, []byte(response.ErrBadRequest))
 		return
@@ -60,15 +87,15 @@ func (h *Handler) GetMatches(w http.ResponseWriter, r *http.Request) {
 	var matches []models.Partner
 	matches, err = h.db.GetMatches(ctx, reqBody.Materials, reqBody.Address.Lat, reqBody.Address.Long)
 	if err != nil {
-		log.Printf("error retrieving matches from the database: %v\n", err)
-		response.WriteInternalServerError(w)
+		logging.FromContext(ctx).Error("error retrieving matches from the database", "error", err)
+		writeDatabaseError(w, err)
 		return
 	}
 
 	var jsonBytes []byte
 	jsonBytes, err = json.Marshal(matches)
 	if err != nil {
-		log.Printf("error marshalling response: %v\n", err)
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
 		response.WriteInternalServerError(w)
 		return
 	}
@@ -98,15 +125,127 @@ func (h *Handler) GetPartnerById(w http.ResponseWriter, r *http.Request) {
 			response.Write(w, []byte(response.ErrNotFound))
 			return
 		}
-		log.Printf("error retrieving the partner from the database: %v\n", err)
+		logging.FromContext(ctx).Error("error retrieving the partner from the database", "error", err)
+		writeDatabaseError(w, err)
+		return
+	}
+
+	var jsonBytes []byte
+	jsonBytes, err = json.Marshal(p)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
 		response.WriteInternalServerError(w)
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	response.Write(w, jsonBytes)
+}
+
+// UpdatePartner updates the address and radius of a partner, the ones set in the request, and returns the updated
+// partner.
+func (h *Handler) UpdatePartner(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	var reqBody models.PartnerUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&reqBody)
+	if err != nil {
+		logging.FromContext(ctx).Warn("error decoding request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	if !isValidPartnerUpdate(reqBody) {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	p, err := h.db.UpdatePartner(ctx, uint(id), reqBody)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			response.Write(w, []byte(response.ErrNotFound))
+			return
+		}
+		logging.FromContext(ctx).Error("error updating the partner in the database", "error", err)
+		writeDatabaseError(w, err)
+		return
+	}
+
 	var jsonBytes []byte
 	jsonBytes, err = json.Marshal(p)
 	if err != nil {
-		log.Printf("error marshalling response: %v\n", err)
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Write(w, jsonBytes)
+}
+
+// isValidPartnerUpdate reports whether the update sets at least one field, to a valid value.
+func isValidPartnerUpdate(u models.PartnerUpdate) bool {
+	if u.Address == nil && u.Radius == nil {
+		return false
+	}
+	if u.Address != nil && (u.Address.Lat < -90 || u.Address.Lat > 90 || u.Address.Long < -180 || u.Address.Long > 180) {
+		return false
+	}
+	return u.Radius == nil || *u.Radius > 0
+}
+
+// GetBatchMatches returns the best matches for each of the customers' requests, in the same order as the requests.
+// A request that fails does not fail the others, instead its result has the error.
+func (h *Handler) GetBatchMatches(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	var reqBody []models.MatchRequest
+	_, span := tracing.Start(ctx, "decode match requests")
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	span.End()
+	if err != nil {
+		logging.FromContext(ctx).Warn("error decoding request body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	if len(reqBody) == 0 || len(reqBody) > MaxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	results := MatchBatch(ctx, h.db, reqBody, batchWorkers)
+
+	// a batch cut short, e.g. by the request's timeout, is retried as a whole rather than answered with partial
+	// results, which an idempotency key would then replay
+	if ctx.Err() != nil {
+		logging.FromContext(ctx).Warn("batch of match requests canceled", "requests", len(reqBody), "error", ctx.Err())
+		response.WriteServiceUnavailable(w)
+		return
+	}
+
+	var jsonBytes []byte
+	jsonBytes, err = json.Marshal(results)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
 		response.WriteInternalServerError(w)
 		return
 	}
@@ -114,3 +253,139 @@ func (h *Handler) GetPartnerById(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	response.Write(w, jsonBytes)
 }
+
+// ListPartners returns the partners that match the filters of the query, sorted and paginated as requested.
+func (h *Handler) ListPartners(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := parsePartnerFilter(r.URL.Query())
+	if err != nil {
+		logging.FromContext(ctx).Warn("error parsing the filter", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	ps, err := h.db.ListPartners(ctx, filter)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing the partners from the database", "error", err)
+		writeDatabaseError(w, err)
+		return
+	}
+
+	total, err := h.db.CountPartners(ctx, filter)
+	if err != nil {
+		logging.FromContext(ctx).Error("error counting the partners from the database", "error", err)
+		writeDatabaseError(w, err)
+		return
+	}
+
+	if ps == nil {
+		ps = []models.Partner{}
+	}
+
+	page := models.PartnerPage{
+		Partners: ps,
+		Total:    total,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	}
+
+	var jsonBytes []byte
+	jsonBytes, err = json.Marshal(page)
+	if err != nil {
+		logging.FromContext(ctx).Error("error marshalling response", "error", err)
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Write(w, jsonBytes)
+}
+
+// Export streams all partners, with their categories and materials, in the requested format.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+
+	// the errors are JSON, while the export has the content type of its format
+	f, err := export.ParseFormat(vars["format"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		response.Write(w, []byte(response.ErrBadRequest))
+		return
+	}
+
+	ew, err := export.NewWriter(w, f)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating export writer", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		response.WriteInternalServerError(w)
+		return
+	}
+
+	// the writer may not support flushing or deadlines, e.g. when the response is buffered, in which case the
+	// export is just written at once, within the server's write timeout
+	rc := http.NewResponseController(w)
+
+	var n int
+	var started bool
+	err = h.db.StreamPartners(ctx, func(p models.Partner) error {
+		if !started {
+			started = true
+			_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+			writeExportHeader(w, f)
+		}
+
+		err := ew.Write(p)
+		if err != nil {
+			return err
+		}
+
+		n++
+		if n%exportFlushInterval == 0 {
+			_ = rc.Flush()
+			_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		}
+		return nil
+	})
+
+	if err != nil {
+		// once the export started the status code was already sent, so the response is just cut short
+		logging.FromContext(ctx).Error("error exporting the partners", "exported", n, "error", err)
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			writeDatabaseError(w, err)
+		}
+		return
+	}
+
+	if !started {
+		writeExportHeader(w, f)
+	}
+
+	err = ew.Close()
+	if err != nil {
+		logging.FromContext(ctx).Error("error finishing the export", "error", err)
+	}
+}
+
+// writeDatabaseError writes the response for an error of the database, which is service unavailable when the
+// database can't be reached.
+func writeDatabaseError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrUnavailable) {
+		response.WriteServiceUnavailable(w)
+		return
+	}
+	response.WriteInternalServerError(w)
+}
+
+// writeExportHeader writes the headers and status code of a successful export response.
+func writeExportHeader(w http.ResponseWriter, f export.Format) {
+	w.Header().Set("Content-Type", f.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="partners.%s"`, f))
+	w.WriteHeader(http.StatusOK)
+}
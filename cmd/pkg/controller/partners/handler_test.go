@@ -2,16 +2,21 @@ package partners_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"match/cmd/pkg/controller/partners"
 	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/controller/response"
 	"match/cmd/pkg/models"
+	"match/cmd/pkg/openapi/openapitest"
 	"match/cmd/pkg/repository"
 
 	"github.com/golang/mock/gomock"
@@ -35,11 +40,9 @@ func TestGetMatches_InvalidBody(t *testing.T) {
 	db := mock.NewMockDatabase(ctrl)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(""))
 
-	handler.GetMatches(rr, req)
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
 
 	expectedCode := http.StatusBadRequest
 	if rr.Code != expectedCode {
@@ -57,8 +60,6 @@ func TestGetMatches_NoAddress(t *testing.T) {
 	db := mock.NewMockDatabase(ctrl)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	reqBody := `
 	{
 		"materials": [1, 2],
@@ -68,7 +69,7 @@ func TestGetMatches_NoAddress(t *testing.T) {
 	`
 	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(reqBody))
 
-	handler.GetMatches(rr, req)
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
 
 	expectedCode := http.StatusBadRequest
 	if rr.Code != expectedCode {
@@ -86,8 +87,6 @@ func TestGetMatches_NoMaterials(t *testing.T) {
 	db := mock.NewMockDatabase(ctrl)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	reqBody := `
 	{
 		"address": {
@@ -100,7 +99,7 @@ func TestGetMatches_NoMaterials(t *testing.T) {
 	`
 	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(reqBody))
 
-	handler.GetMatches(rr, req)
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
 
 	expectedCode := http.StatusBadRequest
 	if rr.Code != expectedCode {
@@ -122,11 +121,9 @@ func TestGetMatches_DatabaseFailure(t *testing.T) {
 		Return(nil, errors.New("some error"))
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(testMatchRequestBody))
 
-	handler.GetMatches(rr, req)
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
 
 	expectedCode := http.StatusInternalServerError
 	if rr.Code != expectedCode {
@@ -139,6 +136,30 @@ func TestGetMatches_DatabaseFailure(t *testing.T) {
 	}
 }
 
+func TestGetMatches_DatabaseUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 2}, float32(1.1), float32(1.2)).
+		Return(nil, repository.ErrUnavailable)
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(testMatchRequestBody))
+
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
+
+	expectedCode := http.StatusServiceUnavailable
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"service_unavailable"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
 func TestGetMatches_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	db := mock.NewMockDatabase(ctrl)
@@ -177,11 +198,9 @@ func TestGetMatches_Success(t *testing.T) {
 		Return([]models.Partner{p}, nil)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodPost, "/partners/match", strings.NewReader(testMatchRequestBody))
 
-	handler.GetMatches(rr, req)
+	rr := openapitest.Serve(t, "match", handler.GetMatches, req)
 
 	expectedCode := http.StatusOK
 	if rr.Code != expectedCode {
@@ -258,12 +277,9 @@ func TestGetPartnerById_PartnerNotFound(t *testing.T) {
 		Return(models.Partner{}, repository.ErrNotFound)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
-	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
-	handler.GetPartnerById(rr, req)
+	rr := openapitest.Serve(t, "get_partner", handler.GetPartnerById, req)
 
 	expectedCode := http.StatusNotFound
 	if rr.Code != expectedCode {
@@ -285,12 +301,9 @@ func TestGetPartnerById_DatabaseFailure(t *testing.T) {
 		Return(models.Partner{}, errors.New("some error"))
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
-	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
-	handler.GetPartnerById(rr, req)
+	rr := openapitest.Serve(t, "get_partner", handler.GetPartnerById, req)
 
 	expectedCode := http.StatusInternalServerError
 	if rr.Code != expectedCode {
@@ -341,12 +354,9 @@ func TestGetPartnerById_Success(t *testing.T) {
 		Return(p, nil)
 
 	handler := partners.NewHandler(db)
-	rr := httptest.NewRecorder()
-
 	req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
-	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
-	handler.GetPartnerById(rr, req)
+	rr := openapitest.Serve(t, "get_partner", handler.GetPartnerById, req)
 
 	expectedCode := http.StatusOK
 	if rr.Code != expectedCode {
@@ -388,3 +398,421 @@ func TestGetPartnerById_Success(t *testing.T) {
 		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
 	}
 }
+
+func TestExport_InvalidFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	handler := partners.NewHandler(db)
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/partners/export.xml", nil)
+	req = mux.SetURLVars(req, map[string]string{"format": "xml"})
+
+	handler.Export(rr, req)
+
+	expectedCode := http.StatusBadRequest
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"bad_request"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+
+	expectedContentType := "application/json"
+	if rr.Header().Get("Content-Type") != expectedContentType {
+		t.Errorf("content type mismatch: want %v got %v", expectedContentType, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestExport_DatabaseFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		Return(errors.New("some error"))
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodGet, "/partners/export.csv", nil)
+
+	rr := openapitest.Serve(t, "export_partners", handler.Export, req)
+
+	expectedCode := http.StatusInternalServerError
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"internal_server_error"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestExport_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	ps := []models.Partner{
+		{
+			ID: 1,
+			Materials: []models.Material{
+				{
+					ID:          1,
+					PartnerID:   1,
+					Description: "material 1",
+				},
+			},
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+			Radius: 100,
+			Rating: 5,
+		},
+		{
+			ID: 2,
+			Address: models.Address{
+				Lat:  2.1,
+				Long: 2.2,
+			},
+			Radius: 50,
+			Rating: 3,
+		},
+	}
+
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+			for _, p := range ps {
+				err := fn(p)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	handler := partners.NewHandler(db)
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/partners/export.jsonl", nil)
+	req = mux.SetURLVars(req, map[string]string{"format": "jsonl"})
+
+	handler.Export(rr, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedContentType := "application/jsonl"
+	if rr.Header().Get("Content-Type") != expectedContentType {
+		t.Errorf("content type mismatch: want %v got %v", expectedContentType, rr.Header().Get("Content-Type"))
+	}
+
+	expectedBody := `{"id":1,"categories":[],"materials":[{"id":1,"description":"material 1"}],"address":{"lat":1.1,"long":1.2},"radius":100,"rating":5}` + "\n" +
+		`{"id":2,"categories":[],"materials":[],"address":{"lat":2.1,"long":2.2},"radius":50,"rating":3}` + "\n"
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestExport_LongerThanWriteTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	// the export takes longer than the server's write timeout, but each batch of partners is flushed in time
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+			for i := 1; i <= 300; i++ {
+				time.Sleep(time.Millisecond)
+				err := fn(models.Partner{ID: uint(i)})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	handler := partners.NewHandler(db)
+
+	// the middlewares wrap the response in a StatusWriter
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Export(response.NewStatusWriter(w), mux.SetURLVars(r, map[string]string{"format": "jsonl"}))
+	}))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/partners/export.jsonl")
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expectedLines := 300
+	if n := strings.Count(string(body), "\n"); n != expectedLines {
+		t.Errorf("exported partners mismatch: want %v got %v", expectedLines, n)
+	}
+}
+
+func TestListPartners_InvalidFilter(t *testing.T) {
+	queries := []string{
+		"material=a",
+		"category=-1",
+		"min_rating=a",
+		"min_rating=4&max_rating=2",
+		"lat=1.1&long=1.2",
+		"lat=91&long=1.2&distance=10",
+		"lat=1.1&long=1.2&distance=-1",
+		"bbox=1,2,3",
+		"bbox=1,4,3,2",
+		"sort=name",
+		"sort=distance",
+		"limit=0",
+		"limit=101",
+		"offset=-1",
+	}
+
+	for _, q := range queries {
+		ctrl := gomock.NewController(t)
+		db := mock.NewMockDatabase(ctrl)
+
+		handler := partners.NewHandler(db)
+		rr := httptest.NewRecorder()
+
+		req := httptest.NewRequest(http.MethodGet, "/partners?"+q, nil)
+
+		handler.ListPartners(rr, req)
+
+		expectedCode := http.StatusBadRequest
+		if rr.Code != expectedCode {
+			t.Errorf("%s: status code mismatch: want %v got %v", q, expectedCode, rr.Code)
+		}
+
+		expectedBody := `{"error":"bad_request"}`
+		if rr.Body.String() != expectedBody {
+			t.Errorf("%s: body mismatch: want %v got %v", q, expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestListPartners_DatabaseFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		ListPartners(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("some error"))
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodGet, "/partners", nil)
+
+	rr := openapitest.Serve(t, "list_partners", handler.ListPartners, req)
+
+	expectedCode := http.StatusInternalServerError
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"internal_server_error"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestListPartners_Empty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	filter := models.PartnerFilter{
+		Sort:  models.SortByID,
+		Limit: 20,
+	}
+
+	db.EXPECT().
+		ListPartners(gomock.Any(), filter).
+		Return(nil, nil)
+
+	db.EXPECT().
+		CountPartners(gomock.Any(), filter).
+		Return(int64(0), nil)
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodGet, "/partners", nil)
+
+	rr := openapitest.Serve(t, "list_partners", handler.ListPartners, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"partners":[],"total":0,"limit":20,"offset":0}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestListPartners_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	minRating := 2
+	maxRating := 4
+	filter := models.PartnerFilter{
+		Materials:  []uint{1, 2},
+		Categories: []uint{3},
+		MinRating:  &minRating,
+		MaxRating:  &maxRating,
+		Near: &models.Proximity{
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+			Distance: 50,
+		},
+		Box: &models.BoundingBox{
+			MinLat:  -1,
+			MinLong: 170,
+			MaxLat:  2,
+			MaxLong: -170,
+		},
+		Sort:       models.SortByDistance,
+		Descending: true,
+		Limit:      5,
+		Offset:     10,
+	}
+
+	p := models.Partner{
+		ID:         3,
+		Categories: []models.Category{},
+		Materials:  []models.Material{},
+		Address: models.Address{
+			Lat:  1.1,
+			Long: 1.2,
+		},
+		Radius: 100,
+		Rating: 3,
+	}
+
+	db.EXPECT().
+		ListPartners(gomock.Any(), filter).
+		Return([]models.Partner{p}, nil)
+
+	db.EXPECT().
+		CountPartners(gomock.Any(), filter).
+		Return(int64(11), nil)
+
+	handler := partners.NewHandler(db)
+	q := "material=1&material=2&category=3&min_rating=2&max_rating=4&lat=1.1&long=1.2&distance=50" +
+		"&bbox=170,-1,-170,2&sort=-distance&limit=5&offset=10"
+	req := httptest.NewRequest(http.MethodGet, "/partners?"+q, nil)
+
+	rr := openapitest.Serve(t, "list_partners", handler.ListPartners, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"partners":[{"id":3,"categories":[],"materials":[],"address":{"lat":1.1,"long":1.2},"radius":100,"rating":3}],"total":11,"limit":5,"offset":10}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestUpdatePartner_InvalidBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	handler := partners.NewHandler(db)
+
+	for _, reqBody := range []string{
+		``,
+		`{}`,
+		`{"radius": 0}`,
+		`{"address": {"lat": 91, "long": 1}}`,
+		`{"rating": 5}`,
+	} {
+		rr := httptest.NewRecorder()
+
+		req := httptest.NewRequest(http.MethodPatch, "/partners/1", strings.NewReader(reqBody))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		handler.UpdatePartner(rr, req)
+
+		expectedCode := http.StatusBadRequest
+		if rr.Code != expectedCode {
+			t.Errorf("status code mismatch for '%s': want %v got %v", reqBody, expectedCode, rr.Code)
+		}
+	}
+}
+
+func TestUpdatePartner_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	radius := 300
+	db.EXPECT().
+		UpdatePartner(gomock.Any(), uint(9), models.PartnerUpdate{Radius: &radius}).
+		Return(models.Partner{}, repository.ErrNotFound)
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodPatch, "/partners/9", strings.NewReader(`{"radius": 300}`))
+
+	rr := openapitest.Serve(t, "update_partner", handler.UpdatePartner, req)
+
+	expectedCode := http.StatusNotFound
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"error":"not_found"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
+
+func TestUpdatePartner_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	u := models.PartnerUpdate{Address: &models.Address{Lat: 1.5, Long: 2.5}}
+	p := models.Partner{
+		ID:         1,
+		Categories: []models.Category{},
+		Materials:  []models.Material{},
+		Address:    models.Address{Lat: 1.5, Long: 2.5},
+		Radius:     200,
+		Rating:     4,
+	}
+
+	db.EXPECT().
+		UpdatePartner(gomock.Any(), uint(1), u).
+		Return(p, nil)
+
+	handler := partners.NewHandler(db)
+	req := httptest.NewRequest(http.MethodPatch, "/partners/1", strings.NewReader(`{"address": {"lat": 1.5, "long": 2.5}}`))
+
+	rr := openapitest.Serve(t, "update_partner", handler.UpdatePartner, req)
+
+	expectedCode := http.StatusOK
+	if rr.Code != expectedCode {
+		t.Errorf("status code mismatch: want %v got %v", expectedCode, rr.Code)
+	}
+
+	expectedBody := `{"id":1,"categories":[],"materials":[],"address":{"lat":1.5,"long":2.5},"radius":200,"rating":4}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+}
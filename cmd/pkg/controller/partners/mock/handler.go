@@ -35,6 +35,21 @@ func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
 	return m.recorder
 }
 
+// CountPartners mocks base method.
+func (m *MockDatabase) CountPartners(ctx context.Context, filter models.PartnerFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPartners", ctx, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPartners indicates an expected call of CountPartners.
+func (mr *MockDatabaseMockRecorder) CountPartners(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPartners", reflect.TypeOf((*MockDatabase)(nil).CountPartners), ctx, filter)
+}
+
 // GetMatches mocks base method.
 func (m *MockDatabase) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +79,47 @@ func (mr *MockDatabaseMockRecorder) GetPartnerById(ctx, id interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPartnerById", reflect.TypeOf((*MockDatabase)(nil).GetPartnerById), ctx, id)
 }
+
+// ListPartners mocks base method.
+func (m *MockDatabase) ListPartners(ctx context.Context, filter models.PartnerFilter) ([]models.Partner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPartners", ctx, filter)
+	ret0, _ := ret[0].([]models.Partner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPartners indicates an expected call of ListPartners.
+func (mr *MockDatabaseMockRecorder) ListPartners(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPartners", reflect.TypeOf((*MockDatabase)(nil).ListPartners), ctx, filter)
+}
+
+// StreamPartners mocks base method.
+func (m *MockDatabase) StreamPartners(ctx context.Context, fn func(models.Partner) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamPartners", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamPartners indicates an expected call of StreamPartners.
+func (mr *MockDatabaseMockRecorder) StreamPartners(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamPartners", reflect.TypeOf((*MockDatabase)(nil).StreamPartners), ctx, fn)
+}
+
+// UpdatePartner mocks base method.
+func (m *MockDatabase) UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePartner", ctx, id, u)
+	ret0, _ := ret[0].(models.Partner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePartner indicates an expected call of UpdatePartner.
+func (mr *MockDatabaseMockRecorder) UpdatePartner(ctx, id, u interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePartner", reflect.TypeOf((*MockDatabase)(nil).UpdatePartner), ctx, id, u)
+}
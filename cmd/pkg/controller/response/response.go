@@ -1,21 +1,31 @@
 package response
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 )
 
 const (
 	ErrNotFound            string = `{"error":"not_found"}`
 	ErrBadRequest          string = `{"error":"bad_request"}`
+	ErrUnauthorized        string = `{"error":"unauthorized"}`
+	ErrForbidden           string = `{"error":"forbidden"}`
+	ErrConflict            string = `{"error":"conflict"}`
+	ErrUnprocessable       string = `{"error":"unprocessable_entity"}`
+	ErrTooManyRequests     string = `{"error":"too_many_requests"}`
 	ErrInternalServerError string = `{"error":"internal_server_error"}`
+	ErrServiceUnavailable  string = `{"error":"service_unavailable"}`
 )
 
+// retryAfter is the number of seconds clients are asked to wait before retrying when the service is unavailable.
+const retryAfter = "5"
+
 // Write writes byte array to http.ResponseWriter.
 func Write(w http.ResponseWriter, b []byte) {
 	_, err := w.Write(b)
 	if err != nil {
-		log.Printf("error writing byte array: %v\n", err)
+		slog.Error("error writing the response", "error", err)
 	}
 }
 
@@ -24,3 +34,76 @@ func WriteInternalServerError(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusInternalServerError)
 	Write(w, []byte(ErrInternalServerError))
 }
+
+// WriteUnauthorized writes unauthorized response to http.ResponseWriter, asking for the credentials of the given
+// scheme, e.g. 'Bearer'.
+func WriteUnauthorized(w http.ResponseWriter, scheme string) {
+	w.Header().Set("WWW-Authenticate", scheme)
+	w.WriteHeader(http.StatusUnauthorized)
+	Write(w, []byte(ErrUnauthorized))
+}
+
+// WriteForbidden writes forbidden response to http.ResponseWriter.
+func WriteForbidden(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	Write(w, []byte(ErrForbidden))
+}
+
+// WriteTooManyRequests writes too many requests response to http.ResponseWriter, asking clients to wait for the
+// given number of seconds before retrying.
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter int) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	Write(w, []byte(ErrTooManyRequests))
+}
+
+// WriteServiceUnavailable writes service unavailable response to http.ResponseWriter.
+func WriteServiceUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", retryAfter)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	Write(w, []byte(ErrServiceUnavailable))
+}
+
+// StatusWriter is a http.ResponseWriter that records the status code of the response, e.g. for the middlewares that
+// report it.
+type StatusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+// NewStatusWriter creates a new StatusWriter that writes to w.
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, code: http.StatusOK}
+}
+
+// Code returns the status code of the response, which is 200 OK until another one is written.
+func (w *StatusWriter) Code() int {
+	return w.code
+}
+
+func (w *StatusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response, if the underlying writer can, e.g. while exporting the partners.
+func (w *StatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, so a http.ResponseController can reach it, e.g. to extend the write
+// deadline of the partners' export.
+func (w *StatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
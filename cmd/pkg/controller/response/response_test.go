@@ -1,6 +1,7 @@
 package response_test
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -28,3 +29,95 @@ func TestWriteInternalServerError(t *testing.T) {
 		t.Errorf("returned unexpected body: want %v got %v", b, rr.Body.String())
 	}
 }
+
+func TestWriteServiceUnavailable(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	response.WriteServiceUnavailable(rr)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	if rr.Header().Get("Retry-After") == "" {
+		t.Errorf("missing Retry-After header")
+	}
+
+	b := `{"error":"service_unavailable"}`
+	if rr.Body.String() != b {
+		t.Errorf("returned unexpected body: want %v got %v", b, rr.Body.String())
+	}
+}
+
+func TestWriteUnauthorized(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	response.WriteUnauthorized(rr, "Bearer")
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("WWW-Authenticate header mismatch: want Bearer got %v", rr.Header().Get("WWW-Authenticate"))
+	}
+
+	b := `{"error":"unauthorized"}`
+	if rr.Body.String() != b {
+		t.Errorf("returned unexpected body: want %v got %v", b, rr.Body.String())
+	}
+}
+
+func TestWriteForbidden(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	response.WriteForbidden(rr)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusForbidden, rr.Code)
+	}
+
+	b := `{"error":"forbidden"}`
+	if rr.Body.String() != b {
+		t.Errorf("returned unexpected body: want %v got %v", b, rr.Body.String())
+	}
+}
+
+func TestWriteTooManyRequests(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	response.WriteTooManyRequests(rr, 3)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if rr.Header().Get("Retry-After") != "3" {
+		t.Errorf("Retry-After header mismatch: want 3 got %v", rr.Header().Get("Retry-After"))
+	}
+
+	b := `{"error":"too_many_requests"}`
+	if rr.Body.String() != b {
+		t.Errorf("returned unexpected body: want %v got %v", b, rr.Body.String())
+	}
+}
+
+func TestStatusWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	sw := response.NewStatusWriter(rr)
+
+	if sw.Code() != http.StatusOK {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusOK, sw.Code())
+	}
+
+	sw.WriteHeader(http.StatusNotFound)
+	sw.WriteHeader(http.StatusInternalServerError)
+
+	if sw.Code() != http.StatusNotFound {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusNotFound, sw.Code())
+	}
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"match/cmd/pkg/models"
+)
+
+// listSeparator separates the values of a list inside a single CSV field.
+const listSeparator = ";"
+
+var csvHeader = []string{
+	"id",
+	"lat",
+	"long",
+	"radius",
+	"rating",
+	"category_ids",
+	"categories",
+	"material_ids",
+	"materials",
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(p models.Partner) error {
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	var cIds, cDescriptions []string
+	for _, c := range p.Categories {
+		cIds = append(cIds, strconv.FormatUint(uint64(c.ID), 10))
+		cDescriptions = append(cDescriptions, c.Description)
+	}
+
+	var mIds, mDescriptions []string
+	for _, m := range p.Materials {
+		mIds = append(mIds, strconv.FormatUint(uint64(m.ID), 10))
+		mDescriptions = append(mDescriptions, m.Description)
+	}
+
+	return cw.w.Write([]string{
+		strconv.FormatUint(uint64(p.ID), 10),
+		strconv.FormatFloat(float64(p.Address.Lat), 'f', -1, 32),
+		strconv.FormatFloat(float64(p.Address.Long), 'f', -1, 32),
+		strconv.Itoa(p.Radius),
+		strconv.Itoa(p.Rating),
+		strings.Join(cIds, listSeparator),
+		strings.Join(cDescriptions, listSeparator),
+		strings.Join(mIds, listSeparator),
+		strings.Join(mDescriptions, listSeparator),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+	cw.headerWritten = true
+	return cw.w.Write(csvHeader)
+}
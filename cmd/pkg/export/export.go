@@ -0,0 +1,82 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"match/cmd/pkg/models"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+// Format represents an export format.
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatGeoJSON Format = "geojson"
+)
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatJSONL, FormatGeoJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownFormat, s)
+	}
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/jsonl"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Writer writes partners, one at a time, to an underlying io.Writer.
+// Nothing is written to the underlying io.Writer until the first partner is written or the Writer is closed.
+type Writer interface {
+	// Write writes a partner.
+	Write(p models.Partner) error
+
+	// Close writes any trailing data required by the format. It does not close the underlying io.Writer.
+	Close() error
+}
+
+// NewWriter creates a new Writer for the given format.
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatGeoJSON:
+		return newGeoJSONWriter(w), nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownFormat, f)
+	}
+}
+
+// withLists returns the partner with empty, instead of nil, categories and materials, so every format encodes them
+// as lists.
+func withLists(p models.Partner) models.Partner {
+	if p.Categories == nil {
+		p.Categories = []models.Category{}
+	}
+	if p.Materials == nil {
+		p.Materials = []models.Material{}
+	}
+	return p
+}
@@ -0,0 +1,226 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"match/cmd/pkg/export"
+	"match/cmd/pkg/models"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testPartners = []models.Partner{
+	{
+		ID: 1,
+		Categories: []models.Category{
+			{
+				ID:          1,
+				PartnerID:   1,
+				Description: "Flooring materials",
+			},
+		},
+		Materials: []models.Material{
+			{
+				ID:          1,
+				PartnerID:   1,
+				Description: "Wood",
+			},
+			{
+				ID:          2,
+				PartnerID:   1,
+				Description: "Carpet",
+			},
+		},
+		Address: models.Address{
+			Lat:  1.1,
+			Long: 1.2,
+		},
+		Radius: 200,
+		Rating: 4,
+	},
+	{
+		ID: 2,
+		Address: models.Address{
+			Lat:  -3.5,
+			Long: 2,
+		},
+		Radius: 10,
+		Rating: 1,
+	},
+}
+
+func writeAll(t *testing.T, f export.Format, ps []models.Partner) string {
+	t.Helper()
+
+	buffer := new(bytes.Buffer)
+
+	w, err := export.NewWriter(buffer, f)
+	if err != nil {
+		t.Fatalf("error creating writer: '%s'", err)
+	}
+
+	for _, p := range ps {
+		err = w.Write(p)
+		if err != nil {
+			t.Fatalf("error writing partner: '%s'", err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: '%s'", err)
+	}
+
+	return buffer.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"csv", "jsonl", "geojson"} {
+		f, err := export.ParseFormat(s)
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+		if string(f) != s {
+			t.Errorf("format mismatch: want %v got %v", s, f)
+		}
+	}
+}
+
+func TestParseFormat_Unknown(t *testing.T) {
+	_, err := export.ParseFormat("xml")
+
+	if !errors.Is(err, export.ErrUnknownFormat) {
+		t.Errorf("error mismatch: want '%s' got '%s'", export.ErrUnknownFormat, err)
+	}
+}
+
+func TestWriter_CSV(t *testing.T) {
+	got := writeAll(t, export.FormatCSV, testPartners)
+
+	expected := strings.Join([]string{
+		"id,lat,long,radius,rating,category_ids,categories,material_ids,materials",
+		"1,1.1,1.2,200,4,1,Flooring materials,1;2,Wood;Carpet",
+		"2,-3.5,2,10,1,,,,",
+		"",
+	}, "\n")
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("csv mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriter_CSVEmpty(t *testing.T) {
+	got := writeAll(t, export.FormatCSV, nil)
+
+	expected := "id,lat,long,radius,rating,category_ids,categories,material_ids,materials\n"
+	if got != expected {
+		t.Errorf("csv mismatch: want %v got %v", expected, got)
+	}
+}
+
+func TestWriter_JSONL(t *testing.T) {
+	got := writeAll(t, export.FormatJSONL, testPartners)
+
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != len(testPartners) {
+		t.Fatalf("number of lines mismatch: want %v got %v", len(testPartners), len(lines))
+	}
+
+	expected := `{"id":1,"categories":[{"id":1,"description":"Flooring materials"}],"materials":[{"id":1,"description":"Wood"},{"id":2,"description":"Carpet"}],"address":{"lat":1.1,"long":1.2},"radius":200,"rating":4}`
+	if lines[0] != expected {
+		t.Errorf("line mismatch: want %v got %v", expected, lines[0])
+	}
+
+	// the partners without categories or materials have empty lists, like in GeoJSON
+	expected = `{"id":2,"categories":[],"materials":[],"address":{"lat":-3.5,"long":2},"radius":10,"rating":1}`
+	if lines[1] != expected {
+		t.Errorf("line mismatch: want %v got %v", expected, lines[1])
+	}
+}
+
+func TestWriter_GeoJSON(t *testing.T) {
+	got := writeAll(t, export.FormatGeoJSON, testPartners)
+
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float32 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				ID        uint              `json:"id"`
+				Radius    int               `json:"radius"`
+				Materials []models.Material `json:"materials"`
+				Coverage  struct {
+					Type        string         `json:"type"`
+					Coordinates [][][2]float64 `json:"coordinates"`
+				} `json:"coverage"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+
+	err := json.Unmarshal([]byte(got), &fc)
+	if err != nil {
+		t.Fatalf("error decoding feature collection: '%s'", err)
+	}
+
+	if fc.Type != "FeatureCollection" {
+		t.Errorf("type mismatch: want FeatureCollection got %v", fc.Type)
+	}
+
+	if len(fc.Features) != len(testPartners) {
+		t.Fatalf("number of features mismatch: want %v got %v", len(testPartners), len(fc.Features))
+	}
+
+	f := fc.Features[0]
+	if f.Geometry.Type != "Point" {
+		t.Errorf("geometry type mismatch: want Point got %v", f.Geometry.Type)
+	}
+
+	if diff := cmp.Diff([]float32{1.2, 1.1}, f.Geometry.Coordinates); diff != "" {
+		t.Errorf("coordinates mismatch (-want +got):\n%s", diff)
+	}
+
+	if f.Properties.ID != 1 || f.Properties.Radius != 200 || len(f.Properties.Materials) != 2 {
+		t.Errorf("properties mismatch: got %+v", f.Properties)
+	}
+
+	if f.Properties.Coverage.Type != "Polygon" {
+		t.Errorf("coverage type mismatch: want Polygon got %v", f.Properties.Coverage.Type)
+	}
+
+	ring := f.Properties.Coverage.Coordinates[0]
+	if ring[0] != ring[len(ring)-1] {
+		t.Errorf("coverage ring is not closed: first %v last %v", ring[0], ring[len(ring)-1])
+	}
+}
+
+func TestWriter_GeoJSONEmpty(t *testing.T) {
+	got := writeAll(t, export.FormatGeoJSON, nil)
+
+	expected := `{"type":"FeatureCollection","features":[]}`
+	if got != expected {
+		t.Errorf("geojson mismatch: want %v got %v", expected, got)
+	}
+}
+
+func TestWriter_NothingWrittenBeforeFirstPartner(t *testing.T) {
+	for _, f := range []export.Format{export.FormatCSV, export.FormatJSONL, export.FormatGeoJSON} {
+		buffer := new(bytes.Buffer)
+
+		_, err := export.NewWriter(buffer, f)
+		if err != nil {
+			t.Fatalf("error creating writer: '%s'", err)
+		}
+
+		if buffer.Len() != 0 {
+			t.Errorf("%s: unexpected output before the first partner: %v", f, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"match/cmd/pkg/geo"
+	"match/cmd/pkg/models"
+)
+
+// coverageVertices is the number of vertices used to approximate the partner's coverage circle.
+const coverageVertices = 32
+
+type feature struct {
+	Type       string            `json:"type"`
+	Geometry   geometry          `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+type featureProperties struct {
+	ID         uint              `json:"id"`
+	Radius     int               `json:"radius"`
+	Rating     int               `json:"rating"`
+	Categories []models.Category `json:"categories"`
+	Materials  []models.Material `json:"materials"`
+	Coverage   geometry          `json:"coverage"`
+}
+
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func newGeoJSONWriter(w io.Writer) *geoJSONWriter {
+	return &geoJSONWriter{w: w}
+}
+
+func (gw *geoJSONWriter) Write(p models.Partner) error {
+	b, err := json.Marshal(newFeature(p))
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if !gw.started {
+		gw.started = true
+		sep = `{"type":"FeatureCollection","features":[`
+	}
+
+	_, err = io.WriteString(gw.w, sep)
+	if err != nil {
+		return err
+	}
+
+	_, err = gw.w.Write(b)
+	return err
+}
+
+func (gw *geoJSONWriter) Close() error {
+	if !gw.started {
+		_, err := io.WriteString(gw.w, `{"type":"FeatureCollection","features":[]}`)
+		return err
+	}
+
+	_, err := io.WriteString(gw.w, "]}")
+	return err
+}
+
+// newFeature creates a GeoJSON feature with the partner's location as geometry.
+// The partner's coverage, i.e. its radius around the location, is added to the properties as a polygon.
+func newFeature(p models.Partner) feature {
+	lat := float64(p.Address.Lat)
+	long := float64(p.Address.Long)
+
+	// GeoJSON positions are (longitude, latitude) pairs
+	var ring [][2]float64
+	for _, c := range geo.Circle(lat, long, float64(p.Radius), coverageVertices) {
+		ring = append(ring, [2]float64{c[1], c[0]})
+	}
+
+	p = withLists(p)
+
+	return feature{
+		Type: "Feature",
+		Geometry: geometry{
+			Type:        "Point",
+			Coordinates: [2]float32{p.Address.Long, p.Address.Lat},
+		},
+		Properties: featureProperties{
+			ID:         p.ID,
+			Radius:     p.Radius,
+			Rating:     p.Rating,
+			Categories: p.Categories,
+			Materials:  p.Materials,
+			Coverage: geometry{
+				Type:        "Polygon",
+				Coordinates: [][][2]float64{ring},
+			},
+		},
+	}
+}
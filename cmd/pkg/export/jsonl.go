@@ -0,0 +1,25 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"match/cmd/pkg/models"
+)
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{enc: json.NewEncoder(w)}
+}
+
+func (jw *jsonlWriter) Write(p models.Partner) error {
+	// json.Encoder terminates each value with a newline
+	return jw.enc.Encode(withLists(p))
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package geo
+
+import "math"
+
+// EarthRadius is the earth radius, in kilometers, used by the 'haversine' function of the database.
+// It is kept equal to the database's value so that distances computed here match the ones used for matching.
+const EarthRadius float64 = 6335
+
+// Distance returns the distance, in kilometers, between two points using the same formula as the database's
+// 'haversine' function, which approximates the arc sine of the Haversine Formula by its argument.
+func Distance(lat1, long1, lat2, long2 float64) float64 {
+	dLat := radians(lat2) - radians(lat1)
+	dLong := radians(long2) - radians(long1)
+
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLong/2), 2)
+
+	return 2 * EarthRadius * math.Sqrt(a)
+}
+
+// Destination returns the point reached when travelling the given distance, in kilometers, from the given point
+// with the given bearing, in degrees clockwise from north.
+func Destination(lat, long, distance, bearing float64) (float64, float64) {
+	d := distance / EarthRadius
+	b := radians(bearing)
+	lat1 := radians(lat)
+	long1 := radians(long)
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(b))
+	long2 := long1 + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	return degrees(lat2), normalizeLongitude(degrees(long2))
+}
+
+// Circle returns a closed ring of n points, as (lat, long) pairs, approximating the circle with the given
+// center and radius in kilometers.
+func Circle(lat, long, radius float64, n int) [][2]float64 {
+	ring := make([][2]float64, 0, n+1)
+	for i := 0; i < n; i++ {
+		pLat, pLong := Destination(lat, long, radius, float64(i)*360/float64(n))
+		ring = append(ring, [2]float64{pLat, pLong})
+	}
+	return append(ring, ring[0])
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
+
+func normalizeLongitude(long float64) float64 {
+	return math.Mod(long+540, 360) - 180
+}
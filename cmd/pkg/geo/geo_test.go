@@ -0,0 +1,56 @@
+package geo_test
+
+import (
+	"math"
+	"testing"
+
+	"match/cmd/pkg/geo"
+)
+
+func TestDistance(t *testing.T) {
+	d := geo.Distance(1.1, 1.1, 1.2, 1.2)
+
+	expected := 15.635
+	if math.Abs(d-expected) > 0.001 {
+		t.Errorf("distance mismatch: want %v got %v", expected, d)
+	}
+}
+
+func TestDistance_SamePoint(t *testing.T) {
+	d := geo.Distance(38.7, -9.1, 38.7, -9.1)
+
+	if d != 0 {
+		t.Errorf("distance mismatch: want 0 got %v", d)
+	}
+}
+
+func TestCircle(t *testing.T) {
+	lat, long, radius := 38.7, -9.1, 200.0
+
+	ring := geo.Circle(lat, long, radius, 16)
+
+	if len(ring) != 17 {
+		t.Fatalf("ring length mismatch: want 17 got %v", len(ring))
+	}
+
+	if ring[0] != ring[len(ring)-1] {
+		t.Errorf("ring is not closed: first %v last %v", ring[0], ring[len(ring)-1])
+	}
+
+	for _, p := range ring {
+		d := geo.Distance(lat, long, p[0], p[1])
+		if math.Abs(d-radius) > radius*0.001 {
+			t.Errorf("distance to center mismatch: want %v got %v", radius, d)
+		}
+	}
+}
+
+func TestCircle_AntiMeridian(t *testing.T) {
+	ring := geo.Circle(0, 179.9, 100, 8)
+
+	for _, p := range ring {
+		if p[1] < -180 || p[1] > 180 {
+			t.Errorf("longitude out of range: %v", p[1])
+		}
+	}
+}
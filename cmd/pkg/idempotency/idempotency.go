@@ -0,0 +1,250 @@
+// Package idempotency makes the POST requests with an 'Idempotency-Key' header safe to retry: the response of the
+// first request with a key is stored and replayed to the retries, for the same client, with the same key.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+const (
+	// Header is the header with the client's idempotency key, see
+	// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/.
+	Header = "Idempotency-Key"
+	// HeaderReplayed is set on the responses replayed to a retry.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// maxKeyLength is the maximum length of an idempotency key.
+	maxKeyLength = 255
+
+	defaultTTL         = 24 * time.Hour
+	defaultLockTimeout = 2 * time.Minute
+	defaultMaxBodySize = 64 << 20
+)
+
+// replayedHeaders are the headers of a response that are stored with it, and replayed.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Store stores the requests made with an idempotency key, e.g. repository.Database.
+type Store interface {
+	// BeginIdempotentRequest records that the request of the client's key is in progress, for up to the lock
+	// duration, and returns true, unless the key has a request that did not expire yet, which it returns instead.
+	BeginIdempotentRequest(ctx context.Context, client, key, fingerprint string, lock time.Duration) (models.IdempotencyKey, bool, error)
+
+	// CompleteIdempotentRequest records the response of the request of the client's key, kept for the given time.
+	CompleteIdempotentRequest(ctx context.Context, client, key string, code int, headers map[string]string, body []byte, ttl time.Duration) error
+
+	// ReleaseIdempotentRequest deletes the request in progress of the client's key, so it can be retried.
+	ReleaseIdempotentRequest(ctx context.Context, client, key string) error
+
+	// DeleteExpiredIdempotencyKeys deletes the expired keys, and returns how many were deleted.
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// Options configures the idempotency Keys.
+type Options struct {
+	// TTL is the time the response of a request is replayed to its retries for. Defaults to 24 hours.
+	TTL time.Duration
+	// LockTimeout is the time after which a request still in progress is considered abandoned, e.g. because its
+	// instance crashed, so a retry is served again. It must be longer than any request. Defaults to 2 minutes.
+	LockTimeout time.Duration
+	// MaxBodySize is the maximum size, in bytes, of the body of the requests with a key. Defaults to 64 MiB.
+	MaxBodySize int64
+}
+
+// Keys serves the requests with an idempotency key at most once per client and key.
+type Keys struct {
+	store Store
+	opts  Options
+}
+
+// New creates new Keys that store the requests in the given store.
+func New(store Store, opts Options) *Keys {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultTTL
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = defaultLockTimeout
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxBodySize
+	}
+	return &Keys{store: store, opts: opts}
+}
+
+// Wrap returns a handler that serves the requests with an idempotency key with next only once per client and key,
+// and replays the stored response to the retries. The requests without a key are served as they are.
+//
+// A retry of a request still in progress is answered with 409 Conflict, and a request that reuses a key with another
+// method, path or body with 422 Unprocessable Entity. The responses with a 5xx status code are not stored, so the
+// request can be retried. It must be wrapped by auth.Authenticator.Require, since the keys are per client.
+func (k *Keys) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		key := r.Header.Get(Header)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		// the keys are scoped by the client's unique identity, since two API keys, or a key and a JWT, may have the
+		// same name
+		p, ok := auth.FromContext(ctx)
+		if !ok || p.ID == "" {
+			next(w, r)
+			return
+		}
+		client := p.ID
+
+		if len(key) > maxKeyLength {
+			logging.FromContext(ctx).Warn("invalid idempotency key", "length", len(key))
+			writeError(w, http.StatusBadRequest, response.ErrBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, k.opts.MaxBodySize))
+		if err != nil {
+			logging.FromContext(ctx).Warn("error reading request body", "error", err)
+			writeError(w, http.StatusBadRequest, response.ErrBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := fingerprint(r, body)
+
+		stored, started, err := k.store.BeginIdempotentRequest(ctx, client, key, fingerprint, k.opts.LockTimeout)
+		if err != nil {
+			logging.FromContext(ctx).Error("error beginning the idempotent request", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			if errors.Is(err, repository.ErrUnavailable) {
+				response.WriteServiceUnavailable(w)
+				return
+			}
+			response.WriteInternalServerError(w)
+			return
+		}
+
+		if !started {
+			replay(ctx, w, stored, fingerprint)
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w, code: http.StatusOK}
+		next(cw, r)
+
+		// the response is stored even if the client is gone, since it may retry
+		ctx = context.WithoutCancel(ctx)
+
+		if cw.code >= http.StatusInternalServerError {
+			err = k.store.ReleaseIdempotentRequest(ctx, client, key)
+			if err != nil {
+				logging.FromContext(ctx).Error("error releasing the idempotent request", "error", err)
+			}
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, h := range replayedHeaders {
+			if v := w.Header().Get(h); v != "" {
+				headers[h] = v
+			}
+		}
+
+		err = k.store.CompleteIdempotentRequest(ctx, client, key, cw.code, headers, cw.body.Bytes(), k.opts.TTL)
+		if err != nil {
+			// the retries are answered with a conflict until the lock expires, and are then served again
+			logging.FromContext(ctx).Error("error completing the idempotent request", "error", err)
+		}
+	}
+}
+
+// Run deletes the expired idempotency keys every interval until the context is done.
+func (k *Keys) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		_, err := k.store.DeleteExpiredIdempotencyKeys(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("error deleting the expired idempotency keys", "error", err)
+		}
+	}
+}
+
+// replay answers a retry with the stored response of its request, if it is done and the retry is the same request.
+func replay(ctx context.Context, w http.ResponseWriter, stored models.IdempotencyKey, fingerprint string) {
+	if stored.Fingerprint != fingerprint {
+		logging.FromContext(ctx).Warn("idempotency key reused for another request")
+		writeError(w, http.StatusUnprocessableEntity, response.ErrUnprocessable)
+		return
+	}
+
+	if stored.StatusCode == nil {
+		logging.FromContext(ctx).Warn("idempotent request still in progress")
+		writeError(w, http.StatusConflict, response.ErrConflict)
+		return
+	}
+
+	for h, v := range stored.Headers {
+		w.Header().Set(h, v)
+	}
+	w.Header().Set(HeaderReplayed, strconv.FormatBool(true))
+	w.WriteHeader(*stored.StatusCode)
+	response.Write(w, stored.Body)
+}
+
+// fingerprint returns the hash of the request's method, path and body, which tells apart the requests of a key.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeError(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	response.Write(w, []byte(body))
+}
+
+// captureWriter is a http.ResponseWriter that records the status code and the body of the response, to store them.
+type captureWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,291 @@
+package idempotency_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/idempotency"
+	"match/cmd/pkg/idempotency/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/golang/mock/gomock"
+)
+
+const client = "key:1"
+
+var opts = idempotency.Options{TTL: time.Hour, LockTimeout: time.Minute}
+
+// created answers with a created job, counting its calls.
+func created(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/jobs/1")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = fmt.Fprintf(w, `{"id":"1","requests":%s}`, body)
+	}
+}
+
+// acme is the principal of the API key 1, named acme.
+var acme = auth.NewPrincipal(models.APIKey{ID: 1, Name: "acme", Role: "client"})
+
+func serve(h http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	return serveAs(h, acme, key, body)
+}
+
+func serveAs(h http.HandlerFunc, p auth.Principal, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestWrap_FirstRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		Return(models.IdempotencyKey{}, true, nil)
+	store.EXPECT().
+		CompleteIdempotentRequest(gomock.Any(), client, "key-1", http.StatusAccepted,
+			map[string]string{"Content-Type": "application/json", "Location": "/jobs/1"}, []byte(`{"id":"1","requests":[]}`), time.Hour).
+		Return(nil)
+
+	var calls int
+	rr := serve(idempotency.New(store, opts).Wrap(created(&calls)), "key-1", "[]")
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusAccepted, rr.Code)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls mismatch: want 1 got %d", calls)
+	}
+
+	if rr.Header().Get(idempotency.HeaderReplayed) != "" {
+		t.Errorf("replayed header mismatch: want '' got '%s'", rr.Header().Get(idempotency.HeaderReplayed))
+	}
+}
+
+func TestWrap_Replayed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	var fingerprint string
+	code := http.StatusAccepted
+
+	// the first request is stored with its fingerprint, which the retry matches
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		DoAndReturn(func(_ context.Context, _, _, f string, _ time.Duration) (models.IdempotencyKey, bool, error) {
+			fingerprint = f
+			return models.IdempotencyKey{}, true, nil
+		})
+	store.EXPECT().
+		CompleteIdempotentRequest(gomock.Any(), client, "key-1", http.StatusAccepted, gomock.Any(), gomock.Any(), time.Hour).
+		Return(nil)
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		DoAndReturn(func(_ context.Context, _, _, f string, _ time.Duration) (models.IdempotencyKey, bool, error) {
+			return models.IdempotencyKey{
+				Client:      client,
+				Key:         "key-1",
+				Fingerprint: fingerprint,
+				StatusCode:  &code,
+				Headers:     map[string]string{"Content-Type": "application/json", "Location": "/jobs/1"},
+				Body:        []byte(`{"id":"1","requests":[]}`),
+			}, false, nil
+		})
+
+	var calls int
+	h := idempotency.New(store, opts).Wrap(created(&calls))
+
+	first := serve(h, "key-1", "[]")
+	retry := serve(h, "key-1", "[]")
+
+	if calls != 1 {
+		t.Errorf("calls mismatch: want 1 got %d", calls)
+	}
+
+	if retry.Code != http.StatusAccepted {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusAccepted, retry.Code)
+	}
+
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("body mismatch: want %s got %s", first.Body.String(), retry.Body.String())
+	}
+
+	if retry.Header().Get("Location") != "/jobs/1" {
+		t.Errorf("location mismatch: want '/jobs/1' got '%s'", retry.Header().Get("Location"))
+	}
+
+	if retry.Header().Get(idempotency.HeaderReplayed) != "true" {
+		t.Errorf("replayed header mismatch: want 'true' got '%s'", retry.Header().Get(idempotency.HeaderReplayed))
+	}
+}
+
+func TestWrap_SameNamedClients(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	// the second key has the name of the first, but the first's response is not replayed to it
+	other := auth.NewPrincipal(models.APIKey{ID: 2, Name: "acme", Role: "client"})
+
+	for _, c := range []string{client, "key:2"} {
+		store.EXPECT().
+			BeginIdempotentRequest(gomock.Any(), c, "key-1", gomock.Any(), time.Minute).
+			Return(models.IdempotencyKey{}, true, nil)
+		store.EXPECT().
+			CompleteIdempotentRequest(gomock.Any(), c, "key-1", http.StatusAccepted, gomock.Any(), gomock.Any(), time.Hour).
+			Return(nil)
+	}
+
+	var calls int
+	h := idempotency.New(store, opts).Wrap(created(&calls))
+
+	for _, p := range []auth.Principal{acme, other} {
+		rr := serveAs(h, p, "key-1", "[]")
+
+		if rr.Header().Get(idempotency.HeaderReplayed) != "" {
+			t.Errorf("%s replayed header mismatch: want '' got '%s'", p.ID, rr.Header().Get(idempotency.HeaderReplayed))
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("calls mismatch: want 2 got %d", calls)
+	}
+}
+
+func TestWrap_InProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		DoAndReturn(func(_ context.Context, _, _, f string, _ time.Duration) (models.IdempotencyKey, bool, error) {
+			return models.IdempotencyKey{Client: client, Key: "key-1", Fingerprint: f}, false, nil
+		})
+
+	var calls int
+	rr := serve(idempotency.New(store, opts).Wrap(created(&calls)), "key-1", "[]")
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusConflict, rr.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("calls mismatch: want 0 got %d", calls)
+	}
+
+	if rr.Body.String() != response.ErrConflict {
+		t.Errorf("body mismatch: want %s got %s", response.ErrConflict, rr.Body.String())
+	}
+}
+
+func TestWrap_OtherRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	code := http.StatusAccepted
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		Return(models.IdempotencyKey{Client: client, Key: "key-1", Fingerprint: "other", StatusCode: &code}, false, nil)
+
+	var calls int
+	rr := serve(idempotency.New(store, opts).Wrap(created(&calls)), "key-1", "[]")
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("calls mismatch: want 0 got %d", calls)
+	}
+}
+
+func TestWrap_ServerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	// the failed request is released, so it can be retried
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		Return(models.IdempotencyKey{}, true, nil)
+	store.EXPECT().
+		ReleaseIdempotentRequest(gomock.Any(), client, "key-1").
+		Return(nil)
+
+	h := idempotency.New(store, opts).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		response.WriteServiceUnavailable(w)
+	})
+
+	rr := serve(h, "key-1", "[]")
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestWrap_StoreUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	store.EXPECT().
+		BeginIdempotentRequest(gomock.Any(), client, "key-1", gomock.Any(), time.Minute).
+		Return(models.IdempotencyKey{}, false, fmt.Errorf("error: %w", repository.ErrUnavailable))
+
+	var calls int
+	rr := serve(idempotency.New(store, opts).Wrap(created(&calls)), "key-1", "[]")
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("calls mismatch: want 0 got %d", calls)
+	}
+}
+
+func TestWrap_WithoutKey(t *testing.T) {
+	// the requests without a key are not stored
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	var calls int
+	h := idempotency.New(store, opts).Wrap(created(&calls))
+
+	serve(h, "", "[]")
+	serve(h, "", "[]")
+
+	if calls != 2 {
+		t.Errorf("calls mismatch: want 2 got %d", calls)
+	}
+}
+
+func TestWrap_InvalidKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+
+	var calls int
+	rr := serve(idempotency.New(store, opts).Wrap(created(&calls)), strings.Repeat("k", 256), "[]")
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,3 @@
+//go:generate mockgen -package=mock -source=../idempotency.go -destination=./idempotency.go
+
+package mock
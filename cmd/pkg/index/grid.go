@@ -0,0 +1,106 @@
+package index
+
+import (
+	"math"
+
+	"match/cmd/pkg/geo"
+	"match/cmd/pkg/models"
+)
+
+// cellSize is the size, in degrees, of the grid cells.
+const cellSize = 1.0
+
+const (
+	rows = int(180 / cellSize)
+	cols = int(360 / cellSize)
+)
+
+// cell represents a grid cell by its row, from the south pole, and its column, from the anti-meridian.
+type cell struct {
+	row int
+	col int
+}
+
+// grid is a spatial index of the partners' coverage, i.e. the circle of the partner's radius around its location.
+// Each partner is added to all cells its coverage's bounding box overlaps, so the partners whose coverage may
+// contain a point are the ones in the point's cell.
+type grid struct {
+	cells map[cell][]int
+}
+
+func newGrid() *grid {
+	return &grid{cells: make(map[cell][]int)}
+}
+
+// add adds the partner, identified by i, to the cells its coverage overlaps.
+//
+// The bounds of the coverage are derived from the distance formula of geo.Distance, i.e.
+// 2 * R * sqrt(sin²(Δlat / 2) + cos(lat1) * cos(lat2) * sin²(Δlong / 2)), which must be lower than the radius.
+func (g *grid) add(i int, p models.Partner) {
+	lat := float64(p.Address.Lat)
+	long := float64(p.Address.Long)
+
+	// one more kilometer makes up for the rounding of the distance and of the coordinates
+	k := (float64(p.Radius) + 1) / (2 * geo.EarthRadius)
+
+	minRow, maxRow := 0, rows-1
+	minCol, maxCol := 0, cols-1
+
+	if k < 1 {
+		dLat := degrees(2 * math.Asin(k))
+		minRow = rowOf(lat - dLat)
+		maxRow = rowOf(lat + dLat)
+
+		// the longitude span is the widest at the latitude, within the coverage, closest to a pole
+		maxAbsLat := math.Min(90, math.Max(math.Abs(lat-dLat), math.Abs(lat+dLat)))
+		cosLats := math.Cos(radians(lat)) * math.Cos(radians(maxAbsLat))
+
+		if cosLats > 0 {
+			if sinHalfDLong := k / math.Sqrt(cosLats); sinHalfDLong < 1 {
+				dLong := degrees(2 * math.Asin(sinHalfDLong))
+				if dLong < 180 {
+					minCol = colOf(long - dLong)
+					maxCol = colOf(long + dLong)
+				}
+			}
+		}
+	}
+
+	for r := minRow; r <= maxRow; r++ {
+		for c := minCol; ; c = (c + 1) % cols {
+			g.cells[cell{r, c}] = append(g.cells[cell{r, c}], i)
+			if c == maxCol {
+				break
+			}
+		}
+	}
+}
+
+// candidates returns the partners whose coverage may contain the point.
+func (g *grid) candidates(lat, long float64) []int {
+	return g.cells[cell{rowOf(lat), colOf(long)}]
+}
+
+func rowOf(lat float64) int {
+	r := int(math.Floor((lat + 90) / cellSize))
+	if r < 0 {
+		return 0
+	}
+	if r >= rows {
+		return rows - 1
+	}
+	return r
+}
+
+func colOf(long float64) int {
+	c := int(math.Floor((long + 180) / cellSize))
+	return ((c % cols) + cols) % cols
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
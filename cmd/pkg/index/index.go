@@ -0,0 +1,254 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/geo"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+// snapshot is an immutable, indexed, copy of all partners.
+type snapshot struct {
+	partners []models.Partner
+	byId     map[uint]int
+	// materials holds, for each partner, the set of the ids of its materials
+	materials []map[uint]struct{}
+	grid      *grid
+}
+
+// Database is a partners.Database that answers GetMatches and GetPartnerById from an in-memory copy of all
+// partners, indexed by location, loaded from the wrapped database. The remaining methods, and every method
+// until the first load, are answered by the wrapped database.
+//
+// The copy is only as fresh as its last load, see Load and Run. The returned partners are shared between callers,
+// so they must not be modified.
+type Database struct {
+	partners.Database
+
+	mu   sync.RWMutex
+	snap *snapshot
+
+	refresh chan struct{}
+
+	ranking models.MatchRanking
+}
+
+// Option configures a Database.
+type Option func(db *Database)
+
+// WithMatchRanking ranks and limits the matches returned by GetMatches as given, which must be the same as the
+// wrapped database's for both to return the same matches.
+func WithMatchRanking(ranking models.MatchRanking) Option {
+	return func(db *Database) {
+		db.ranking = ranking
+	}
+}
+
+// NewDatabase creates a new Database that loads the partners from the given database.
+func NewDatabase(db partners.Database, opts ...Option) *Database {
+	idx := &Database{
+		Database: db,
+		refresh:  make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(idx)
+	}
+	return idx
+}
+
+// Load loads all partners from the wrapped database and replaces the in-memory copy once they are all loaded.
+func (db *Database) Load(ctx context.Context) error {
+	s := &snapshot{
+		byId: make(map[uint]int),
+		grid: newGrid(),
+	}
+
+	err := db.Database.StreamPartners(ctx, func(p models.Partner) error {
+		i := len(s.partners)
+
+		ms := make(map[uint]struct{}, len(p.Materials))
+		for _, m := range p.Materials {
+			ms[m.ID] = struct{}{}
+		}
+
+		s.partners = append(s.partners, p)
+		s.byId[p.ID] = i
+		s.materials = append(s.materials, ms)
+		s.grid.add(i, p)
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error loading the partners: %w", err)
+	}
+
+	db.mu.Lock()
+	db.snap = s
+	db.mu.Unlock()
+
+	return nil
+}
+
+// Loaded reports whether the partners were loaded at least once.
+func (db *Database) Loaded() bool {
+	return db.snapshot() != nil
+}
+
+// Refresh asks Run to load the partners again as soon as possible, e.g. because a partner changed.
+// It does not block.
+func (db *Database) Refresh() {
+	select {
+	case db.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run loads the partners every interval, or sooner when asked to by Refresh, until the context is done.
+func (db *Database) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-db.refresh:
+		}
+
+		err := db.Load(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("error refreshing the partners index", "error", err)
+		}
+	}
+}
+
+// GetMatches returns the partners that are experienced with all the given materials and whose radius covers the
+// given latitude and longitude, ordered by the highest rating and closest location, with the same semantics as
+// repository.Database.
+func (db *Database) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
+	s := db.snapshot()
+	if s == nil {
+		return db.Database.GetMatches(ctx, materials, lat, long)
+	}
+
+	// like the database's query, repeated materials never match
+	if hasDuplicates(materials) {
+		return []models.Partner{}, nil
+	}
+
+	type match struct {
+		i        int
+		distance int
+	}
+
+	var ms []match
+	for _, i := range s.grid.candidates(float64(lat), float64(long)) {
+		if !hasMaterials(s.materials[i], materials) {
+			continue
+		}
+
+		p := s.partners[i]
+		d := distance(p.Address.Lat, p.Address.Long, lat, long)
+		if d < p.Radius {
+			ms = append(ms, match{i: i, distance: d})
+		}
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		pi, pj := s.partners[ms[i].i], s.partners[ms[j].i]
+		if db.ranking.Weighted() {
+			si, sj := db.ranking.Score(pi.Rating, ms[i].distance), db.ranking.Score(pj.Rating, ms[j].distance)
+			if si != sj {
+				return si > sj
+			}
+		} else if pi.Rating != pj.Rating {
+			return pi.Rating > pj.Rating
+		}
+		if ms[i].distance != ms[j].distance {
+			return ms[i].distance < ms[j].distance
+		}
+		return pi.ID < pj.ID
+	})
+
+	if limit := db.ranking.MaxMatches(); len(ms) > limit {
+		ms = ms[:limit]
+	}
+
+	ps := make([]models.Partner, 0, len(ms))
+	for _, m := range ms {
+		ps = append(ps, s.partners[m.i])
+	}
+
+	return ps, nil
+}
+
+// GetPartnerById returns a partner by id.
+func (db *Database) GetPartnerById(ctx context.Context, id uint) (models.Partner, error) {
+	s := db.snapshot()
+	if s == nil {
+		return db.Database.GetPartnerById(ctx, id)
+	}
+
+	i, ok := s.byId[id]
+	if !ok {
+		return models.Partner{}, repository.ErrNotFound
+	}
+
+	return s.partners[i], nil
+}
+
+// UpdatePartner updates the partner with the wrapped database and asks for the index to be refreshed. Until it is,
+// the index still has the partner as it was.
+func (db *Database) UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error) {
+	p, err := db.Database.UpdatePartner(ctx, id, u)
+	if err != nil {
+		return models.Partner{}, err
+	}
+
+	db.Refresh()
+	return p, nil
+}
+
+func (db *Database) snapshot() *snapshot {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.snap
+}
+
+// hasMaterials reports whether the partner's materials contain all the given materials.
+func hasMaterials(partnerMaterials map[uint]struct{}, materials []uint) bool {
+	for _, m := range materials {
+		if _, ok := partnerMaterials[m]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasDuplicates(materials []uint) bool {
+	seen := make(map[uint]struct{}, len(materials))
+	for _, m := range materials {
+		if _, ok := seen[m]; ok {
+			return true
+		}
+		seen[m] = struct{}{}
+	}
+	return false
+}
+
+// distance returns the distance, in kilometers, between two points, rounded like the database's 'haversine'
+// function does, i.e. to the nearest integer with ties to even.
+func distance(lat1, long1, lat2, long2 float32) int {
+	return int(math.RoundToEven(geo.Distance(float64(lat1), float64(long1), float64(lat2), float64(long2))))
+}
@@ -0,0 +1,79 @@
+//go:build integration
+// +build integration
+
+package index_test
+
+import (
+	"context"
+	"testing"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/index"
+	"match/cmd/pkg/repository"
+
+	"github.com/google/go-cmp/cmp"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const dsn string = "host=localhost port=5432 user=root password=password dbname=match sslmode=disable"
+
+func openDatabase(tb testing.TB) partners.Database {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatalf("error opening the database: '%s'", err)
+	}
+	return repository.NewDatabase(db)
+}
+
+func TestIntegrationGetMatches(t *testing.T) {
+	repo := openDatabase(t)
+
+	idx := index.NewDatabase(repo)
+	err := idx.Load(context.Background())
+	if err != nil {
+		t.Fatalf("error loading the index: '%s'", err)
+	}
+
+	for _, materials := range [][]uint{{1}, {2}, {3}, {1, 2}, {1, 2, 3}} {
+		for lat := float32(0); lat <= 5; lat += 0.5 {
+			want, err := repo.GetMatches(context.Background(), materials, lat, lat)
+			if err != nil {
+				t.Fatalf("error getting matches: '%s'", err)
+			}
+
+			got, err := idx.GetMatches(context.Background(), materials, lat, lat)
+			if err != nil {
+				t.Fatalf("error getting matches: '%s'", err)
+			}
+
+			if diff := cmp.Diff(ids(want), ids(got)); diff != "" {
+				t.Errorf("%v (%v, %v): matches mismatch (-want +got):\n%s", materials, lat, lat, diff)
+			}
+		}
+	}
+}
+
+func BenchmarkIntegrationGetMatches(b *testing.B) {
+	repo := openDatabase(b)
+
+	idx := index.NewDatabase(repo)
+	err := idx.Load(context.Background())
+	if err != nil {
+		b.Fatalf("error loading the index: '%s'", err)
+	}
+
+	for name, db := range map[string]partners.Database{"repository": repo, "index": idx} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				_, err := db.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.1)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package index_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/geo"
+	"match/cmd/pkg/index"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTestPartners returns the partners of the database's initialization script.
+func newTestPartners() []models.Partner {
+	wood := models.Material{ID: 1, Description: "Wood"}
+	carpet := models.Material{ID: 2, Description: "Carpet"}
+	tile := models.Material{ID: 3, Description: "Tile"}
+
+	ps := []models.Partner{
+		{ID: 1, Address: models.Address{Lat: 1.3, Long: 1.3}, Radius: 200, Rating: 1, Materials: []models.Material{wood, carpet, tile}},
+		{ID: 2, Address: models.Address{Lat: 1.2, Long: 1.2}, Radius: 200, Rating: 3, Materials: []models.Material{wood, carpet, tile}},
+		{ID: 3, Address: models.Address{Lat: 1.1, Long: 1.1}, Radius: 200, Rating: 1, Materials: []models.Material{wood, carpet, tile}},
+		{ID: 4, Address: models.Address{Lat: 1.4, Long: 1.4}, Radius: 200, Rating: 2, Materials: []models.Material{wood, carpet}},
+		{ID: 5, Address: models.Address{Lat: 3.0, Long: 3.0}, Radius: 200, Rating: 5, Materials: []models.Material{wood, carpet, tile}},
+		{ID: 6, Address: models.Address{Lat: 4.0, Long: 4.0}, Radius: 200, Rating: 5, Materials: []models.Material{wood, carpet, tile}},
+	}
+
+	for i := range ps {
+		ps[i].Categories = []models.Category{{ID: 1, PartnerID: ps[i].ID, Description: "Flooring materials"}}
+		for j := range ps[i].Materials {
+			ps[i].Materials[j].PartnerID = ps[i].ID
+		}
+	}
+
+	return ps
+}
+
+// newRandomPartners returns n partners spread all over the globe.
+func newRandomPartners(r *rand.Rand, n int) []models.Partner {
+	var ps []models.Partner
+	for i := 1; i <= n; i++ {
+		p := models.Partner{
+			ID: uint(i),
+			Address: models.Address{
+				Lat:  float32(r.Float64()*180 - 90),
+				Long: float32(r.Float64()*360 - 180),
+			},
+			Radius: 1 + r.Intn(1000),
+			Rating: r.Intn(6),
+		}
+		for m := 1; m <= 5; m++ {
+			if r.Intn(2) == 0 {
+				p.Materials = append(p.Materials, models.Material{ID: uint(m), PartnerID: p.ID})
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func newLoadedDatabase(t testing.TB, ps []models.Partner, opts ...index.Option) *index.Database {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+			for _, p := range ps {
+				err := fn(p)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	idx := index.NewDatabase(db, opts...)
+
+	err := idx.Load(context.Background())
+	if err != nil {
+		t.Fatalf("error loading the index: '%s'", err)
+	}
+
+	return idx
+}
+
+func ids(ps []models.Partner) []uint {
+	res := []uint{}
+	for _, p := range ps {
+		res = append(res, p.ID)
+	}
+	return res
+}
+
+func TestGetMatches_Success(t *testing.T) {
+	ps := newTestPartners()
+	idx := newLoadedDatabase(t, ps)
+
+	tests := []struct {
+		materials []uint
+		lat, long float32
+		expected  []uint
+	}{
+		// the same matches as the integration tests
+		{[]uint{1, 2}, 1.1, 1.1, []uint{2, 4, 3, 1}},
+		{[]uint{3}, 1.1, 1.1, []uint{2, 3, 1}},
+		{[]uint{1}, 3.5, 3.5, []uint{5, 6}},
+		{[]uint{1}, 20, 20, []uint{}},
+		{[]uint{4}, 1.1, 1.1, []uint{}},
+		// like the database's query, repeated materials never match
+		{[]uint{1, 1}, 1.1, 1.1, []uint{}},
+	}
+
+	for _, test := range tests {
+		matches, err := idx.GetMatches(context.Background(), test.materials, test.lat, test.long)
+
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		if diff := cmp.Diff(test.expected, ids(matches)); diff != "" {
+			t.Errorf("%v (%v, %v): matches mismatch (-want +got):\n%s", test.materials, test.lat, test.long, diff)
+		}
+	}
+
+	matches, _ := idx.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.1)
+	if diff := cmp.Diff(ps[1], matches[0]); diff != "" {
+		t.Errorf("partner mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetMatches_Limit(t *testing.T) {
+	var ps []models.Partner
+	for i := 1; i <= 15; i++ {
+		ps = append(ps, models.Partner{
+			ID:        uint(i),
+			Address:   models.Address{Lat: 10, Long: 10},
+			Radius:    100,
+			Rating:    i % 5,
+			Materials: []models.Material{{ID: 1, PartnerID: uint(i)}},
+		})
+	}
+
+	idx := newLoadedDatabase(t, ps)
+
+	matches, _ := idx.GetMatches(context.Background(), []uint{1}, 10, 10)
+
+	expected := []uint{4, 9, 14, 3, 8, 13, 2, 7, 12, 1}
+	if diff := cmp.Diff(expected, ids(matches)); diff != "" {
+		t.Errorf("matches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestGetMatches_SameAsBruteForce verifies that the grid never misses a partner, including near the poles and
+// around the anti-meridian.
+func TestGetMatches_Ranking(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranking  models.MatchRanking
+		expected []uint
+	}{
+		{"closest", models.MatchRanking{DistanceWeight: 1}, []uint{3, 2, 1, 4}},
+		{"weighted", models.MatchRanking{RatingWeight: 10, DistanceWeight: 1}, []uint{2, 3, 1, 4}},
+		{"limit", models.MatchRanking{Limit: 2}, []uint{2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newLoadedDatabase(t, newTestPartners(), index.WithMatchRanking(tt.ranking))
+
+			ps, err := idx.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.1)
+
+			if err != nil {
+				t.Errorf("error mismatch: want 'nil' got '%s'", err)
+			}
+
+			if diff := cmp.Diff(tt.expected, ids(ps)); diff != "" {
+				t.Errorf("matches mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetMatches_SameAsBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ps := newRandomPartners(r, 5000)
+	idx := newLoadedDatabase(t, ps)
+
+	for q := 0; q < 2000; q++ {
+		lat := float32(r.Float64()*180 - 90)
+		long := float32(r.Float64()*360 - 180)
+		materials := []uint{uint(1 + r.Intn(5))}
+
+		type match struct {
+			p models.Partner
+			d int
+		}
+
+		var ms []match
+		for _, p := range ps {
+			d := int(math.RoundToEven(geo.Distance(float64(p.Address.Lat), float64(p.Address.Long), float64(lat), float64(long))))
+			if d >= p.Radius {
+				continue
+			}
+			for _, m := range p.Materials {
+				if m.ID == materials[0] {
+					ms = append(ms, match{p, d})
+				}
+			}
+		}
+
+		sort.Slice(ms, func(i, j int) bool {
+			if ms[i].p.Rating != ms[j].p.Rating {
+				return ms[i].p.Rating > ms[j].p.Rating
+			}
+			if ms[i].d != ms[j].d {
+				return ms[i].d < ms[j].d
+			}
+			return ms[i].p.ID < ms[j].p.ID
+		})
+
+		expected := []uint{}
+		for i := 0; i < len(ms) && i < 10; i++ {
+			expected = append(expected, ms[i].p.ID)
+		}
+
+		matches, _ := idx.GetMatches(context.Background(), materials, lat, long)
+
+		if diff := cmp.Diff(expected, ids(matches)); diff != "" {
+			t.Fatalf("%v (%v, %v): matches mismatch (-want +got):\n%s", materials, lat, long, diff)
+		}
+	}
+}
+
+func TestGetMatches_NotLoaded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{{ID: 1}}, nil)
+
+	idx := index.NewDatabase(db)
+
+	if idx.Loaded() {
+		t.Errorf("loaded mismatch: want false got true")
+	}
+
+	matches, err := idx.GetMatches(context.Background(), []uint{1}, 1.1, 1.2)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff([]uint{1}, ids(matches)); diff != "" {
+		t.Errorf("matches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetPartnerById_Success(t *testing.T) {
+	ps := newTestPartners()
+	idx := newLoadedDatabase(t, ps)
+
+	p, err := idx.GetPartnerById(context.Background(), 4)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff(ps[3], p); diff != "" {
+		t.Errorf("partner mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetPartnerById_NotFound(t *testing.T) {
+	idx := newLoadedDatabase(t, newTestPartners())
+
+	_, err := idx.GetPartnerById(context.Background(), 7)
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+}
+
+func TestLoad_FailureKeepsPreviousPartners(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	ps := newTestPartners()
+
+	gomock.InOrder(
+		db.EXPECT().
+			StreamPartners(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+				return fn(ps[0])
+			}),
+		db.EXPECT().
+			StreamPartners(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+				_ = fn(ps[1])
+				return errors.New("some error")
+			}),
+	)
+
+	idx := index.NewDatabase(db)
+
+	_ = idx.Load(context.Background())
+
+	err := idx.Load(context.Background())
+	if err == nil {
+		t.Errorf("error mismatch: want an error got 'nil'")
+	}
+
+	_, err = idx.GetPartnerById(context.Background(), ps[0].ID)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	_, err = idx.GetPartnerById(context.Background(), ps[1].ID)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+}
+
+func TestRun_Refresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	loaded := make(chan struct{})
+
+	db.EXPECT().
+		StreamPartners(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(p models.Partner) error) error {
+			close(loaded)
+			return nil
+		})
+
+	idx := index.NewDatabase(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go idx.Run(ctx, time.Hour)
+	idx.Refresh()
+
+	select {
+	case <-loaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the partners were not loaded")
+	}
+}
+
+func BenchmarkGetMatches(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	idx := newLoadedDatabase(b, newRandomPartners(r, 100000))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lat := float32(r.Float64()*180 - 90)
+		long := float32(r.Float64()*360 - 180)
+
+		_, err := idx.GetMatches(context.Background(), []uint{1, 2}, lat, long)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
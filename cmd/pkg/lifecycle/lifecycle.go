@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// hook stops a component.
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Lifecycle keeps track of the components of the application, e.g. the HTTP server, the background workers and the
+// database, to stop them in order when the application shuts down.
+//
+// The components are stopped in the reverse order of their registration, so a component registered after the ones it
+// uses is stopped before them, e.g. the workers are stopped before the database is closed. It is safe for concurrent
+// use.
+type Lifecycle struct {
+	signals chan os.Signal
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+// New creates a new Lifecycle, which from now on catches the SIGINT and SIGTERM signals.
+func New() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Lifecycle{
+		signals: make(chan os.Signal, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	signal.Notify(l.signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-l.signals
+		if ok {
+			slog.Info("shutting down", "signal", sig.String())
+			l.Stop(nil)
+		}
+	}()
+
+	return l
+}
+
+// Context returns a context that is done once the application is asked to stop, so the steps of its startup, e.g.
+// waiting for the database, give up instead of delaying the shutdown.
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// OnStop registers a component, which is stopped by calling stop with the context of Shutdown.
+func (l *Lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook{name: name, stop: stop})
+}
+
+// Go runs a background component until it is stopped, which cancels its context and waits for run to return.
+func (l *Lifecycle) Go(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	l.OnStop(name, func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// Stop asks the application to stop, making Wait return the given error, e.g. when a component can't keep running.
+// Only the first call has effect.
+func (l *Lifecycle) Stop(err error) {
+	l.once.Do(func() {
+		l.err = err
+		l.cancel()
+	})
+}
+
+// Wait blocks until the application receives SIGINT or SIGTERM, returning nil, or until Stop is called, returning its
+// error. Afterwards the signals are no longer caught, so a second signal kills the application right away.
+// It must be called only once.
+func (l *Lifecycle) Wait() error {
+	<-l.ctx.Done()
+
+	signal.Stop(l.signals)
+	close(l.signals)
+
+	return l.err
+}
+
+// Shutdown stops every component, in the reverse order of their registration. The context limits the time to stop
+// them all: once it is done the components still running are told to stop, but not waited for.
+//
+// A component that fails to stop does not keep the others from stopping.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks
+	l.hooks = nil
+	l.mu.Unlock()
+
+	var failed []string
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+
+		err := h.stop(ctx)
+		if err != nil {
+			slog.Error("error stopping", "component", h.name, "error", err)
+			failed = append(failed, h.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("error stopping %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
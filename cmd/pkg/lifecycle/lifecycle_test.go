@@ -0,0 +1,138 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/lifecycle"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShutdown_ReverseOrder(t *testing.T) {
+	l := lifecycle.New()
+
+	var stopped []string
+	for _, name := range []string{"database", "worker", "server"} {
+		name := name
+		l.OnStop(name, func(ctx context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+
+	err := l.Shutdown(context.Background())
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff([]string{"server", "worker", "database"}, stopped); diff != "" {
+		t.Errorf("stop order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestShutdown_FailureStopsTheOthers(t *testing.T) {
+	l := lifecycle.New()
+
+	var dbClosed bool
+	l.OnStop("database", func(ctx context.Context) error {
+		dbClosed = true
+		return nil
+	})
+	l.OnStop("server", func(ctx context.Context) error {
+		return errors.New("some error")
+	})
+
+	err := l.Shutdown(context.Background())
+
+	if err == nil || err.Error() != "error stopping server" {
+		t.Errorf("error mismatch: want 'error stopping server' got '%v'", err)
+	}
+
+	if !dbClosed {
+		t.Errorf("the database was not closed")
+	}
+}
+
+func TestGo_StopWaitsForTheComponent(t *testing.T) {
+	l := lifecycle.New()
+
+	var finished bool
+	l.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		finished = true
+	})
+
+	err := l.Shutdown(context.Background())
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if !finished {
+		t.Errorf("the shutdown did not wait for the worker")
+	}
+}
+
+func TestGo_DrainDeadline(t *testing.T) {
+	l := lifecycle.New()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	l.Go("worker", func(ctx context.Context) {
+		<-block
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := l.Shutdown(ctx)
+
+	if err == nil {
+		t.Errorf("error mismatch: want an error got 'nil'")
+	}
+}
+
+func TestWait_Signal(t *testing.T) {
+	l := lifecycle.New()
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("error finding the test process: '%s'", err)
+	}
+
+	err = p.Signal(os.Interrupt)
+	if err != nil {
+		t.Fatalf("error sending a signal: '%s'", err)
+	}
+
+	err = l.Wait()
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+}
+
+func TestWait_Stop(t *testing.T) {
+	l := lifecycle.New()
+
+	errServer := errors.New("address already in use")
+	l.Stop(errServer)
+	l.Stop(errors.New("some other error"))
+
+	err := l.Wait()
+
+	if !errors.Is(err, errServer) {
+		t.Errorf("error mismatch: want '%s' got '%v'", errServer, err)
+	}
+
+	if l.Context().Err() == nil {
+		t.Errorf("the context is not done")
+	}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"match/cmd/pkg/tracing"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger is a gorm logger that logs with the logger of the query's context, so the queries of a request are
+// logged with its ID. The failed queries are logged as errors, the slow ones as warnings and the others at the
+// debug level, with their statement sanitized as in the traces.
+type GormLogger struct {
+	// slowQuery is the duration above which a query is slow, or 0 to never log the slow queries.
+	slowQuery time.Duration
+	// silent is whether the queries are not logged, e.g. in gorm's dry run mode.
+	silent bool
+}
+
+// NewGormLogger creates a new GormLogger that logs the queries that take longer than slowQuery as slow.
+func NewGormLogger(slowQuery time.Duration) *GormLogger {
+	return &GormLogger{slowQuery: slowQuery}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	cp := *l
+	cp.silent = level == gormlogger.Silent
+	return &cp
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	l.log(ctx, slog.LevelInfo, fmt.Sprintf(msg, data...))
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	l.log(ctx, slog.LevelWarn, fmt.Sprintf(msg, data...))
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	l.log(ctx, slog.LevelError, fmt.Sprintf(msg, data...))
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level, msg := slog.LevelDebug, "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "query failed"
+	case l.slowQuery > 0 && elapsed > l.slowQuery:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("statement", tracing.Sanitize(sql)),
+		slog.Int64("rows_affected", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (l *GormLogger) log(ctx context.Context, level slog.Level, msg string) {
+	if !l.silent {
+		FromContext(ctx).Log(ctx, level, msg)
+	}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"match/cmd/pkg/controller/response"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID is the header with the ID of a request, given by the caller or generated otherwise.
+const HeaderRequestID = "X-Request-ID"
+
+// validRequestID matches the request IDs given by the callers that are kept, e.g. UUIDs. Others are replaced, so
+// they can't forge the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Middleware logs every request, once it is served, with its route, status code and duration. The request's ID is
+// taken from its 'X-Request-ID' header, or generated, and returned in the same header of the response. The handlers,
+// and the repository, log with the ID of the request, and with its trace's ID when it is traced, using the logger
+// of the request's context. It must be used by the router, with its Use method, so the route is known.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+
+		logger := slog.Default().With("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+
+		sw := response.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(WithLogger(r.Context(), logger)))
+
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		level := slog.LevelInfo
+		if sw.Code() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.Code()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		)
+	})
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		// the ID only correlates the logs, a time based one will do
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,30 @@
+// Package logging writes structured, JSON, logs with log/slog, masking the personal data they may hold, and
+// correlates the logs of a request with its ID.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+// New creates a new logger that writes JSON records of the given level and above to w, with their personal data
+// masked.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewMaskingHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// WithLogger returns a copy of the context that carries the logger, e.g. with the ID of the request.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the default logger if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,200 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"match/cmd/pkg/logging"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// initLogs makes the default logger write to the returned buffer.
+func initLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	previous := slog.Default()
+	slog.SetDefault(logging.New(buf, level))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+	})
+	return buf
+}
+
+// records returns the logged JSON records.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var r map[string]interface{}
+		err := json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			t.Fatalf("error decoding the log record '%s': '%s'", line, err)
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+func newRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(logging.Middleware)
+	r.HandleFunc("/partners/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Error("error retrieving the partner from the database")
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods(http.MethodGet)
+	return r
+}
+
+func TestMiddleware_PropagatesRequestID(t *testing.T) {
+	buf := initLogs(t, slog.LevelInfo)
+	rr := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
+	req.Header.Set(logging.HeaderRequestID, "3f2c9a70-6f0b-4a43-9d3e-2b4a1f0c8e11")
+
+	newRouter().ServeHTTP(rr, req)
+
+	expectedID := "3f2c9a70-6f0b-4a43-9d3e-2b4a1f0c8e11"
+	if id := rr.Header().Get(logging.HeaderRequestID); id != expectedID {
+		t.Errorf("request id mismatch: want '%s' got '%s'", expectedID, id)
+	}
+
+	rs := records(t, buf)
+	if len(rs) != 2 {
+		t.Fatalf("number of records mismatch: want 2 got %d", len(rs))
+	}
+
+	// the handler's record and the request's record
+	for _, r := range rs {
+		if r["request_id"] != expectedID {
+			t.Errorf("request id mismatch: want '%s' got '%v'", expectedID, r["request_id"])
+		}
+	}
+
+	r := rs[1]
+	if r["msg"] != "request" || r["level"] != "ERROR" {
+		t.Errorf("record mismatch: want an error 'request' record got '%v'", r)
+	}
+	if r["route"] != "/partners/{id:[0-9]+}" {
+		t.Errorf("route mismatch: want '/partners/{id:[0-9]+}' got '%v'", r["route"])
+	}
+	if r["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("status mismatch: want %v got %v", http.StatusInternalServerError, r["status"])
+	}
+	if _, ok := r["duration_ms"]; !ok {
+		t.Errorf("record mismatch: want a duration got '%v'", r)
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	for _, header := range []string{"", "forged\nrecord"} {
+		buf := initLogs(t, slog.LevelInfo)
+		rr := httptest.NewRecorder()
+
+		req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
+		req.Header.Set(logging.HeaderRequestID, header)
+
+		newRouter().ServeHTTP(rr, req)
+
+		id := rr.Header().Get(logging.HeaderRequestID)
+		if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) {
+			t.Errorf("request id mismatch: want a random id got '%s'", id)
+		}
+
+		for _, r := range records(t, buf) {
+			if r["request_id"] != id {
+				t.Errorf("request id mismatch: want '%s' got '%v'", id, r["request_id"])
+			}
+		}
+	}
+}
+
+func TestMaskingHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := logging.New(buf, slog.LevelInfo).With("phone_number", "+351912345678")
+
+	logger.Error("error matching +351 912 345 678",
+		"error", errors.New("invalid request for +351912345678"),
+		"request", slog.GroupValue(slog.String("address", "Rua 1, 912345678")),
+		"password", "s3cr3t",
+		"date", "2022-08-01",
+	)
+
+	rs := records(t, buf)
+	if len(rs) != 1 {
+		t.Fatalf("number of records mismatch: want 1 got %d", len(rs))
+	}
+	r := rs[0]
+
+	expected := map[string]interface{}{
+		"msg":          "error matching +*** *** *** *78",
+		"error":        "invalid request for +**********78",
+		"request":      map[string]interface{}{"address": "Rua 1, *******78"},
+		"password":     "[REDACTED]",
+		"phone_number": "[REDACTED]",
+		"date":         "2022-08-01",
+	}
+	for k, v := range expected {
+		got, _ := json.Marshal(r[k])
+		want, _ := json.Marshal(v)
+		if string(got) != string(want) {
+			t.Errorf("%s mismatch: want %s got %s", k, want, got)
+		}
+	}
+}
+
+func TestGormLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := logging.WithLogger(context.Background(), logging.New(buf, slog.LevelDebug).With("request_id", "abc"))
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	handler, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{Logger: logging.NewGormLogger(0)})
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: '%s'", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE jobs SET error = $1`)).
+		WithArgs("no partner for +351912345678").
+		WillReturnError(errors.New("connection refused"))
+
+	_ = handler.WithContext(ctx).Exec("UPDATE jobs SET error = ?", "no partner for +351912345678").Error
+
+	rs := records(t, buf)
+	if len(rs) != 1 {
+		t.Fatalf("number of records mismatch: want 1 got %d", len(rs))
+	}
+	r := rs[0]
+
+	if r["msg"] != "query failed" || r["request_id"] != "abc" {
+		t.Errorf("record mismatch: want a 'query failed' record of the request got '%v'", r)
+	}
+
+	expectedStatement := "UPDATE jobs SET error = ?"
+	if r["statement"] != expectedStatement {
+		t.Errorf("statement mismatch: want '%s' got '%v'", expectedStatement, r["statement"])
+	}
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const (
+	// redacted replaces the values of the sensitive attributes.
+	redacted = "[REDACTED]"
+	// minPhoneDigits is the minimum number of digits of a phone number, so dates, e.g. '2022-08-01', and other
+	// short numbers are not masked.
+	minPhoneDigits = 9
+	// visiblePhoneDigits is the number of trailing digits of a phone number that are not masked.
+	visiblePhoneDigits = 2
+)
+
+// phoneNumber matches a phone number, e.g. '+351912345678' or '+351 912 345 678', along with other numbers that look
+// like one.
+var phoneNumber = regexp.MustCompile(`(?:\+|\b)\d[\d ()-]{6,}\d\b`)
+
+// sensitiveKeys are the keys of the attributes whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"phone_number":  true,
+	"password":      true,
+	"authorization": true,
+	"api_key":       true,
+	"token":         true,
+}
+
+// MaskingHandler is a slog.Handler that masks the personal data of the records before handing them to another
+// handler: the phone numbers found in the message and in the string attributes, errors included, keep only their
+// last digits, and the values of the sensitive attributes, e.g. 'password', are redacted.
+type MaskingHandler struct {
+	next slog.Handler
+}
+
+// NewMaskingHandler creates a new MaskingHandler that hands the masked records to next.
+func NewMaskingHandler(next slog.Handler) *MaskingHandler {
+	return &MaskingHandler{next: next}
+}
+
+func (h *MaskingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *MaskingHandler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, mask(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(maskAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *MaskingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = maskAttr(a)
+	}
+	return &MaskingHandler{next: h.next.WithAttrs(masked)}
+}
+
+func (h *MaskingHandler) WithGroup(name string) slog.Handler {
+	return &MaskingHandler{next: h.next.WithGroup(name)}
+}
+
+// maskAttr masks the personal data of an attribute. The values that are neither strings, errors nor fmt.Stringers
+// are logged as they are.
+func maskAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, mask(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		masked := make([]any, len(group))
+		for i, ga := range group {
+			masked[i] = maskAttr(ga)
+		}
+		return slog.Group(a.Key, masked...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, mask(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, mask(v.String()))
+		}
+	}
+	return a
+}
+
+// mask masks the digits of the phone numbers of s, but their last ones.
+func mask(s string) string {
+	return phoneNumber.ReplaceAllStringFunc(s, func(match string) string {
+		digits := 0
+		for _, c := range match {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits < minPhoneDigits {
+			return match
+		}
+
+		b := []byte(match)
+		for i := range b {
+			if b[i] >= '0' && b[i] <= '9' && digits > visiblePhoneDigits {
+				b[i] = '*'
+				digits--
+			}
+		}
+		return string(b)
+	})
+}
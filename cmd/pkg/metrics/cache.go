@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"match/cmd/pkg/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache is a cache whose usage is exposed, e.g. a cache.Database.
+type Cache interface {
+	Stats() cache.Stats
+	Len() int
+}
+
+// RegisterCacheStats exposes the usage of the cache: its hits, its misses, its hit rate and its number of entries.
+func (m *Metrics) RegisterCacheStats(c Cache) error {
+	return m.registry.Register(cacheCollector{cache: c})
+}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "hits_total"),
+		"The number of lookups served from the cache.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "misses_total"),
+		"The number of lookups not served from the cache.", nil, nil)
+	cacheHitRateDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "hit_rate"),
+		"The ratio of the lookups served from the cache, between 0 and 1.", nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "entries"),
+		"The number of cached results.", nil, nil)
+)
+
+// cacheCollector collects the usage of a cache when the metrics are scraped.
+type cacheCollector struct {
+	cache Cache
+}
+
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheHitRateDesc
+	ch <- cacheEntriesDesc
+}
+
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.cache.Stats()
+
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheHitRateDesc, prometheus.GaugeValue, s.HitRate())
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(c.cache.Len()))
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"match/cmd/pkg/controller/response"
+
+	"github.com/gorilla/mux"
+)
+
+// unmatchedRoute is the route of the requests that do not match any route of the router.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts the requests and measures their duration, by the template of their route, e.g.
+// '/partners/{id:[0-9]+}', so the requests for different partners share their metrics.
+// It must be used by the router, with its Use method, so the route is known.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := response.NewStatusWriter(w)
+
+		next.ServeHTTP(sw, r)
+
+		route := unmatchedRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.Code())).Inc()
+		m.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/models"
+)
+
+// Matches records the number of partners matched by the match requests of a partners.Database.
+type Matches struct {
+	partners.Database
+
+	metrics *Metrics
+}
+
+// WrapMatches returns a Matches that records the matches of the given database.
+func (m *Metrics) WrapMatches(db partners.Database) *Matches {
+	return &Matches{Database: db, metrics: m}
+}
+
+func (d *Matches) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
+	ps, err := d.Database.GetMatches(ctx, materials, lat, long)
+	if err != nil {
+		return ps, err
+	}
+
+	d.metrics.matches.Observe(float64(len(ps)))
+	if len(ps) == 0 {
+		d.metrics.emptyMatches.Inc()
+	}
+	return ps, nil
+}
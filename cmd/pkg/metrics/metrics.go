@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of all the metrics.
+const namespace = "match"
+
+var (
+	// durationBuckets are the buckets, in seconds, of the HTTP requests' and the queries' durations.
+	durationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// matchesBuckets are the buckets of the number of matches of a request, up to the maximum by default.
+	matchesBuckets = prometheus.LinearBuckets(0, 1, 11)
+)
+
+// Metrics collects the metrics of the app and exposes them to Prometheus.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	matches      prometheus.Histogram
+	emptyMatches prometheus.Counter
+
+	queryDuration *prometheus.HistogramVec
+}
+
+// New creates a new Metrics, with the metrics of the Go runtime and of the process.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "The number of HTTP requests, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "The duration of the HTTP requests, by route and method.",
+			Buckets:   durationBuckets,
+		}, []string{"route", "method"}),
+		matches: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "matches",
+			Help:      "The number of partners matched by each match request.",
+			Buckets:   matchesBuckets,
+		}),
+		emptyMatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "empty_matches_total",
+			Help:      "The number of match requests without any matched partner.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "The duration of the database queries, by query and status.",
+			Buckets:   durationBuckets,
+		}, []string{"query", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.matches,
+		m.emptyMatches,
+		m.queryDuration,
+	)
+
+	return m
+}
+
+// RegisterDBStats exposes the statistics of a database connection pool, e.g. its open and in use connections, with the
+// given name to tell the pools apart.
+func (m *Metrics) RegisterDBStats(name string, db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Registry returns the registry of the metrics, to register the metrics of other components.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns the handler of the '/metrics' requests.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,187 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"match/cmd/pkg/cache"
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/metrics"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// scrape returns the metrics as exposed to Prometheus.
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	b, err := io.ReadAll(rr.Body)
+	if err != nil {
+		t.Fatalf("error reading the metrics: '%s'", err)
+	}
+	return string(b)
+}
+
+func assertContains(t *testing.T, metrics string, lines ...string) {
+	for _, l := range lines {
+		if !strings.Contains(metrics, l+"\n") {
+			t.Errorf("metrics mismatch: want '%s' in:\n%s", l, metrics)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := metrics.New()
+
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/partners/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "2" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/partners/1", "/partners/1", "/partners/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assertContains(t, scrape(t, m),
+		`match_http_requests_total{code="200",method="GET",route="/partners/{id:[0-9]+}"} 2`,
+		`match_http_requests_total{code="404",method="GET",route="/partners/{id:[0-9]+}"} 1`,
+		`match_http_request_duration_seconds_count{method="GET",route="/partners/{id:[0-9]+}"} 3`,
+	)
+}
+
+func TestMiddleware_KeepsFlusher(t *testing.T) {
+	m := metrics.New()
+
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/partners/export.csv", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Errorf("the response writer is not a http.Flusher")
+		}
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/partners/export.csv", nil))
+}
+
+func TestWrapMatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.1)).
+		Return([]models.Partner{{ID: 1}, {ID: 2}}, nil)
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{2}, float32(1.1), float32(1.1)).
+		Return([]models.Partner{}, nil)
+
+	m := metrics.New()
+	matches := m.WrapMatches(db)
+
+	_, _ = matches.GetMatches(context.Background(), []uint{1}, 1.1, 1.1)
+	_, _ = matches.GetMatches(context.Background(), []uint{2}, 1.1, 1.1)
+
+	assertContains(t, scrape(t, m),
+		`match_matches_bucket{le="0"} 1`,
+		`match_matches_bucket{le="2"} 2`,
+		`match_matches_count 2`,
+		`match_empty_matches_total 1`,
+	)
+}
+
+func TestWrapRepository(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	handler, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: '%s'", err)
+	}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "partners" WHERE id = $1 ORDER BY "partners"."id" LIMIT 1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	m := metrics.New()
+	repo := m.WrapRepository(repository.NewDatabase(handler))
+
+	_, err = repo.GetPartnerById(context.Background(), 1)
+	if err == nil {
+		t.Errorf("error mismatch: want '%s' got 'nil'", repository.ErrNotFound)
+	}
+
+	assertContains(t, scrape(t, m),
+		`match_db_query_duration_seconds_count{query="get_partner_by_id",status="not_found"} 1`,
+	)
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	m := metrics.New()
+
+	err = m.RegisterDBStats("primary", db)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	n, err := testutil.GatherAndCount(m.Registry(), "go_sql_open_connections")
+	if err != nil || n != 1 {
+		t.Errorf("open connections metric mismatch: want 1 got %d (%v)", n, err)
+	}
+}
+
+func TestRegisterCacheStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := mock.NewMockDatabase(ctrl)
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(models.Partner{ID: 1}, nil)
+
+	c := cache.NewDatabase(db, cache.Options{})
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	_, _ = c.GetPartnerById(context.Background(), 1)
+	_, _ = c.GetPartnerById(context.Background(), 1)
+
+	m := metrics.New()
+
+	err := m.RegisterCacheStats(c)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	assertContains(t, scrape(t, m),
+		"match_cache_hits_total 2",
+		"match_cache_misses_total 1",
+		"match_cache_hit_rate 0.6666666666666666",
+		"match_cache_entries 1",
+	)
+}
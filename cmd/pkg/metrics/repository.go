@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+// The statuses of the queries.
+const (
+	statusOK          = "ok"
+	statusNotFound    = "not_found"
+	statusUnavailable = "unavailable"
+	statusError       = "error"
+)
+
+// Repository measures the duration of the queries of a repository.Database, by the method that runs them.
+// The methods it does not override, e.g. Ping, are not measured.
+type Repository struct {
+	*repository.Database
+
+	metrics *Metrics
+}
+
+// WrapRepository returns a Repository that measures the queries of the given database.
+func (m *Metrics) WrapRepository(db *repository.Database) *Repository {
+	return &Repository{Database: db, metrics: m}
+}
+
+// observe records the duration of a query that started at the given time and ended with the given error.
+func (r *Repository) observe(query string, start time.Time, err error) {
+	r.metrics.queryDuration.WithLabelValues(query, queryStatus(err)).Observe(time.Since(start).Seconds())
+}
+
+func queryStatus(err error) string {
+	switch {
+	case err == nil:
+		return statusOK
+	case errors.Is(err, repository.ErrNotFound):
+		return statusNotFound
+	case errors.Is(err, repository.ErrUnavailable):
+		return statusUnavailable
+	}
+	return statusError
+}
+
+func (r *Repository) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
+	start := time.Now()
+	ps, err := r.Database.GetMatches(ctx, materials, lat, long)
+	r.observe("get_matches", start, err)
+	return ps, err
+}
+
+func (r *Repository) GetPartnerById(ctx context.Context, id uint) (models.Partner, error) {
+	start := time.Now()
+	p, err := r.Database.GetPartnerById(ctx, id)
+	r.observe("get_partner_by_id", start, err)
+	return p, err
+}
+
+func (r *Repository) UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error) {
+	start := time.Now()
+	p, err := r.Database.UpdatePartner(ctx, id, u)
+	r.observe("update_partner", start, err)
+	return p, err
+}
+
+func (r *Repository) ListPartners(ctx context.Context, filter models.PartnerFilter) ([]models.Partner, error) {
+	start := time.Now()
+	ps, err := r.Database.ListPartners(ctx, filter)
+	r.observe("list_partners", start, err)
+	return ps, err
+}
+
+func (r *Repository) CountPartners(ctx context.Context, filter models.PartnerFilter) (int64, error) {
+	start := time.Now()
+	n, err := r.Database.CountPartners(ctx, filter)
+	r.observe("count_partners", start, err)
+	return n, err
+}
+
+// StreamPartners measures the whole stream, including the time spent by fn.
+func (r *Repository) StreamPartners(ctx context.Context, fn func(p models.Partner) error) error {
+	start := time.Now()
+	err := r.Database.StreamPartners(ctx, fn)
+	r.observe("stream_partners", start, err)
+	return err
+}
+
+func (r *Repository) CreateJob(ctx context.Context, owner string, reqs []models.MatchRequest) (models.Job, error) {
+	start := time.Now()
+	j, err := r.Database.CreateJob(ctx, owner, reqs)
+	r.observe("create_job", start, err)
+	return j, err
+}
+
+func (r *Repository) GetJob(ctx context.Context, id string) (models.Job, error) {
+	start := time.Now()
+	j, err := r.Database.GetJob(ctx, id)
+	r.observe("get_job", start, err)
+	return j, err
+}
+
+func (r *Repository) ClaimJob(ctx context.Context, lockedUntil time.Time) (models.Job, error) {
+	start := time.Now()
+	j, err := r.Database.ClaimJob(ctx, lockedUntil)
+	r.observe("claim_job", start, err)
+	return j, err
+}
+
+func (r *Repository) SaveJobResults(ctx context.Context, id string, offset int, results []models.MatchResult, lockedUntil time.Time) error {
+	start := time.Now()
+	err := r.Database.SaveJobResults(ctx, id, offset, results, lockedUntil)
+	r.observe("save_job_results", start, err)
+	return err
+}
+
+func (r *Repository) FinishJob(ctx context.Context, id string, errMsg string) error {
+	start := time.Now()
+	err := r.Database.FinishJob(ctx, id, errMsg)
+	r.observe("finish_job", start, err)
+	return err
+}
+
+// StreamJobResults measures the whole stream, including the time spent by fn.
+func (r *Repository) StreamJobResults(ctx context.Context, id string, fn func(r models.MatchResult) error) error {
+	start := time.Now()
+	err := r.Database.StreamJobResults(ctx, id, fn)
+	r.observe("stream_job_results", start, err)
+	return err
+}
+
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	start := time.Now()
+	k, err := r.Database.GetAPIKeyByHash(ctx, hash)
+	r.observe("get_api_key_by_hash", start, err)
+	return k, err
+}
+
+func (r *Repository) TakeToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	start := time.Now()
+	tokens, allowed, err := r.Database.TakeToken(ctx, key, rate, burst)
+	r.observe("take_token", start, err)
+	return tokens, allowed, err
+}
+
+func (r *Repository) BeginIdempotentRequest(ctx context.Context, client, key, fingerprint string, lock time.Duration) (models.IdempotencyKey, bool, error) {
+	start := time.Now()
+	k, started, err := r.Database.BeginIdempotentRequest(ctx, client, key, fingerprint, lock)
+	r.observe("begin_idempotent_request", start, err)
+	return k, started, err
+}
+
+func (r *Repository) CompleteIdempotentRequest(ctx context.Context, client, key string, code int, headers map[string]string, body []byte, ttl time.Duration) error {
+	start := time.Now()
+	err := r.Database.CompleteIdempotentRequest(ctx, client, key, code, headers, body, ttl)
+	r.observe("complete_idempotent_request", start, err)
+	return err
+}
+
+func (r *Repository) ReleaseIdempotentRequest(ctx context.Context, client, key string) error {
+	start := time.Now()
+	err := r.Database.ReleaseIdempotentRequest(ctx, client, key)
+	r.observe("release_idempotent_request", start, err)
+	return err
+}
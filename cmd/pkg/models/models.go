@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // MatchRequest represents '/partners/match' request.
 type MatchRequest struct {
 	Materials    []uint  `json:"materials"`
@@ -18,6 +20,13 @@ type Partner struct {
 	Rating     int        `json:"rating" gorm:"column:rating"`
 }
 
+// PartnerUpdate represents a '/partners/{id}' update of a partner's own record. Only the fields that are set are
+// updated; the rating is not the partner's to edit.
+type PartnerUpdate struct {
+	Address *Address `json:"address"`
+	Radius  *int     `json:"radius"`
+}
+
 // Category represents a partner's category.
 type Category struct {
 	ID          uint   `json:"id" gorm:"column:id"`
@@ -37,3 +46,155 @@ type Address struct {
 	Lat  float32 `json:"lat"`
 	Long float32 `json:"long"`
 }
+
+// DefaultMatchLimit is the maximum number of matches when MatchRanking's Limit is not set.
+const DefaultMatchLimit = 10
+
+// MatchRanking represents how the matches of a '/partners/match' request are ranked, and how many are returned.
+//
+// When a weight is set the matches are sorted by the highest score, rating*RatingWeight - distance*DistanceWeight,
+// with the distance in kilometers, and then by the closest location. Otherwise, they are sorted by the highest rating
+// and then by the closest location.
+type MatchRanking struct {
+	RatingWeight   float64
+	DistanceWeight float64
+	Limit          int
+}
+
+// Weighted reports whether the matches are sorted by their score.
+func (r MatchRanking) Weighted() bool {
+	return r.RatingWeight != 0 || r.DistanceWeight != 0
+}
+
+// Score returns the score of a match with the given rating and distance.
+func (r MatchRanking) Score(rating, distance int) float64 {
+	return float64(rating)*r.RatingWeight - float64(distance)*r.DistanceWeight
+}
+
+// MaxMatches returns the maximum number of matches.
+func (r MatchRanking) MaxMatches() int {
+	if r.Limit <= 0 {
+		return DefaultMatchLimit
+	}
+	return r.Limit
+}
+
+// PartnerSort represents a field by which partners can be sorted.
+type PartnerSort string
+
+const (
+	SortByID       PartnerSort = "id"
+	SortByRating   PartnerSort = "rating"
+	SortByRadius   PartnerSort = "radius"
+	SortByDistance PartnerSort = "distance"
+)
+
+// PartnerFilter represents the filters, sorting and pagination of '/partners' requests.
+// Nil or empty fields are ignored.
+type PartnerFilter struct {
+	// IDs restricts the partners to the ones with the given ids.
+	IDs []uint
+	// Materials are the ids of the materials a partner must be experienced with, all of them.
+	Materials []uint
+	// Categories are the ids of the categories a partner must have, all of them.
+	Categories []uint
+	MinRating  *int
+	MaxRating  *int
+	// Near restricts the partners to the ones located within a distance of a point.
+	Near *Proximity
+	// Box restricts the partners to the ones located inside a bounding box.
+	Box *BoundingBox
+	// Sort is the field the partners are sorted by. Sorting by distance requires Near.
+	Sort       PartnerSort
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+// Proximity represents a distance, in kilometers, around a point.
+type Proximity struct {
+	Address  Address
+	Distance int
+}
+
+// BoundingBox represents an area delimited by its south-west and north-east corners.
+// A box whose minimum longitude is greater than its maximum longitude crosses the anti-meridian.
+type BoundingBox struct {
+	MinLat  float32
+	MinLong float32
+	MaxLat  float32
+	MaxLong float32
+}
+
+// PartnerPage represents '/partners' response.
+type PartnerPage struct {
+	Partners []Partner `json:"partners"`
+	Total    int64     `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// MatchResult represents the result of a single match request of '/partners/match/batch' requests.
+// Either the partners or the error is set.
+type MatchResult struct {
+	Partners []Partner `json:"partners"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// JobStatus represents the status of a match job.
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job represents an asynchronous job that matches a batch of customers' requests. Its owner is the principal that
+// created it, the only one besides the admins that can see it.
+type Job struct {
+	ID          string         `json:"id" gorm:"column:id"`
+	Owner       string         `json:"-" gorm:"column:owner"`
+	Status      JobStatus      `json:"status" gorm:"column:status"`
+	Requests    []MatchRequest `json:"-" gorm:"column:requests;serializer:json"`
+	Total       int            `json:"total" gorm:"column:total"`
+	Processed   int            `json:"processed" gorm:"column:processed"`
+	Error       string         `json:"error,omitempty" gorm:"column:error"`
+	LockedUntil *time.Time     `json:"-" gorm:"column:locked_until"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// JobResult represents the result of a single match request of a job.
+type JobResult struct {
+	JobID  string      `gorm:"column:job_id"`
+	Index  int         `gorm:"column:idx"`
+	Result MatchResult `gorm:"column:result;serializer:json"`
+}
+
+// APIKey represents a key that authenticates a client of the API, with a role and, optionally, the scopes it is
+// restricted to. A partner's key is for its partner. Only the hash of the key is stored.
+type APIKey struct {
+	ID        uint       `json:"id" gorm:"column:id"`
+	Name      string     `json:"name" gorm:"column:name"`
+	Hash      string     `json:"-" gorm:"column:hash"`
+	Role      string     `json:"role" gorm:"column:role"`
+	Scopes    []string   `json:"scopes" gorm:"column:scopes;serializer:json"`
+	PartnerID *uint      `json:"partner_id,omitempty" gorm:"column:partner_id"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+}
+
+// IdempotencyKey represents a request made with an 'Idempotency-Key' header, by its client and key, and the response
+// replayed to its retries once it is done. StatusCode is nil while the request is in progress.
+type IdempotencyKey struct {
+	Client      string            `json:"client" gorm:"column:client;primaryKey"`
+	Key         string            `json:"key" gorm:"column:key;primaryKey"`
+	Fingerprint string            `json:"fingerprint" gorm:"column:fingerprint"`
+	StatusCode  *int              `json:"status_code" gorm:"column:status_code"`
+	Headers     map[string]string `json:"headers" gorm:"column:headers;serializer:json"`
+	Body        []byte            `json:"body" gorm:"column:body"`
+	CreatedAt   time.Time         `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt   time.Time         `json:"expires_at" gorm:"column:expires_at"`
+}
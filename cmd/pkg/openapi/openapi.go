@@ -0,0 +1,254 @@
+// Package openapi registers the routes of the API from its OpenAPI specification, so the routes can't drift from it,
+// and serves the specification and its documentation.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"match/cmd/pkg/controller/response"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+)
+
+// docsPage renders the specification served at '/openapi.yml' with Redoc, whose bundle is served at '/docs/redoc.js'.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Match API</title>
+  <meta charset="utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="/openapi.yml"></redoc>
+  <script src="/docs/redoc.js"></script>
+</body>
+</html>
+`
+
+// docsPolicy is the Content-Security-Policy of the documentation page, which only loads the app's own scripts.
+const docsPolicy = "default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; worker-src 'self' blob:"
+
+// redoc is the standalone bundle of Redoc, vendored by 'make redoc', so the documentation doesn't run a script
+// from a CDN.
+//
+//go:embed redoc.standalone.js
+var redoc []byte
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`)
+
+// Spec is the OpenAPI specification of the API.
+type Spec struct {
+	doc  *openapi3.T
+	data []byte
+	json []byte
+
+	mu         sync.Mutex
+	registered map[string]bool
+}
+
+// Load loads the specification, e.g. match.OpenAPI, and checks that it is valid and that every operation has an id,
+// since the operations are registered by their id.
+func Load(data []byte) (*Spec, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("error trying to load the openapi specification: %w", err)
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi specification: %w", err)
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item.Operations() {
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("invalid openapi specification: %s %s has no operationId", method, path)
+			}
+		}
+	}
+
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error trying to marshal the openapi specification: %w", err)
+	}
+
+	return &Spec{doc: doc, data: data, json: j, registered: make(map[string]bool)}, nil
+}
+
+// Doc returns the parsed specification.
+func (s *Spec) Doc() *openapi3.T {
+	return s.doc
+}
+
+// Register registers the operations of the specification that have a handler, by their id, on the router, each
+// route named after its operation. The path parameters are constrained by their schema, e.g. an integer to digits,
+// and the paths are registered in order, so the literal ones, e.g. '/partners/match', come before the parameters.
+//
+// It returns an error if a handler has no operation, e.g. because of a typo.
+func (s *Spec) Register(r *mux.Router, handlers map[string]http.HandlerFunc) error {
+	found := make(map[string]bool, len(handlers))
+
+	paths := make([]string, 0, len(s.doc.Paths))
+	for path := range s.doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := s.doc.Paths[path]
+
+		methods := make([]string, 0, len(item.Operations()))
+		for method := range item.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			op := item.GetOperation(method)
+			h, ok := handlers[op.OperationID]
+			if !ok {
+				continue
+			}
+
+			tpl, err := routeTemplate(path, item, op)
+			if err != nil {
+				return err
+			}
+
+			r.HandleFunc(tpl, h).Methods(method).Name(op.OperationID)
+			found[op.OperationID] = true
+		}
+	}
+
+	var unknown []string
+	for id := range handlers {
+		if !found[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("handlers of unknown operations: %s", strings.Join(unknown, ", "))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range found {
+		s.registered[id] = true
+	}
+
+	return nil
+}
+
+// Unregistered returns the operations of the specification that were not registered, i.e. that the API doesn't
+// serve.
+func (s *Spec) Unregistered() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for _, item := range s.doc.Paths {
+		for _, op := range item.Operations() {
+			if !s.registered[op.OperationID] {
+				ids = append(ids, op.OperationID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Operation returns the path and the operation of the given id, if there is one.
+func (s *Spec) Operation(id string) (string, *openapi3.PathItem, *openapi3.Operation, bool) {
+	for path, item := range s.doc.Paths {
+		for _, op := range item.Operations() {
+			if op.OperationID == id {
+				return path, item, op, true
+			}
+		}
+	}
+	return "", nil, nil, false
+}
+
+// ServeYAML serves the specification as it is written.
+func (s *Spec) ServeYAML(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	response.Write(w, s.data)
+}
+
+// ServeJSON serves the specification as JSON.
+func (s *Spec) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	response.Write(w, s.json)
+}
+
+// ServeDocs serves the documentation page of the specification.
+func (s *Spec) ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	response.Write(w, []byte(docsPage))
+}
+
+// ServeRedoc serves the Redoc bundle of the documentation page.
+func (s *Spec) ServeRedoc(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	response.Write(w, redoc)
+}
+
+// routeTemplate returns the mux template of the path, with the pattern of each parameter, e.g.
+// '/partners/{id:[0-9]+}' for '/partners/{id}'.
+func routeTemplate(path string, item *openapi3.PathItem, op *openapi3.Operation) (string, error) {
+	var errs []error
+
+	tpl := pathParam.ReplaceAllStringFunc(path, func(m string) string {
+		name := m[1 : len(m)-1]
+
+		p := op.Parameters.GetByInAndName(openapi3.ParameterInPath, name)
+		if p == nil {
+			p = item.Parameters.GetByInAndName(openapi3.ParameterInPath, name)
+		}
+		if p == nil || p.Schema == nil || p.Schema.Value == nil {
+			errs = append(errs, fmt.Errorf("the path parameter '%s' of %s has no schema", name, path))
+			return m
+		}
+
+		pattern := paramPattern(p.Schema.Value)
+		if pattern == "" {
+			return m
+		}
+		return "{" + name + ":" + pattern + "}"
+	})
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return tpl, nil
+}
+
+// paramPattern returns the pattern of the values of the schema, or an empty string for any value.
+func paramPattern(schema *openapi3.Schema) string {
+	switch {
+	case schema.Pattern != "":
+		return strings.TrimSuffix(strings.TrimPrefix(schema.Pattern, "^"), "$")
+	case len(schema.Enum) > 0:
+		values := make([]string, len(schema.Enum))
+		for i, v := range schema.Enum {
+			values[i] = regexp.QuoteMeta(fmt.Sprint(v))
+		}
+		return strings.Join(values, "|")
+	case schema.Type == openapi3.TypeInteger:
+		return "[0-9]+"
+	}
+	return ""
+}
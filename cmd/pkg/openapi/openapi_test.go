@@ -0,0 +1,174 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"match"
+	"match/cmd/pkg/openapi"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+)
+
+var operations = []string{
+	"list_partners", "match", "match_batch", "get_partner", "update_partner", "export_partners",
+	"create_job", "get_job", "get_job_results", "liveness", "readiness",
+}
+
+// handlers returns the handlers of the operations, which answer with their operation.
+func handlers(ids ...string) map[string]http.HandlerFunc {
+	hs := make(map[string]http.HandlerFunc, len(ids))
+	for _, id := range ids {
+		id := id
+		hs[id] = func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(id))
+		}
+	}
+	return hs
+}
+
+func TestRegister(t *testing.T) {
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	r := mux.NewRouter()
+	err = spec.Register(r, handlers(operations...))
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if ops := spec.Unregistered(); len(ops) > 0 {
+		t.Errorf("unregistered operations mismatch: want none got %v", ops)
+	}
+
+	for _, tc := range []struct {
+		method    string
+		path      string
+		code      int
+		operation string
+	}{
+		{http.MethodGet, "/partners", http.StatusOK, "list_partners"},
+		{http.MethodPost, "/partners/match", http.StatusOK, "match"},
+		{http.MethodPost, "/partners/match/batch", http.StatusOK, "match_batch"},
+		{http.MethodGet, "/partners/12", http.StatusOK, "get_partner"},
+		{http.MethodPatch, "/partners/12", http.StatusOK, "update_partner"},
+		{http.MethodGet, "/partners/export.geojson", http.StatusOK, "export_partners"},
+		{http.MethodGet, "/jobs/0123456789abcdef0123456789abcdef/results", http.StatusOK, "get_job_results"},
+		{http.MethodGet, "/readyz", http.StatusOK, "readiness"},
+		// the path parameters are constrained by their schema
+		{http.MethodGet, "/partners/abc", http.StatusNotFound, ""},
+		{http.MethodGet, "/partners/export.xml", http.StatusNotFound, ""},
+		{http.MethodGet, "/jobs/123", http.StatusNotFound, ""},
+		{http.MethodDelete, "/partners/12", http.StatusMethodNotAllowed, ""},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if rr.Code != tc.code {
+			t.Errorf("%s %s status code mismatch: want %v got %v", tc.method, tc.path, tc.code, rr.Code)
+		}
+
+		if tc.operation != "" && rr.Body.String() != tc.operation {
+			t.Errorf("%s %s operation mismatch: want %s got %s", tc.method, tc.path, tc.operation, rr.Body.String())
+		}
+	}
+}
+
+func TestRegister_Partially(t *testing.T) {
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	err = spec.Register(mux.NewRouter(), handlers("liveness", "readiness"))
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := []string{"create_job", "export_partners", "get_job", "get_job_results", "get_partner", "list_partners", "match", "match_batch", "update_partner"}
+	if diff := cmp.Diff(expected, spec.Unregistered()); diff != "" {
+		t.Errorf("unregistered operations mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegister_UnknownOperation(t *testing.T) {
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	err = spec.Register(mux.NewRouter(), handlers("liveness", "delete_partner"))
+
+	if err == nil || !strings.Contains(err.Error(), "delete_partner") {
+		t.Errorf("error mismatch: want an unknown operation error got '%v'", err)
+	}
+}
+
+func TestLoad_MissingOperationID(t *testing.T) {
+	spec := `openapi: 3.0.3
+info:
+  title: Match
+  version: 0.0.1
+paths:
+  /healthz:
+    get:
+      responses:
+        200:
+          description: Alive
+`
+	_, err := openapi.Load([]byte(spec))
+
+	if err == nil || !strings.Contains(err.Error(), "operationId") {
+		t.Errorf("error mismatch: want a missing operationId error got '%v'", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	for _, tc := range []struct {
+		serve       http.HandlerFunc
+		contentType string
+		contains    string
+	}{
+		{spec.ServeYAML, "application/yaml", "openapi: 3.0.3"},
+		{spec.ServeJSON, "application/json", `"openapi":"3.0.3"`},
+		{spec.ServeDocs, "text/html; charset=utf-8", `<script src="/docs/redoc.js">`},
+		{spec.ServeRedoc, "text/javascript; charset=utf-8", "document"},
+	} {
+		rr := httptest.NewRecorder()
+		tc.serve(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rr.Header().Get("Content-Type") != tc.contentType {
+			t.Errorf("content type mismatch: want %s got %s", tc.contentType, rr.Header().Get("Content-Type"))
+		}
+
+		if !strings.Contains(rr.Body.String(), tc.contains) {
+			t.Errorf("body mismatch: want %s in %s", tc.contains, rr.Body.String())
+		}
+	}
+}
+
+func TestServeRedoc_Vendored(t *testing.T) {
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	rr := httptest.NewRecorder()
+	spec.ServeRedoc(rr, httptest.NewRequest(http.MethodGet, "/docs/redoc.js", nil))
+
+	// the standalone bundle is about 1 MB and defines the Redoc global, unlike the placeholder of an unvendored tree
+	body := rr.Body.String()
+	if len(body) < 500_000 || !strings.Contains(body, "Redoc") || strings.Contains(body, "make redoc") {
+		t.Errorf("redoc bundle mismatch: want the bundle vendored by 'make redoc' got %d bytes", len(body))
+	}
+}
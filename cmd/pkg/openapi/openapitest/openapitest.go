@@ -0,0 +1,60 @@
+// Package openapitest serves the handlers in the tests as the app does, routed by openapi.yml, and fails the tests
+// whose requests or responses don't match it, so a handler can't silently break the contract.
+package openapitest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"match"
+	"match/cmd/pkg/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	once    sync.Once
+	spec    *openapi.Spec
+	specErr error
+)
+
+// Serve serves the request with the handler of the operation, and fails the test if the request is not valid or if
+// the response doesn't match the specification.
+func Serve(t testing.TB, operationID string, h http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	once.Do(func() {
+		spec, specErr = openapi.Load(match.OpenAPI)
+	})
+	if specErr != nil {
+		t.Fatalf("error loading the openapi specification: %s", specErr)
+	}
+
+	r := mux.NewRouter()
+	r.Use(spec.Validator(openapi.ValidatorOptions{
+		Responses: true,
+		OnResponseError: func(_ *http.Request, err error) {
+			t.Errorf("response mismatch: %s", err)
+		},
+	}))
+
+	var served bool
+	err := spec.Register(r, map[string]http.HandlerFunc{operationID: func(w http.ResponseWriter, r *http.Request) {
+		served = true
+		h(w, r)
+	}})
+	if err != nil {
+		t.Fatalf("error registering the operation: %s", err)
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if !served {
+		t.Errorf("request mismatch: %d %s", rr.Code, rr.Body.String())
+	}
+
+	return rr
+}
@@ -0,0 +1,2 @@
+// Placeholder of the Redoc bundle, replaced by the pinned release with 'make redoc'.
+document.body.textContent = "The Redoc bundle is not vendored: run 'make redoc' and rebuild the app.";
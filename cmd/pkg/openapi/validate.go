@@ -0,0 +1,233 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+)
+
+const defaultMaxBodySize = 64 << 20
+
+func init() {
+	// the bodies that are not JSON are validated as strings
+	for _, contentType := range []string{"application/jsonl", "text/csv", "application/geo+json"} {
+		openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+	}
+}
+
+// Violation is a part of a request that doesn't match the specification.
+type Violation struct {
+	// In is where the violation is: 'path', 'query', 'header' or 'body'.
+	In string `json:"in"`
+	// Name is the name of the parameter, or the JSON pointer of the body's field, if there is one.
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidatorOptions configures the validation of the requests and of the responses.
+type ValidatorOptions struct {
+	// Responses validates the responses too, which are then buffered, e.g. in the tests or in development.
+	Responses bool
+	// OnResponseError is called with the violations of the responses, e.g. to fail a test. Defaults to logging them.
+	OnResponseError func(r *http.Request, err error)
+	// MaxBodySize is the maximum size, in bytes, of the body of the requests. Defaults to 64 MiB.
+	MaxBodySize int64
+}
+
+// Validator returns a middleware that validates the requests to the routes registered by Register against their
+// operation, and answers the ones that don't match it with 400 Bad Request and their violations. The routes of no
+// operation, e.g. '/metrics', are not validated.
+//
+// The credentials are checked by the authenticator of the routes, not by the validator, which buffers the bodies:
+// in the app it wraps each operation's handler once the request is rate limited and authenticated.
+func (s *Spec) Validator(opts ValidatorOptions) mux.MiddlewareFunc {
+	if opts.OnResponseError == nil {
+		opts.OnResponseError = func(r *http.Request, err error) {
+			logging.FromContext(r.Context()).Error("response does not match the openapi specification", "error", err)
+		}
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxBodySize
+	}
+
+	filterOpts := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current := mux.CurrentRoute(r)
+			if current == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			path, item, op, ok := s.Operation(current.GetName())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodySize)
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    withContentType(r, op),
+				PathParams: mux.Vars(r),
+				Route:      &routers.Route{Spec: s.doc, Path: path, PathItem: item, Method: r.Method, Operation: op},
+				Options:    filterOpts,
+			}
+
+			err := openapi3filter.ValidateRequest(ctx, input)
+			if err != nil {
+				logging.FromContext(ctx).Warn("request does not match the openapi specification", "error", err)
+				writeViolations(w, violations(err))
+				return
+			}
+			// the body was read, and put back, by the validation
+			r.Body = input.Request.Body
+
+			if !opts.Responses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferWriter{header: make(http.Header), code: http.StatusOK}
+			next.ServeHTTP(bw, r)
+
+			err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 bw.code,
+				Header:                 bw.header,
+				Body:                   io.NopCloser(bytes.NewReader(bw.body.Bytes())),
+				Options:                filterOpts,
+			})
+			if err != nil {
+				opts.OnResponseError(r, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+			}
+
+			for h, v := range bw.header {
+				w.Header()[h] = v
+			}
+			w.WriteHeader(bw.code)
+			response.Write(w, bw.body.Bytes())
+		})
+	}
+}
+
+// withContentType returns the request with the content type of the operation's body when it has none and the
+// operation has a single one, since the clients that send JSON often leave it out.
+func withContentType(r *http.Request, op *openapi3.Operation) *http.Request {
+	if r.Header.Get("Content-Type") != "" || op.RequestBody == nil || op.RequestBody.Value == nil {
+		return r
+	}
+
+	content := op.RequestBody.Value.Content
+	if len(content) != 1 {
+		return r
+	}
+
+	r2 := r.Clone(r.Context())
+	for contentType := range content {
+		r2.Header.Set("Content-Type", contentType)
+	}
+	return r2
+}
+
+// violations returns the violations of the request's validation errors.
+func violations(err error) []Violation {
+	var errs openapi3.MultiError
+	if !errors.As(err, &errs) {
+		errs = openapi3.MultiError{err}
+	}
+
+	var vs []Violation
+	for _, err := range errs {
+		var reqErr *openapi3filter.RequestError
+		if !errors.As(err, &reqErr) {
+			vs = append(vs, Violation{In: "request", Reason: err.Error()})
+			continue
+		}
+
+		v := Violation{In: "body", Reason: reqErr.Reason}
+		if reqErr.Parameter != nil {
+			v.In = reqErr.Parameter.In
+			v.Name = reqErr.Parameter.Name
+		}
+
+		var schemaErrs openapi3.MultiError
+		if !errors.As(reqErr.Err, &schemaErrs) {
+			schemaErrs = openapi3.MultiError{reqErr.Err}
+		}
+
+		for _, e := range schemaErrs {
+			v := v
+
+			var schemaErr *openapi3.SchemaError
+			switch {
+			case errors.As(e, &schemaErr):
+				if v.In == "body" {
+					v.Name = "/" + strings.Join(schemaErr.JSONPointer(), "/")
+				}
+				v.Reason = schemaErr.Reason
+			case e != nil && v.Reason == "":
+				v.Reason = e.Error()
+			}
+
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+func writeViolations(w http.ResponseWriter, vs []Violation) {
+	b, err := json.Marshal(struct {
+		Error      string      `json:"error"`
+		Violations []Violation `json:"violations"`
+	}{"bad_request", vs})
+	if err != nil {
+		b = []byte(response.ErrBadRequest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	response.Write(w, b)
+}
+
+// bufferWriter is a http.ResponseWriter that buffers the response, to validate it before it is written.
+type bufferWriter struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
@@ -0,0 +1,164 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"match"
+	"match/cmd/pkg/openapi"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+)
+
+// validated returns a router with the operations' handlers, validated with the given options.
+func validated(t *testing.T, opts openapi.ValidatorOptions, hs map[string]http.HandlerFunc) *mux.Router {
+	t.Helper()
+
+	spec, err := openapi.Load(match.OpenAPI)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(spec.Validator(opts))
+	err = spec.Register(r, hs)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+	r.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	return r
+}
+
+func TestValidator_Requests(t *testing.T) {
+	var served int
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	}
+
+	r := validated(t, openapi.ValidatorOptions{}, map[string]http.HandlerFunc{"list_partners": ok, "match": ok, "create_job": ok})
+
+	for _, tc := range []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		code        int
+		violations  []openapi.Violation
+	}{
+		{method: http.MethodGet, path: "/partners?material=1&material=2&min_rating=3", code: http.StatusOK},
+		{method: http.MethodGet, path: "/metrics", code: http.StatusOK},
+		{
+			method: http.MethodGet, path: "/partners?min_rating=high", code: http.StatusBadRequest,
+			violations: []openapi.Violation{{In: "query", Name: "min_rating", Reason: `value high: an invalid integer: invalid syntax`}},
+		},
+		// the clients that send JSON often leave out its content type
+		{method: http.MethodPost, path: "/partners/match", body: `{"materials": [1], "address": {"lat": 1.1, "long": 1.2}}`, code: http.StatusOK},
+		{
+			method: http.MethodPost, path: "/partners/match", contentType: "application/json",
+			body: `{"materials": ["wood"], "address": {"lat": 1.1, "long": 1.2}}`, code: http.StatusBadRequest,
+			violations: []openapi.Violation{{In: "body", Name: "/materials/0", Reason: `Field must be set to integer or not be present`}},
+		},
+		{method: http.MethodPost, path: "/jobs", contentType: "application/jsonl", body: `{"materials": [1]}`, code: http.StatusOK},
+		{
+			method: http.MethodPost, path: "/jobs", contentType: "text/plain", body: `[]`, code: http.StatusBadRequest,
+			violations: []openapi.Violation{{In: "body", Reason: `header Content-Type has unexpected value "text/plain"`}},
+		},
+	} {
+		served = 0
+
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tc.code {
+			t.Errorf("%s %s status code mismatch: want %v got %v: %s", tc.method, tc.path, tc.code, rr.Code, rr.Body.String())
+		}
+
+		if tc.code != http.StatusBadRequest {
+			continue
+		}
+
+		if served != 0 {
+			t.Errorf("%s %s served mismatch: want 0 got %d", tc.method, tc.path, served)
+		}
+
+		var body struct {
+			Error      string              `json:"error"`
+			Violations []openapi.Violation `json:"violations"`
+		}
+		err := json.Unmarshal(rr.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		if body.Error != "bad_request" {
+			t.Errorf("%s %s error mismatch: want bad_request got %s", tc.method, tc.path, body.Error)
+		}
+
+		if diff := cmp.Diff(tc.violations, body.Violations); diff != "" {
+			t.Errorf("%s %s violations mismatch (-want +got):\n%s", tc.method, tc.path, diff)
+		}
+	}
+}
+
+func TestValidator_Responses(t *testing.T) {
+	var errs []error
+	opts := openapi.ValidatorOptions{
+		Responses:       true,
+		OnResponseError: func(_ *http.Request, err error) { errs = append(errs, err) },
+	}
+
+	r := validated(t, opts, map[string]http.HandlerFunc{
+		"get_partner": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			// the id is not an integer
+			_, _ = w.Write([]byte(`{"id": "3", "address": {"lat": 1.1, "long": 1.2}}`))
+		},
+		"get_job": func(w http.ResponseWriter, r *http.Request) {
+			// a status code that is not documented
+			w.WriteHeader(http.StatusTeapot)
+		},
+		"liveness": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status": "ok"}`))
+		},
+	})
+
+	for _, tc := range []struct {
+		path  string
+		code  int
+		valid bool
+	}{
+		{path: "/partners/3", code: http.StatusOK},
+		{path: "/jobs/0123456789abcdef0123456789abcdef", code: http.StatusTeapot},
+		{path: "/healthz", code: http.StatusOK, valid: true},
+	} {
+		errs = nil
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		// the response is still written, as it is
+		if rr.Code != tc.code {
+			t.Errorf("%s status code mismatch: want %v got %v", tc.path, tc.code, rr.Code)
+		}
+
+		if tc.valid && len(errs) > 0 {
+			t.Errorf("%s errors mismatch: want none got %v", tc.path, errs)
+		}
+
+		if !tc.valid && len(errs) != 1 {
+			t.Errorf("%s errors mismatch: want 1 got %v", tc.path, errs)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/response"
+	"match/cmd/pkg/logging"
+)
+
+// The headers of the rate limits, see https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/.
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// Options configures a Limiter.
+type Options struct {
+	// Default is the limit of the routes without their own. The zero Limit doesn't limit them.
+	Default Limit
+	// Routes are the limits of the routes, by their name.
+	Routes map[string]Limit
+
+	// TrustForwardedFor limits the requests without valid credentials by the first IP of their 'X-Forwarded-For'
+	// header, instead of by the address they come from. It must only be set behind a proxy that sets the header.
+	TrustForwardedFor bool
+
+	// Authenticator identifies the credentials of the requests, so the identified clients have limits of their own.
+	// Nil limits every request by its IP.
+	Authenticator Authenticator
+}
+
+// Authenticator identifies an API key or a JWT without looking it up in a store, see auth.Authenticator.Identify.
+type Authenticator interface {
+	Identify(ctx context.Context, key string) (auth.Principal, bool)
+}
+
+// Limiter limits the rate of the requests of each client to each route. The clients are told apart by their
+// principal, or by their IP if they are not identified, so made up credentials don't get fresh limits.
+type Limiter struct {
+	store Store
+	opts  Options
+
+	mu     sync.Mutex
+	routes map[string]bool
+}
+
+// New creates a new Limiter that counts the requests in the given store.
+func New(store Store, opts Options) *Limiter {
+	return &Limiter{store: store, opts: opts, routes: make(map[string]bool)}
+}
+
+// Limit returns a handler that serves the requests to the named route with next, as long as their client is within
+// the route's limit. It answers the others with 429 Too Many Requests and a 'Retry-After' header. Every response
+// has the 'RateLimit-*' headers of the route's limit.
+//
+// If the limits can't be checked, e.g. because the shared store is unavailable, the requests are let through.
+func (l *Limiter) Limit(route string, next http.HandlerFunc) http.HandlerFunc {
+	rt := l.Route(route)
+	if rt.limit.Unlimited() {
+		return next
+	}
+
+	policy := strconv.Itoa(rt.limit.Requests) + ";w=" + strconv.Itoa(int(rt.limit.Period.Seconds()))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// the identified principal is put in the request's context, so auth.Require doesn't authenticate it again
+		client := IPClient(l.ip(r))
+		if p, ok := l.Identify(ctx, auth.Credentials(r)); ok {
+			client = PrincipalClient(p)
+			r = r.WithContext(auth.WithPrincipal(ctx, p))
+		}
+
+		res, err := rt.Take(ctx, client)
+		if err != nil {
+			logging.FromContext(ctx).Error("error checking the rate limit", "route", route, "error", err)
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set(HeaderLimit, strconv.Itoa(rt.limit.Requests))
+		h.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+		h.Set(HeaderReset, strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set(HeaderPolicy, policy)
+
+		if !res.Allowed {
+			logging.FromContext(ctx).Warn("rate limited request", "route", route, "limit", rt.limit.String())
+			h.Set("Content-Type", "application/json")
+			response.WriteTooManyRequests(w, ceilSeconds(res.RetryAfter))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Route is the limit of a named route, to limit the requests that are not served by Limit, e.g. the gRPC calls or
+// the GraphQL fields that do the same as the route. The zero Route is unlimited.
+type Route struct {
+	name  string
+	limit Limit
+	store Store
+}
+
+// Route returns the limit of the named route, which is then a known route, see UnknownRoutes.
+func (l *Limiter) Route(name string) Route {
+	l.mu.Lock()
+	l.routes[name] = true
+	l.mu.Unlock()
+
+	limit, ok := l.opts.Routes[name]
+	if !ok {
+		limit = l.opts.Default
+	}
+	return Route{name: name, limit: limit, store: l.store}
+}
+
+// Limit returns the limit of the route.
+func (r Route) Limit() Limit {
+	return r.limit
+}
+
+// Take takes a request of the client, see PrincipalClient and IPClient, from its bucket for the route. A route
+// without a limit always allows it.
+func (r Route) Take(ctx context.Context, client string) (Result, error) {
+	if r.limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+	return r.store.Take(ctx, r.name+":"+client, r.limit)
+}
+
+// UnknownRoutes returns the routes with a limit that is not the limit of any route, e.g. because of a typo.
+func (l *Limiter) UnknownRoutes() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var unknown []string
+	for route := range l.opts.Routes {
+		if !l.routes[route] {
+			unknown = append(unknown, route)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Identify returns the principal of the credentials if the authenticator knows it without a lookup, e.g. from its
+// cache of valid API keys. The other clients, including the ones with valid API keys not cached yet, are limited by
+// their IP, so made up credentials neither get fresh limits nor query the store before they are limited.
+func (l *Limiter) Identify(ctx context.Context, credentials string) (auth.Principal, bool) {
+	if credentials == "" || l.opts.Authenticator == nil {
+		return auth.Principal{}, false
+	}
+	return l.opts.Authenticator.Identify(ctx, credentials)
+}
+
+// PrincipalClient returns the key of an identified client.
+func PrincipalClient(p auth.Principal) string {
+	return "principal:" + p.ID
+}
+
+// IPClient returns the key of a client that is not identified.
+func IPClient(ip string) string {
+	return "ip:" + ip
+}
+
+// ip returns the IP of the request's client.
+func (l *Limiter) ip(r *http.Request) string {
+	if l.opts.TrustForwardedFor {
+		first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// ceilSeconds returns the duration in whole seconds, rounded up, so a client that waits for it is not limited again.
+func ceilSeconds(d time.Duration) int {
+	s := int(d / time.Second)
+	if d%time.Second != 0 {
+		s++
+	}
+	return s
+}
@@ -0,0 +1,168 @@
+// Package ratelimit limits the rate of the requests of each client with token buckets, counted in memory or in a
+// store shared by all the instances of the app.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the time between sweeps of the full buckets of a MemoryStore.
+const sweepInterval = time.Minute
+
+// Limit is a number of requests allowed per period, e.g. 60 a minute. The requests are limited by a token bucket
+// that holds up to Requests tokens and is refilled at Requests per Period, so a burst of Requests is allowed once
+// the bucket was idle for a period. The zero Limit allows every request.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit as 'requests/period', e.g. '60/1m'. An empty string is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit '%s', must be requests/period, e.g. 60/1m", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit '%s', the requests must be a positive number", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit '%s', the period must be a positive duration, e.g. 1m", s)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Unlimited reports whether the limit allows every request.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// Rate returns the number of tokens the bucket is refilled with per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	// Allowed reports whether there was a token, i.e. whether the request is allowed.
+	Allowed bool
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// RetryAfter is the time until the next token, if the request was not allowed.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+// NewResult returns the result of taking a token from a bucket of the limit that has the given tokens left.
+func NewResult(l Limit, tokens float64, allowed bool) Result {
+	rate := l.Rate()
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(l.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
+
+// Store stores the token buckets, by key.
+type Store interface {
+	// Take refills the bucket of the key for the time since it was last taken from, and then takes a token from it,
+	// if it has one. A new bucket is full.
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// MemoryStore is a Store that keeps the buckets in memory, so the limits are per instance of the app. The buckets
+// that are full again are swept once in a while, since they are the same as no bucket.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweepAt time.Time
+}
+
+// NewMemoryStore creates a new, empty, MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, l Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Requests), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	rate := l.Rate()
+	b.tokens = math.Min(float64(l.Requests), b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.fullAt = now.Add(seconds((float64(l.Requests) - b.tokens) / rate))
+
+	return NewResult(l, b.tokens, allowed), nil
+}
+
+// Len returns the number of buckets.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+	s.sweepAt = now.Add(sweepInterval)
+
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
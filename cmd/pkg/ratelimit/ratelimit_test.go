@@ -0,0 +1,271 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLimit(t *testing.T) {
+	for s, expected := range map[string]ratelimit.Limit{
+		"":       {},
+		"60/1m":  {Requests: 60, Period: time.Minute},
+		"10/30s": {Requests: 10, Period: 30 * time.Second},
+	} {
+		l, err := ratelimit.ParseLimit(s)
+
+		if err != nil {
+			t.Errorf("error mismatch for '%s': want 'nil' got '%s'", s, err)
+		}
+
+		if l != expected {
+			t.Errorf("limit mismatch for '%s': want %v got %v", s, expected, l)
+		}
+	}
+
+	for _, s := range []string{"60", "0/1m", "-1/1m", "60/0s", "60/minute"} {
+		if _, err := ratelimit.ParseLimit(s); err == nil {
+			t.Errorf("error mismatch for '%s': want an invalid limit error got 'nil'", s)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	l := ratelimit.Limit{Requests: 2, Period: time.Hour}
+
+	for i, expected := range []ratelimit.Result{
+		{Allowed: true, Remaining: 1, Reset: 30 * time.Minute},
+		{Allowed: true, Remaining: 0, Reset: time.Hour},
+		{Allowed: false, Remaining: 0, RetryAfter: 30 * time.Minute, Reset: time.Hour},
+	} {
+		r, err := s.Take(context.Background(), "a", l)
+
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		// the buckets refill while the test runs
+		approx := cmp.Comparer(func(x, y time.Duration) bool { return x-y < time.Second && y-x < time.Second })
+		if diff := cmp.Diff(expected, r, approx); diff != "" {
+			t.Errorf("result %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	// the keys have their own buckets
+	r, _ := s.Take(context.Background(), "b", l)
+	if !r.Allowed {
+		t.Errorf("another key was limited")
+	}
+}
+
+func TestMemoryStore_Refill(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	l := ratelimit.Limit{Requests: 1, Period: 50 * time.Millisecond}
+
+	r, _ := s.Take(context.Background(), "a", l)
+	if !r.Allowed {
+		t.Fatalf("the first request was limited")
+	}
+
+	r, _ = s.Take(context.Background(), "a", l)
+	if r.Allowed {
+		t.Fatalf("the second request was allowed")
+	}
+
+	time.Sleep(r.RetryAfter)
+
+	r, _ = s.Take(context.Background(), "a", l)
+	if !r.Allowed {
+		t.Errorf("the request after the retry was limited")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("some error")
+}
+
+// serve serves a request to the named route of the limiter, returning the response and whether the route's handler
+// served it.
+func serve(l *ratelimit.Limiter, route string, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	var served bool
+	h := l.Limit(route, func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	})
+
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr, served
+}
+
+// keys identifies the API keys of its map, by their principal's id.
+type keys map[string]string
+
+func (k keys) Identify(_ context.Context, key string) (auth.Principal, bool) {
+	id, ok := k[key]
+	return auth.Principal{ID: id}, ok
+}
+
+func TestLimiter(t *testing.T) {
+	l := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Options{
+		Default:       ratelimit.Limit{Requests: 100, Period: time.Minute},
+		Routes:        map[string]ratelimit.Limit{"match": {Requests: 1, Period: time.Minute}},
+		Authenticator: keys{"mk_0123456789abcdef": "key:1"},
+	})
+
+	newRequest := func(headers map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/partners/match", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	rr, served := serve(l, "match", newRequest(nil))
+
+	if !served {
+		t.Errorf("the first request was limited")
+	}
+
+	for header, expected := range map[string]string{
+		"RateLimit-Limit":     "1",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    "1;w=60",
+	} {
+		if rr.Header().Get(header) != expected {
+			t.Errorf("%s header mismatch: want %v got %v", header, expected, rr.Header().Get(header))
+		}
+	}
+
+	rr, served = serve(l, "match", newRequest(nil))
+
+	if served {
+		t.Errorf("the second request was served")
+	}
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("Retry-After header mismatch: want 60 got %v", rr.Header().Get("Retry-After"))
+	}
+
+	expectedBody := `{"error":"too_many_requests"}`
+	if rr.Body.String() != expectedBody {
+		t.Errorf("body mismatch: want %v got %v", expectedBody, rr.Body.String())
+	}
+
+	// a client with an API key, from the same address, and another route have their own limits
+	_, served = serve(l, "match", newRequest(map[string]string{"X-API-Key": "mk_0123456789abcdef"}))
+	if !served {
+		t.Errorf("the request with an API key was limited")
+	}
+
+	// made up credentials share the limit of their address
+	for _, key := range []string{"mk_made_up_1", "mk_made_up_2"} {
+		_, served = serve(l, "match", newRequest(map[string]string{"Authorization": "Bearer " + key}))
+		if served {
+			t.Errorf("the request with the invalid key %s was served", key)
+		}
+	}
+
+	rr, served = serve(l, "jobs", newRequest(nil))
+	if !served {
+		t.Errorf("the request to another route was limited")
+	}
+
+	if rr.Header().Get("RateLimit-Limit") != "100" {
+		t.Errorf("RateLimit-Limit header mismatch: want the default 100 got %v", rr.Header().Get("RateLimit-Limit"))
+	}
+}
+
+// countingStore counts the API keys looked up, none of which exists.
+type countingStore struct {
+	lookups int
+}
+
+func (s *countingStore) GetAPIKeyByHash(context.Context, string) (models.APIKey, error) {
+	s.lookups++
+	return models.APIKey{}, repository.ErrNotFound
+}
+
+func TestLimiter_MadeUpKeys(t *testing.T) {
+	store := &countingStore{}
+	a := auth.NewAuthenticator(store, auth.Options{})
+	l := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Options{
+		Default:       ratelimit.Limit{Requests: 3, Period: time.Minute},
+		Authenticator: a,
+	})
+
+	h := l.Limit("match", a.Require(auth.ScopeMatch, func(w http.ResponseWriter, r *http.Request) {}))
+
+	// a flood of made up keys only looks up the keys of the requests within the limit of its address
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/partners/match", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-API-Key", fmt.Sprintf("mk_made_up_%d", i))
+
+		h(httptest.NewRecorder(), req)
+	}
+
+	if store.lookups != 3 {
+		t.Errorf("lookups mismatch: want 3 got %d", store.lookups)
+	}
+}
+
+func TestLimiter_ForwardedFor(t *testing.T) {
+	l := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Options{
+		Default:           ratelimit.Limit{Requests: 1, Period: time.Minute},
+		TrustForwardedFor: true,
+	})
+
+	// the proxy's address is the same, but the clients' are not
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/partners/match", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", ip+", 10.0.0.2")
+
+		_, served := serve(l, "match", req)
+		if !served {
+			t.Errorf("the request from %s was limited", ip)
+		}
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := ratelimit.New(failingStore{}, ratelimit.Options{
+		Routes: map[string]ratelimit.Limit{"match": {Requests: 1, Period: time.Minute}, "mtach": {Requests: 1, Period: time.Minute}},
+	})
+
+	// a route without a limit, when there is no default, is not limited
+	rr, served := serve(l, "jobs", httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	if !served || rr.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("the route without a limit was limited")
+	}
+
+	// the requests are let through when the limits can't be checked
+	_, served = serve(l, "match", httptest.NewRequest(http.MethodPost, "/partners/match", nil))
+	if !served {
+		t.Errorf("the request was not served when the store failed")
+	}
+
+	if diff := cmp.Diff([]string{"mtach"}, l.UnknownRoutes()); diff != "" {
+		t.Errorf("unknown routes mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"match/cmd/pkg/logging"
+)
+
+// Buckets stores the token buckets in a database shared by all the instances of the app, e.g. repository.Database.
+type Buckets interface {
+	// TakeToken refills the bucket of the key, which holds up to burst tokens, with rate tokens per second for the
+	// time since it was last taken from, and then takes a token from it, if it has one. It returns the tokens left
+	// and whether one was taken.
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error)
+
+	// DeleteFullBuckets deletes the buckets that have been full again for a while, since they are the same as no
+	// bucket.
+	DeleteFullBuckets(ctx context.Context) (int64, error)
+}
+
+// SharedStore is a Store that keeps the buckets in a shared database, so the limits hold across all the instances
+// of the app.
+type SharedStore struct {
+	buckets Buckets
+}
+
+// NewSharedStore creates a new SharedStore that keeps the buckets in the given database.
+func NewSharedStore(buckets Buckets) *SharedStore {
+	return &SharedStore{buckets: buckets}
+}
+
+func (s *SharedStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	tokens, allowed, err := s.buckets.TakeToken(ctx, key, l.Rate(), l.Requests)
+	if err != nil {
+		return Result{}, err
+	}
+	return NewResult(l, tokens, allowed), nil
+}
+
+// Run deletes the full buckets every interval until the context is done.
+func (s *SharedStore) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		_, err := s.buckets.DeleteFullBuckets(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("error deleting the full rate limit buckets", "error", err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"match/cmd/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey persists a new API key, returning it with its id.
+func (db *Database) CreateAPIKey(ctx context.Context, k models.APIKey) (models.APIKey, error) {
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Create(&k).
+			Error
+	})
+
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error trying to create the api key in the database: %w", err)
+	}
+
+	return k, nil
+}
+
+// GetAPIKeyByHash returns the API key, not revoked, with the given hash. It always reads from the primary, so a
+// revoked key is never accepted by a lagging replica.
+func (db *Database) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var k models.APIKey
+
+	err := db.read(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Where("hash = ? AND revoked_at IS NULL", hash).
+			First(&k).
+			Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.APIKey{}, ErrNotFound
+		}
+		return models.APIKey{}, fmt.Errorf("error trying to retrieve the api key from the database: %w", err)
+	}
+
+	return k, nil
+}
+
+// ListAPIKeys returns all the API keys, revoked ones included, in ascending order of id.
+func (db *Database) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var ks []models.APIKey
+
+	err := db.read(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Order("id").
+			Find(&ks).
+			Error
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error trying to list the api keys from the database: %w", err)
+	}
+
+	return ks, nil
+}
+
+// RevokeAPIKey revokes the API key with the given id. Returns ErrNotFound if there is no such key, or if it was
+// already revoked.
+func (db *Database) RevokeAPIKey(ctx context.Context, id uint) error {
+	var rows int64
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		res := db.handler.
+			WithContext(ctx).
+			Model(&models.APIKey{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now())
+		rows = res.RowsAffected
+		return res.Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to revoke the api key in the database: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
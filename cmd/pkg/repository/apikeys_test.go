@@ -0,0 +1,111 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	queryCreateAPIKey    = `INSERT INTO "api_keys" ("name","hash","role","scopes","partner_id","created_at","revoked_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`
+	queryGetAPIKeyByHash = `SELECT * FROM "api_keys" WHERE hash = $1 AND revoked_at IS NULL ORDER BY "api_keys"."id" LIMIT 1`
+	queryRevokeAPIKey    = `UPDATE "api_keys" SET "revoked_at"=$1 WHERE id = $2 AND revoked_at IS NULL`
+)
+
+func TestCreateAPIKey_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(queryCreateAPIKey)).
+		WithArgs("acme", "abc", "partner", `[]`, 3, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	partnerID := uint(3)
+	k, err := repo.CreateAPIKey(context.Background(), models.APIKey{Name: "acme", Hash: "abc", Role: "partner", PartnerID: &partnerID})
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if k.ID != 7 {
+		t.Errorf("id mismatch: want 7 got %v", k.ID)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestGetAPIKeyByHash_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetAPIKeyByHash)).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "hash", "role", "scopes"}).
+			AddRow(7, "shop", "abc", "client", `["match"]`))
+
+	k, err := repo.GetAPIKeyByHash(context.Background(), "abc")
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := models.APIKey{ID: 7, Name: "shop", Hash: "abc", Role: "client", Scopes: []string{"match"}}
+	if diff := cmp.Diff(expected, k); diff != "" {
+		t.Errorf("api key mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetAPIKeyByHash_NotFoundFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetAPIKeyByHash)).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repo.GetAPIKeyByHash(context.Background(), "abc")
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%v'", repository.ErrNotFound, err)
+	}
+}
+
+func TestRevokeAPIKey_NotFoundFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryRevokeAPIKey)).
+		WithArgs(sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := repo.RevokeAPIKey(context.Background(), 7)
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%v'", repository.ErrNotFound, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
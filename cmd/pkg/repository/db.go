@@ -4,115 +4,153 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"match/cmd/pkg/breaker"
 	"match/cmd/pkg/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrNotFound = errors.New("not found")
 )
 
+// streamBatchSize is the number of partners fetched from the database at a time when streaming partners.
+const streamBatchSize = 500
+
 // Database can communicate with the persistent storage.
 type Database struct {
 	handler *gorm.DB
+
+	queryTimeout time.Duration
+	readRetries  int
+	retryBackoff time.Duration
+	breaker      *breaker.Breaker
+	ranking      models.MatchRanking
+
+	replicas       []*replica
+	nextReplicaIdx uint32
+	replicaMaxLag  time.Duration
+	// primaryUntil is the time, in Unix nanoseconds, until which the partners are read from the primary.
+	primaryUntil int64
 }
 
-// NewDatabase creates a new instance of Database with the given SQL database handler.
-func NewDatabase(handler *gorm.DB) *Database {
-	return &Database{handler: handler}
+// NewDatabase creates a new instance of Database with the given SQL database handler and options.
+func NewDatabase(handler *gorm.DB, opts ...Option) *Database {
+	db := &Database{handler: handler}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
 }
 
 // GetMatches returns all partners that have a radius that cover given latitude and longitude values.
+// The partners, their categories and their materials are fetched in a single query.
 func (db *Database) GetMatches(ctx context.Context, materials []uint, lat, long float32) ([]models.Partner, error) {
-	var ps []models.Partner
+	var rows []matchRow
 
-	subQuery := db.handler.
-		WithContext(ctx).
-		Select("p1.id, haversine(p1.lat, p1.long, ?, ?) AS distance", lat, long).
-		Table("partners p1")
+	err := db.readReplica(ctx, func(ctx context.Context, handler *gorm.DB) error {
+		subQuery := handler.
+			WithContext(ctx).
+			Select("p1.id, haversine(p1.lat, p1.long, ?, ?) AS distance", lat, long).
+			Table("partners p1")
 
-	err := db.handler.
-		WithContext(ctx).
-		Select("p2.id, p2.lat, p2.long, p2.radius, p2.rating, sub.distance").
-		Table("partners p2").
-		Joins("JOIN materials ON materials.partner_id = p2.id AND materials.id IN (?)", materials).
-		Joins("JOIN (?) sub ON sub.id = p2.id", subQuery).
-		Where("sub.distance < p2.radius").
-		Group("p2.id, p2.rating, sub.distance").
-		Having("COUNT(DISTINCT materials.id) = ?", len(materials)).
-		Order("p2.rating desc, sub.distance asc").
-		Limit(10).
-		Find(&ps).
-		Error
+		return handler.
+			WithContext(ctx).
+			Select(
+				"p2.id, p2.lat, p2.long, p2.radius, p2.rating, sub.distance, (?) AS categories, (?) AS materials",
+				aggregate(handler, "categories"),
+				aggregate(handler, "materials"),
+			).
+			Table("partners p2").
+			Joins("JOIN materials ON materials.partner_id = p2.id AND materials.id IN (?)", materials).
+			Joins("JOIN (?) sub ON sub.id = p2.id", subQuery).
+			Where("sub.distance < p2.radius").
+			Group("p2.id, p2.rating, sub.distance").
+			Having("COUNT(DISTINCT materials.id) = ?", len(materials)).
+			Clauses(clause.OrderBy{Expression: db.matchesOrder()}).
+			Limit(db.ranking.MaxMatches()).
+			Find(&rows).
+			Error
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("error trying to retrieve the partners from the database: %w", err)
 	}
 
-	// if not matches were found just return
-	if len(ps) == 0 {
-		return ps, nil
-	}
-
-	var psIds []uint
-	for _, p := range ps {
-		psIds = append(psIds, p.ID)
-	}
+	ps := make([]models.Partner, 0, len(rows))
+	for _, r := range rows {
+		p := models.Partner{
+			ID:         r.ID,
+			Categories: r.Categories,
+			Materials:  r.Materials,
+			Address:    models.Address{Lat: r.Lat, Long: r.Long},
+			Radius:     r.Radius,
+			Rating:     r.Rating,
+		}
 
-	var cs []models.Category
-	err = db.handler.
-		WithContext(ctx).
-		Model(&models.Category{}).
-		Where("partner_id IN (?)", psIds).
-		Find(&cs).
-		Error
+		// the partner id is left out of the aggregation, since it is the same for all of them
+		for i := range p.Categories {
+			p.Categories[i].PartnerID = p.ID
+		}
+		for i := range p.Materials {
+			p.Materials[i].PartnerID = p.ID
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("error trying to retrieve the categories from the database: %w", err)
+		ps = append(ps, p)
 	}
 
-	var ms []models.Material
-	err = db.handler.
-		WithContext(ctx).
-		Model(&models.Material{}).
-		Where("partner_id IN (?)", psIds).
-		Find(&ms).
-		Error
+	return ps, nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("error trying to retrieve the materials from the database: %w", err)
+// matchesOrder returns the sorting of the matches, according to the ranking.
+func (db *Database) matchesOrder() clause.Expr {
+	if !db.ranking.Weighted() {
+		return clause.Expr{SQL: "p2.rating desc, sub.distance asc"}
 	}
 
-	for i := range ps {
-		for j := range cs {
-			if ps[i].ID == cs[j].PartnerID {
-				ps[i].Categories = append(ps[i].Categories, cs[j])
-			}
-		}
-		for j := range ms {
-			if ps[i].ID == ms[j].PartnerID {
-				ps[i].Materials = append(ps[i].Materials, ms[j])
-			}
-		}
+	return clause.Expr{
+		SQL:  "p2.rating * ? - sub.distance * ? desc, sub.distance asc",
+		Vars: []interface{}{db.ranking.RatingWeight, db.ranking.DistanceWeight},
 	}
+}
 
-	return ps, nil
+// matchRow is a partner returned by the GetMatches query, with its categories and materials aggregated as JSON.
+type matchRow struct {
+	ID         uint
+	Lat        float32
+	Long       float32
+	Radius     int
+	Rating     int
+	Categories []models.Category `gorm:"serializer:json"`
+	Materials  []models.Material `gorm:"serializer:json"`
+}
+
+// aggregate returns a subquery that aggregates, as a JSON array ordered by id, the rows of the given table, either
+// categories or materials, of the partner 'p2'. A partner without rows has an empty array instead of NULL.
+func aggregate(handler *gorm.DB, table string) *gorm.DB {
+	return handler.
+		Select("COALESCE(json_agg(json_build_object('id', t.id, 'description', t.description) ORDER BY t.id), '[]')").
+		Table(table + " t").
+		Where("t.partner_id = p2.id")
 }
 
 // GetPartnerById returns a partner by id.
 func (db *Database) GetPartnerById(ctx context.Context, id uint) (models.Partner, error) {
 	var p models.Partner
 
-	err := db.handler.
-		WithContext(ctx).
-		Model(&models.Partner{}).
-		Preload("Categories").
-		Preload("Materials").
-		Where("id = ?", id).
-		First(&p).
-		Error
+	err := db.readReplica(ctx, func(ctx context.Context, handler *gorm.DB) error {
+		return handler.
+			WithContext(ctx).
+			Model(&models.Partner{}).
+			Preload("Categories").
+			Preload("Materials").
+			Where("id = ?", id).
+			First(&p).
+			Error
+	})
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -123,3 +161,208 @@ func (db *Database) GetPartnerById(ctx context.Context, id uint) (models.Partner
 
 	return p, nil
 }
+
+// UpdatePartner updates the fields of a partner that are set, and returns the partner, with its categories and
+// materials, as updated. Returns ErrNotFound if there is no such partner.
+func (db *Database) UpdatePartner(ctx context.Context, id uint, u models.PartnerUpdate) (models.Partner, error) {
+	values := make(map[string]interface{})
+	if u.Address != nil {
+		values["lat"] = u.Address.Lat
+		values["long"] = u.Address.Long
+	}
+	if u.Radius != nil {
+		values["radius"] = *u.Radius
+	}
+
+	var p models.Partner
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Transaction(func(tx *gorm.DB) error {
+				res := tx.
+					Model(&models.Partner{}).
+					Where("id = ?", id).
+					Updates(values)
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					return gorm.ErrRecordNotFound
+				}
+
+				return tx.
+					Preload("Categories").
+					Preload("Materials").
+					Where("id = ?", id).
+					First(&p).
+					Error
+			})
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Partner{}, ErrNotFound
+		}
+		return models.Partner{}, fmt.Errorf("error trying to update the partner in the database: %w", err)
+	}
+
+	db.PartnersChanged()
+	return p, nil
+}
+
+// StreamPartners calls fn for every partner, with its categories and materials, in ascending order of id.
+// The partners are fetched in batches, so they are never all held in memory. If fn returns an error, the
+// streaming stops and the error is returned. They are always read from the primary, so they are up to date with
+// the changes notified by the database.
+func (db *Database) StreamPartners(ctx context.Context, fn func(p models.Partner) error) error {
+	var ps []models.Partner
+
+	err := db.stream(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Model(&models.Partner{}).
+			Preload("Categories").
+			Preload("Materials").
+			FindInBatches(&ps, streamBatchSize, func(tx *gorm.DB, batch int) error {
+				for _, p := range ps {
+					err := fn(p)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).
+			Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to stream the partners from the database: %w", err)
+	}
+
+	return nil
+}
+
+// ListPartners returns the partners, with their categories and materials, that match the given filter,
+// sorted and paginated as requested.
+func (db *Database) ListPartners(ctx context.Context, filter models.PartnerFilter) ([]models.Partner, error) {
+	var ps []models.Partner
+
+	err := db.readReplica(ctx, func(ctx context.Context, handler *gorm.DB) error {
+		return filterPartners(ctx, handler, filter).
+			Preload("Categories").
+			Preload("Materials").
+			Clauses(clause.OrderBy{Expression: partnersOrder(filter)}).
+			Limit(filter.Limit).
+			Offset(filter.Offset).
+			Find(&ps).
+			Error
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error trying to list the partners from the database: %w", err)
+	}
+
+	return ps, nil
+}
+
+// CountPartners returns the number of partners that match the given filter, ignoring sorting and pagination.
+func (db *Database) CountPartners(ctx context.Context, filter models.PartnerFilter) (int64, error) {
+	var n int64
+
+	err := db.readReplica(ctx, func(ctx context.Context, handler *gorm.DB) error {
+		return filterPartners(ctx, handler, filter).
+			Count(&n).
+			Error
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error trying to count the partners from the database: %w", err)
+	}
+
+	return n, nil
+}
+
+// filterPartners returns a query, on the given handler, for the partners that match the given filter.
+func filterPartners(ctx context.Context, handler *gorm.DB, filter models.PartnerFilter) *gorm.DB {
+	tx := handler.
+		WithContext(ctx).
+		Model(&models.Partner{})
+
+	if len(filter.IDs) > 0 {
+		tx = tx.Where("partners.id IN (?)", filter.IDs)
+	}
+
+	if len(filter.Materials) > 0 {
+		subQuery := handler.
+			Select("partner_id").
+			Table("materials").
+			Where("id IN (?)", filter.Materials).
+			Group("partner_id").
+			Having("COUNT(DISTINCT id) = ?", len(filter.Materials))
+		tx = tx.Where("partners.id IN (?)", subQuery)
+	}
+
+	if len(filter.Categories) > 0 {
+		subQuery := handler.
+			Select("partner_id").
+			Table("categories").
+			Where("id IN (?)", filter.Categories).
+			Group("partner_id").
+			Having("COUNT(DISTINCT id) = ?", len(filter.Categories))
+		tx = tx.Where("partners.id IN (?)", subQuery)
+	}
+
+	if filter.MinRating != nil {
+		tx = tx.Where("partners.rating >= ?", *filter.MinRating)
+	}
+
+	if filter.MaxRating != nil {
+		tx = tx.Where("partners.rating <= ?", *filter.MaxRating)
+	}
+
+	if filter.Near != nil {
+		tx = tx.Where(
+			"haversine(partners.lat, partners.long, ?, ?) <= ?",
+			filter.Near.Address.Lat,
+			filter.Near.Address.Long,
+			filter.Near.Distance,
+		)
+	}
+
+	if box := filter.Box; box != nil {
+		tx = tx.Where("partners.lat BETWEEN ? AND ?", box.MinLat, box.MaxLat)
+		if box.MinLong <= box.MaxLong {
+			tx = tx.Where("partners.long BETWEEN ? AND ?", box.MinLong, box.MaxLong)
+		} else {
+			tx = tx.Where("partners.long >= ? OR partners.long <= ?", box.MinLong, box.MaxLong)
+		}
+	}
+
+	return tx
+}
+
+// partnersOrder returns the sorting of the partners for the given filter.
+// Partners are always sorted by id last, which makes the pagination stable.
+func partnersOrder(filter models.PartnerFilter) clause.Expr {
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	switch filter.Sort {
+	case models.SortByRating:
+		return clause.Expr{SQL: "partners.rating " + direction + ", partners.id ASC"}
+	case models.SortByRadius:
+		return clause.Expr{SQL: "partners.radius " + direction + ", partners.id ASC"}
+	case models.SortByDistance:
+		if filter.Near != nil {
+			return clause.Expr{
+				SQL:  "haversine(partners.lat, partners.long, ?, ?) " + direction + ", partners.id ASC",
+				Vars: []interface{}{filter.Near.Address.Lat, filter.Near.Address.Long},
+			}
+		}
+	}
+
+	return clause.Expr{SQL: "partners.id " + direction}
+}
@@ -0,0 +1,132 @@
+//go:build integration
+// +build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/google/go-cmp/cmp"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openIntegrationDB(tb testing.TB) *gorm.DB {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatalf("error opening the database: '%s'", err)
+	}
+	return db
+}
+
+// getMatchesInThreeQueries gets the matches like GetMatches used to, with a query for the partners, one for their
+// categories and one for their materials, to compare both.
+func getMatchesInThreeQueries(ctx context.Context, db *gorm.DB, materials []uint, lat, long float32) ([]models.Partner, error) {
+	var ps []models.Partner
+
+	subQuery := db.
+		WithContext(ctx).
+		Select("p1.id, haversine(p1.lat, p1.long, ?, ?) AS distance", lat, long).
+		Table("partners p1")
+
+	err := db.
+		WithContext(ctx).
+		Select("p2.id, p2.lat, p2.long, p2.radius, p2.rating, sub.distance").
+		Table("partners p2").
+		Joins("JOIN materials ON materials.partner_id = p2.id AND materials.id IN (?)", materials).
+		Joins("JOIN (?) sub ON sub.id = p2.id", subQuery).
+		Where("sub.distance < p2.radius").
+		Group("p2.id, p2.rating, sub.distance").
+		Having("COUNT(DISTINCT materials.id) = ?", len(materials)).
+		Order("p2.rating desc, sub.distance asc").
+		Limit(10).
+		Find(&ps).
+		Error
+	if err != nil || len(ps) == 0 {
+		return ps, err
+	}
+
+	var psIds []uint
+	for _, p := range ps {
+		psIds = append(psIds, p.ID)
+	}
+
+	var cs []models.Category
+	err = db.WithContext(ctx).Model(&models.Category{}).Where("partner_id IN (?)", psIds).Order("id").Find(&cs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []models.Material
+	err = db.WithContext(ctx).Model(&models.Material{}).Where("partner_id IN (?)", psIds).Order("id").Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ps {
+		for j := range cs {
+			if ps[i].ID == cs[j].PartnerID {
+				ps[i].Categories = append(ps[i].Categories, cs[j])
+			}
+		}
+		for j := range ms {
+			if ps[i].ID == ms[j].PartnerID {
+				ps[i].Materials = append(ps[i].Materials, ms[j])
+			}
+		}
+	}
+
+	return ps, nil
+}
+
+func TestIntegrationGetMatches(t *testing.T) {
+	db := openIntegrationDB(t)
+	repo := repository.NewDatabase(db)
+
+	for _, materials := range [][]uint{{1}, {2}, {3}, {1, 2}, {1, 2, 3}} {
+		want, err := getMatchesInThreeQueries(context.Background(), db, materials, 1.1, 1.1)
+		if err != nil {
+			t.Fatalf("error getting matches: '%s'", err)
+		}
+
+		got, err := repo.GetMatches(context.Background(), materials, 1.1, 1.1)
+		if err != nil {
+			t.Fatalf("error getting matches: '%s'", err)
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%v: matches mismatch (-want +got):\n%s", materials, diff)
+		}
+	}
+}
+
+// BenchmarkIntegrationGetMatches compares GetMatches with the previous implementation, which used three queries.
+func BenchmarkIntegrationGetMatches(b *testing.B) {
+	db := openIntegrationDB(b)
+	repo := repository.NewDatabase(db)
+
+	b.Run("single query", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := repo.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.1)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("three queries", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := getMatchesInThreeQueries(context.Background(), db, []uint{1, 2}, 1.1, 1.1)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
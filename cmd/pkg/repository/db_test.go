@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"regexp"
+	"strings"
 	"testing"
 
 	"match/cmd/pkg/models"
@@ -20,9 +21,14 @@ const (
 	queryGetPartnerById           = `SELECT * FROM "partners" WHERE id = $1 ORDER BY "partners"."id" LIMIT 1`
 	queryGetCategoriesByPartnerId = `SELECT * FROM "categories" WHERE "categories"."partner_id" = $1`
 	queryGetMaterialsByPartnerId  = `SELECT * FROM "materials" WHERE "materials"."partner_id" = $1`
-	queryGetPartnersMatch         = `SELECT p2.id, p2.lat, p2.long, p2.radius, p2.rating, sub.distance FROM partners p2 JOIN materials ON materials.partner_id = p2.id AND materials.id IN ($1,$2) JOIN (SELECT p1.id, haversine(p1.lat, p1.long, $3, $4) AS distance FROM partners p1) sub ON sub.id = p2.id WHERE sub.distance < p2.radius GROUP BY p2.id, p2.rating, sub.distance HAVING COUNT(DISTINCT materials.id) = $5 ORDER BY p2.rating desc, sub.distance asc LIMIT 10`
-	queryGetCategoriesMatch       = `SELECT * FROM "categories" WHERE partner_id IN ($1)`
-	queryGetMaterialsMatch        = `SELECT * FROM "materials" WHERE partner_id IN ($1)`
+	queryUpdatePartner            = `UPDATE "partners" SET "lat"=$1,"long"=$2,"radius"=$3 WHERE id = $4`
+	queryGetPartnersMatch         = `SELECT p2.id, p2.lat, p2.long, p2.radius, p2.rating, sub.distance, (SELECT COALESCE(json_agg(json_build_object('id', t.id, 'description', t.description) ORDER BY t.id), '[]') FROM categories t WHERE t.partner_id = p2.id) AS categories, (SELECT COALESCE(json_agg(json_build_object('id', t.id, 'description', t.description) ORDER BY t.id), '[]') FROM materials t WHERE t.partner_id = p2.id) AS materials FROM partners p2 JOIN materials ON materials.partner_id = p2.id AND materials.id IN ($1,$2) JOIN (SELECT p1.id, haversine(p1.lat, p1.long, $3, $4) AS distance FROM partners p1) sub ON sub.id = p2.id WHERE sub.distance < p2.radius GROUP BY p2.id, p2.rating, sub.distance HAVING COUNT(DISTINCT materials.id) = $5 ORDER BY p2.rating desc, sub.distance asc LIMIT 10`
+	queryStreamPartners           = `SELECT * FROM "partners" ORDER BY "partners"."id" LIMIT 500`
+	queryGetCategoriesByPartners  = `SELECT * FROM "categories" WHERE "categories"."partner_id" IN ($1,$2)`
+	queryGetMaterialsByPartners   = `SELECT * FROM "materials" WHERE "materials"."partner_id" IN ($1,$2)`
+	queryListPartners             = `SELECT * FROM "partners" WHERE partners.id IN (SELECT partner_id FROM "materials" WHERE id IN ($1,$2) GROUP BY "partner_id" HAVING COUNT(DISTINCT id) = $3) AND partners.rating >= $4 AND haversine(partners.lat, partners.long, $5, $6) <= $7 AND (partners.lat BETWEEN $8 AND $9) AND (partners.long >= $10 OR partners.long <= $11) ORDER BY haversine(partners.lat, partners.long, $12, $13) ASC, partners.id ASC LIMIT 5 OFFSET 10`
+	queryListPartnersDefault      = `SELECT * FROM "partners" ORDER BY partners.id ASC LIMIT 20`
+	queryCountPartners            = `SELECT count(*) FROM "partners" WHERE partners.id IN (SELECT partner_id FROM "categories" WHERE id IN ($1) GROUP BY "partner_id" HAVING COUNT(DISTINCT id) = $2) AND partners.rating <= $3 AND (partners.lat BETWEEN $4 AND $5) AND (partners.long BETWEEN $6 AND $7)`
 )
 
 func initDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *gorm.DB) {
@@ -111,28 +117,22 @@ func TestGetMatches_Success(t *testing.T) {
 		Rating: 5,
 	}
 
-	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating", "distance"})
-	pRows.AddRow(pExpected.ID, pExpected.Address.Lat, pExpected.Address.Long, pExpected.Radius, pExpected.Rating, 1)
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating", "distance", "categories", "materials"})
+	pRows.AddRow(
+		pExpected.ID,
+		pExpected.Address.Lat,
+		pExpected.Address.Long,
+		pExpected.Radius,
+		pExpected.Rating,
+		1,
+		`[{"id": 2, "description": "category 2"}]`,
+		`[{"id": 3, "description": "material 3"}, {"id": 4, "description": "material 4"}]`,
+	)
 
 	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnersMatch)).
 		WithArgs(pExpected.Materials[0].ID, pExpected.Materials[1].ID, pExpected.Address.Lat, pExpected.Address.Long, len(pExpected.Materials)).
 		WillReturnRows(pRows)
 
-	cRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
-	cRows.AddRow(pExpected.Categories[0].ID, pExpected.Categories[0].PartnerID, pExpected.Categories[0].Description)
-
-	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesMatch)).
-		WithArgs(pExpected.ID).
-		WillReturnRows(cRows)
-
-	mRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
-	mRows.AddRow(pExpected.Materials[0].ID, pExpected.Materials[0].PartnerID, pExpected.Materials[0].Description)
-	mRows.AddRow(pExpected.Materials[1].ID, pExpected.Materials[1].PartnerID, pExpected.Materials[1].Description)
-
-	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsMatch)).
-		WithArgs(pExpected.ID).
-		WillReturnRows(mRows)
-
 	ps, err := repo.GetMatches(
 		context.Background(),
 		[]uint{pExpected.Materials[0].ID, pExpected.Materials[1].ID},
@@ -154,6 +154,69 @@ func TestGetMatches_Success(t *testing.T) {
 	}
 }
 
+func TestGetMatches_NoCategoriesOrMaterials(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating", "distance", "categories", "materials"})
+	pRows.AddRow(1, 1.1, 1.2, 100, 5, 1, `[]`, `[]`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnersMatch)).
+		WithArgs(1, 2, float32(1.1), float32(1.2), 2).
+		WillReturnRows(pRows)
+
+	ps, err := repo.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.2)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	psExpected := []models.Partner{
+		{
+			ID:         1,
+			Categories: []models.Category{},
+			Materials:  []models.Material{},
+			Address:    models.Address{Lat: 1.1, Long: 1.2},
+			Radius:     100,
+			Rating:     5,
+		},
+	}
+	if diff := cmp.Diff(psExpected, ps); diff != "" {
+		t.Errorf("guest list mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestGetMatches_Ranking(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	ranking := models.MatchRanking{RatingWeight: 10, DistanceWeight: 0.5, Limit: 3}
+	repo := repository.NewDatabase(handler, repository.WithMatchRanking(ranking))
+
+	query := strings.Replace(queryGetPartnersMatch, "ORDER BY p2.rating desc, sub.distance asc LIMIT 10",
+		"ORDER BY p2.rating * $6 - sub.distance * $7 desc, sub.distance asc LIMIT 3", 1)
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(1, 2, float32(1.1), float32(1.2), 2, 10.0, 0.5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating", "distance", "categories", "materials"}))
+
+	_, err := repo.GetMatches(context.Background(), []uint{1, 2}, 1.1, 1.2)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
 func TestGetPartnerById_NotFoundFailure(t *testing.T) {
 	db, mock, handler := initDB(t)
 	defer db.Close()
@@ -288,3 +351,416 @@ func TestGetPartnerById_Success(t *testing.T) {
 		t.Errorf("expectations were not met: '%s'", err)
 	}
 }
+
+func TestUpdatePartner_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	radius := 300
+	u := models.PartnerUpdate{Address: &models.Address{Lat: 1.5, Long: 2.5}, Radius: &radius}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryUpdatePartner)).
+		WithArgs(1.5, 2.5, 300, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"}).AddRow(1, 1.5, 2.5, 300, 4))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}).AddRow(3, 1, "material 3"))
+	mock.ExpectCommit()
+
+	p, err := repo.UpdatePartner(context.Background(), 1, u)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := models.Partner{
+		ID:         1,
+		Categories: []models.Category{},
+		Materials:  []models.Material{{ID: 3, PartnerID: 1, Description: "material 3"}},
+		Address:    models.Address{Lat: 1.5, Long: 2.5},
+		Radius:     300,
+		Rating:     4,
+	}
+	if diff := cmp.Diff(expected, p); diff != "" {
+		t.Errorf("partner mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestUpdatePartner_NotFoundFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	radius := 300
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "partners" SET "radius"=$1 WHERE id = $2`)).
+		WithArgs(300, 9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := repo.UpdatePartner(context.Background(), 9, models.PartnerUpdate{Radius: &radius})
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%v'", repository.ErrNotFound, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestStreamPartners_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	psExpected := []models.Partner{
+		{
+			ID: 1,
+			Categories: []models.Category{
+				{
+					ID:          2,
+					PartnerID:   1,
+					Description: "category 2",
+				},
+			},
+			Materials: []models.Material{
+				{
+					ID:          3,
+					PartnerID:   1,
+					Description: "material 3",
+				},
+			},
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+			Radius: 100,
+			Rating: 4,
+		},
+		{
+			ID:         2,
+			Categories: []models.Category{},
+			Materials: []models.Material{
+				{
+					ID:          3,
+					PartnerID:   2,
+					Description: "material 3",
+				},
+			},
+			Address: models.Address{
+				Lat:  2.1,
+				Long: 2.2,
+			},
+			Radius: 50,
+			Rating: 2,
+		},
+	}
+
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"})
+	for _, p := range psExpected {
+		pRows.AddRow(p.ID, p.Address.Lat, p.Address.Long, p.Radius, p.Rating)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryStreamPartners)).
+		WillReturnRows(pRows)
+
+	cRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
+	cRows.AddRow(psExpected[0].Categories[0].ID, psExpected[0].Categories[0].PartnerID, psExpected[0].Categories[0].Description)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartners)).
+		WithArgs(psExpected[0].ID, psExpected[1].ID).
+		WillReturnRows(cRows)
+
+	mRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
+	mRows.AddRow(psExpected[0].Materials[0].ID, psExpected[0].Materials[0].PartnerID, psExpected[0].Materials[0].Description)
+	mRows.AddRow(psExpected[1].Materials[0].ID, psExpected[1].Materials[0].PartnerID, psExpected[1].Materials[0].Description)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartners)).
+		WithArgs(psExpected[0].ID, psExpected[1].ID).
+		WillReturnRows(mRows)
+
+	var ps []models.Partner
+	err := repo.StreamPartners(context.Background(), func(p models.Partner) error {
+		ps = append(ps, p)
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff(psExpected, ps); diff != "" {
+		t.Errorf("partners mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestStreamPartners_CallbackFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"})
+	pRows.AddRow(1, 1.1, 1.2, 100, 4)
+	pRows.AddRow(2, 2.1, 2.2, 50, 2)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryStreamPartners)).
+		WillReturnRows(pRows)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartners)).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{}))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartners)).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{}))
+
+	errCallback := errors.New("some error")
+
+	var calls int
+	err := repo.StreamPartners(context.Background(), func(p models.Partner) error {
+		calls++
+		return errCallback
+	})
+
+	if !errors.Is(err, errCallback) {
+		t.Errorf("error mismatch: want '%s' got '%s'", errCallback, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("number of calls mismatch: want 1 got %d", calls)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestListPartners_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	minRating := 2
+	filter := models.PartnerFilter{
+		Materials: []uint{1, 2},
+		MinRating: &minRating,
+		Near: &models.Proximity{
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+			Distance: 50,
+		},
+		Box: &models.BoundingBox{
+			MinLat:  -1,
+			MinLong: 170,
+			MaxLat:  2,
+			MaxLong: -170,
+		},
+		Sort:   models.SortByDistance,
+		Limit:  5,
+		Offset: 10,
+	}
+
+	pExpected := models.Partner{
+		ID:         1,
+		Categories: []models.Category{},
+		Materials: []models.Material{
+			{
+				ID:          1,
+				PartnerID:   1,
+				Description: "material 1",
+			},
+			{
+				ID:          2,
+				PartnerID:   1,
+				Description: "material 2",
+			},
+		},
+		Address: models.Address{
+			Lat:  1.1,
+			Long: 179,
+		},
+		Radius: 100,
+		Rating: 4,
+	}
+
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"})
+	pRows.AddRow(pExpected.ID, pExpected.Address.Lat, pExpected.Address.Long, pExpected.Radius, pExpected.Rating)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryListPartners)).
+		WithArgs(1, 2, 2, minRating, float32(1.1), float32(1.2), 50, float32(-1), float32(2), float32(170), float32(-170), float32(1.1), float32(1.2)).
+		WillReturnRows(pRows)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartnerId)).
+		WithArgs(pExpected.ID).
+		WillReturnRows(sqlmock.NewRows([]string{}))
+
+	mRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
+	mRows.AddRow(pExpected.Materials[0].ID, pExpected.Materials[0].PartnerID, pExpected.Materials[0].Description)
+	mRows.AddRow(pExpected.Materials[1].ID, pExpected.Materials[1].PartnerID, pExpected.Materials[1].Description)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartnerId)).
+		WithArgs(pExpected.ID).
+		WillReturnRows(mRows)
+
+	ps, err := repo.ListPartners(context.Background(), filter)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff([]models.Partner{pExpected}, ps); diff != "" {
+		t.Errorf("partners mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestListPartners_ByIds(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	pRows := sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"})
+	pRows.AddRow(1, 1.1, 1.2, 10, 4)
+	pRows.AddRow(3, 2.1, 2.2, 20, 5)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "partners" WHERE partners.id IN ($1,$2) ORDER BY partners.id ASC LIMIT 2`)).
+		WithArgs(1, 3).
+		WillReturnRows(pRows)
+
+	// the categories and the materials of all the partners are loaded at once
+	cRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
+	cRows.AddRow(1, 3, "category 1")
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE "categories"."partner_id" IN ($1,$2)`)).
+		WithArgs(1, 3).
+		WillReturnRows(cRows)
+
+	mRows := sqlmock.NewRows([]string{"id", "partner_id", "description"})
+	mRows.AddRow(2, 1, "material 2")
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "materials" WHERE "materials"."partner_id" IN ($1,$2)`)).
+		WithArgs(1, 3).
+		WillReturnRows(mRows)
+
+	ps, err := repo.ListPartners(context.Background(), models.PartnerFilter{IDs: []uint{1, 3}, Limit: 2})
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := []models.Partner{
+		{
+			ID:         1,
+			Categories: []models.Category{},
+			Materials:  []models.Material{{ID: 2, PartnerID: 1, Description: "material 2"}},
+			Address:    models.Address{Lat: 1.1, Long: 1.2},
+			Radius:     10,
+			Rating:     4,
+		},
+		{
+			ID:         3,
+			Categories: []models.Category{{ID: 1, PartnerID: 3, Description: "category 1"}},
+			Materials:  []models.Material{},
+			Address:    models.Address{Lat: 2.1, Long: 2.2},
+			Radius:     20,
+			Rating:     5,
+		},
+	}
+	if diff := cmp.Diff(expected, ps); diff != "" {
+		t.Errorf("partners mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestListPartners_Failure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	errQuery := errors.New("some error")
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryListPartnersDefault)).
+		WillReturnError(errQuery)
+
+	_, err := repo.ListPartners(context.Background(), models.PartnerFilter{Limit: 20})
+
+	if !errors.Is(err, errQuery) {
+		t.Errorf("error mismatch: want '%s' got '%s'", errQuery, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestCountPartners_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	maxRating := 3
+	filter := models.PartnerFilter{
+		Categories: []uint{1},
+		MaxRating:  &maxRating,
+		Box: &models.BoundingBox{
+			MinLat:  -1,
+			MinLong: -2,
+			MaxLat:  1,
+			MaxLong: 2,
+		},
+		Limit:  5,
+		Offset: 10,
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryCountPartners)).
+		WithArgs(1, 1, maxRating, float32(-1), float32(1), float32(-2), float32(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	n, err := repo.CountPartners(context.Background(), filter)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if n != 7 {
+		t.Errorf("count mismatch: want 7 got %d", n)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMigrationsMissing is returned when the database lacks some of the tables, indexes, columns or functions created
+// by the scripts of the '/scripts/db' directory.
+var ErrMigrationsMissing = errors.New("database migrations missing")
+
+var (
+	// migratedRelations are the tables and indexes created by the scripts of the '/scripts/db' directory.
+	migratedRelations = []string{
+		"partners", "categories", "materials",
+		"jobs", "jobs_status_created_at_idx", "job_results", "api_keys", "rate_limits",
+		"idempotency_keys", "idempotency_keys_expires_at_idx",
+		"categories_partner_id_idx", "materials_partner_id_idx",
+	}
+
+	// migratedColumns are the columns, as 'table.column', added to existing tables by the scripts of the
+	// '/scripts/db' directory.
+	migratedColumns = []string{"api_keys.partner_id", "jobs.owner"}
+
+	// migratedFunctions are the functions created by the scripts of the '/scripts/db' directory.
+	migratedFunctions = []string{"haversine", "notify_partner_change"}
+)
+
+// Ping checks that the primary database is reachable.
+func (db *Database) Ping(ctx context.Context) error {
+	sqlDB, err := db.handler.DB()
+	if err != nil {
+		return fmt.Errorf("error trying to get the database connection pool: %w", err)
+	}
+
+	return db.run(ctx, true, func(ctx context.Context) error {
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// CheckMigrations checks that the database has every table, index, column and function created by the scripts of
+// the '/scripts/db' directory, returning ErrMigrationsMissing with the missing ones otherwise.
+func (db *Database) CheckMigrations(ctx context.Context) error {
+	var found []string
+	err := db.read(ctx, func(ctx context.Context) error {
+		return db.handler.WithContext(ctx).
+			Raw("SELECT relname FROM pg_class WHERE relname IN ? AND pg_table_is_visible(oid) "+
+				"UNION SELECT relname || '.' || attname FROM pg_attribute JOIN pg_class ON pg_class.oid = attrelid "+
+				"WHERE relname || '.' || attname IN ? AND NOT attisdropped AND pg_table_is_visible(pg_class.oid) "+
+				"UNION SELECT proname FROM pg_proc WHERE proname IN ? AND pg_function_is_visible(oid)",
+				migratedRelations, migratedColumns, migratedFunctions).
+			Scan(&found).
+			Error
+	})
+	if err != nil {
+		return fmt.Errorf("error trying to check the migrations of the database: %w", err)
+	}
+
+	exists := map[string]bool{}
+	for _, name := range found {
+		exists[name] = true
+	}
+
+	var missing []string
+	for _, name := range append(append(append([]string{}, migratedRelations...), migratedColumns...), migratedFunctions...) {
+		if !exists[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMigrationsMissing, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const queryCheckMigrations = `SELECT relname FROM pg_class WHERE relname IN ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) AND pg_table_is_visible(oid) UNION SELECT relname || '.' || attname FROM pg_attribute JOIN pg_class ON pg_class.oid = attrelid WHERE relname || '.' || attname IN ($13,$14) AND NOT attisdropped AND pg_table_is_visible(pg_class.oid) UNION SELECT proname FROM pg_proc WHERE proname IN ($15,$16) AND pg_function_is_visible(oid)`
+
+func TestCheckMigrations_Applied(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryCheckMigrations)).
+		WithArgs("partners", "categories", "materials", "jobs", "jobs_status_created_at_idx", "job_results", "api_keys", "rate_limits",
+			"idempotency_keys", "idempotency_keys_expires_at_idx", "categories_partner_id_idx", "materials_partner_id_idx", "api_keys.partner_id", "jobs.owner", "haversine", "notify_partner_change").
+		WillReturnRows(sqlmock.NewRows([]string{"relname"}).
+			AddRow("partners").AddRow("categories").AddRow("materials").
+			AddRow("jobs").AddRow("jobs_status_created_at_idx").AddRow("job_results").AddRow("api_keys").AddRow("rate_limits").
+			AddRow("idempotency_keys").AddRow("idempotency_keys_expires_at_idx").
+			AddRow("categories_partner_id_idx").AddRow("materials_partner_id_idx").AddRow("api_keys.partner_id").AddRow("jobs.owner").
+			AddRow("haversine").AddRow("notify_partner_change"))
+
+	err := repo.CheckMigrations(context.Background())
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestCheckMigrations_Missing(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryCheckMigrations)).
+		WillReturnRows(sqlmock.NewRows([]string{"relname"}).
+			AddRow("partners").AddRow("categories").AddRow("materials").
+			AddRow("jobs").AddRow("jobs_status_created_at_idx").AddRow("job_results").AddRow("api_keys").AddRow("rate_limits").
+			AddRow("idempotency_keys").AddRow("idempotency_keys_expires_at_idx").
+			AddRow("haversine").AddRow("notify_partner_change"))
+
+	err := repo.CheckMigrations(context.Background())
+
+	if !errors.Is(err, repository.ErrMigrationsMissing) {
+		t.Errorf("error mismatch: want '%s' got '%v'", repository.ErrMigrationsMissing, err)
+	}
+
+	expected := "database migrations missing: categories_partner_id_idx, materials_partner_id_idx, api_keys.partner_id, jobs.owner"
+	if err != nil && err.Error() != expected {
+		t.Errorf("error mismatch: want '%s' got '%s'", expected, err)
+	}
+}
+
+func TestPing_Unavailable(t *testing.T) {
+	// a replica's stub also monitors the pings
+	mock, handler := initReplica(t)
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	err := repo.Ping(context.Background())
+
+	if err == nil {
+		t.Errorf("error mismatch: want an error got 'nil'")
+	}
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"match/cmd/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// queryBeginIdempotentRequest inserts a request in progress, unless its key has a request that did not expire yet,
+// returning its key if it did.
+const queryBeginIdempotentRequest = `INSERT INTO idempotency_keys AS k (client, key, fingerprint, expires_at) ` +
+	`VALUES (?, ?, ?, now() + make_interval(secs => ?)) ` +
+	`ON CONFLICT (client, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, headers = '{}', ` +
+	`body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at ` +
+	`WHERE k.expires_at <= now() ` +
+	`RETURNING k.key`
+
+// BeginIdempotentRequest records that the request of the client's idempotency key is in progress, until it is
+// completed or released or, if neither happens, e.g. because the app crashed, for the lock duration. It returns
+// true if it did, or false and the request already recorded for the key otherwise.
+//
+// The idempotency keys are not a write of the request, so they don't make its reads go to the primary.
+func (db *Database) BeginIdempotentRequest(ctx context.Context, client, key, fingerprint string, lock time.Duration) (models.IdempotencyKey, bool, error) {
+	var k models.IdempotencyKey
+	var started []string
+
+	err := db.run(ctx, true, func(ctx context.Context) error {
+		err := db.handler.
+			WithContext(ctx).
+			Raw(queryBeginIdempotentRequest, client, key, fingerprint, lock.Seconds()).
+			Scan(&started).
+			Error
+		if err != nil || len(started) > 0 {
+			return err
+		}
+
+		return db.handler.
+			WithContext(ctx).
+			Where("client = ? AND key = ?", client, key).
+			First(&k).
+			Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// the request expired, and was deleted, in between
+			err = fmt.Errorf("idempotency key expired concurrently: %w", err)
+		}
+		return models.IdempotencyKey{}, false, fmt.Errorf("error trying to begin the idempotent request in the database: %w", err)
+	}
+
+	if len(started) > 0 {
+		return models.IdempotencyKey{Client: client, Key: key, Fingerprint: fingerprint}, true, nil
+	}
+	return k, false, nil
+}
+
+// CompleteIdempotentRequest records the response of the request of the client's idempotency key, which is replayed
+// to its retries for the given time.
+func (db *Database) CompleteIdempotentRequest(ctx context.Context, client, key string, code int, headers map[string]string, body []byte, ttl time.Duration) error {
+	h, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("error trying to marshal the headers of the idempotent request: %w", err)
+	}
+
+	err = db.run(ctx, true, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Model(&models.IdempotencyKey{}).
+			Where("client = ? AND key = ?", client, key).
+			Updates(map[string]interface{}{
+				"status_code": code,
+				"headers":     string(h),
+				"body":        body,
+				"expires_at":  gorm.Expr("now() + make_interval(secs => ?)", ttl.Seconds()),
+			}).
+			Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to complete the idempotent request in the database: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotentRequest deletes the request in progress of the client's idempotency key, e.g. because it failed,
+// so it can be retried.
+func (db *Database) ReleaseIdempotentRequest(ctx context.Context, client, key string) error {
+	err := db.run(ctx, true, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Exec("DELETE FROM idempotency_keys WHERE client = ? AND key = ? AND status_code IS NULL", client, key).
+			Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to release the idempotent request in the database: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes the expired idempotency keys, and returns how many were deleted.
+func (db *Database) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	var n int64
+
+	err := db.run(ctx, true, func(ctx context.Context) error {
+		res := db.handler.
+			WithContext(ctx).
+			Exec("DELETE FROM idempotency_keys WHERE expires_at <= now()")
+		n = res.RowsAffected
+		return res.Error
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error trying to delete the expired idempotency keys from the database: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,167 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	queryBeginIdempotentRequest    = `INSERT INTO idempotency_keys AS k (client, key, fingerprint, expires_at) VALUES ($1, $2, $3, now() + make_interval(secs => $4)) ON CONFLICT (client, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, headers = '{}', body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at WHERE k.expires_at <= now() RETURNING k.key`
+	queryGetIdempotencyKey         = `SELECT * FROM "idempotency_keys" WHERE client = $1 AND key = $2 ORDER BY "idempotency_keys"."client" LIMIT 1`
+	queryCompleteIdempotentRequest = `UPDATE "idempotency_keys" SET "body"=$1,"expires_at"=now() + make_interval(secs => $2),"headers"=$3,"status_code"=$4 WHERE client = $5 AND key = $6`
+	queryReleaseIdempotentRequest  = `DELETE FROM idempotency_keys WHERE client = $1 AND key = $2 AND status_code IS NULL`
+	queryDeleteExpiredIdempotency  = `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+)
+
+func TestBeginIdempotentRequest_Started(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryBeginIdempotentRequest)).
+		WithArgs("client:acme", "key-1", "abc", 120.0).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("key-1"))
+
+	k, started, err := repo.BeginIdempotentRequest(context.Background(), "client:acme", "key-1", "abc", 2*time.Minute)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if !started {
+		t.Errorf("started mismatch: want true got false")
+	}
+
+	expected := models.IdempotencyKey{Client: "client:acme", Key: "key-1", Fingerprint: "abc"}
+	if diff := cmp.Diff(expected, k); diff != "" {
+		t.Errorf("idempotency key mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestBeginIdempotentRequest_Existing(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	now := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryBeginIdempotentRequest)).
+		WithArgs("client:acme", "key-1", "abc", 120.0).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetIdempotencyKey)).
+		WithArgs("client:acme", "key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"client", "key", "fingerprint", "status_code", "headers", "body", "created_at", "expires_at"}).
+			AddRow("client:acme", "key-1", "abc", 201, `{"Content-Type":"application/json"}`, []byte(`{"id":"1"}`), now, now.Add(24*time.Hour)))
+
+	k, started, err := repo.BeginIdempotentRequest(context.Background(), "client:acme", "key-1", "abc", 2*time.Minute)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if started {
+		t.Errorf("started mismatch: want false got true")
+	}
+
+	code := 201
+	expected := models.IdempotencyKey{
+		Client:      "client:acme",
+		Key:         "key-1",
+		Fingerprint: "abc",
+		StatusCode:  &code,
+		Headers:     map[string]string{"Content-Type": "application/json"},
+		Body:        []byte(`{"id":"1"}`),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(24 * time.Hour),
+	}
+	if diff := cmp.Diff(expected, k); diff != "" {
+		t.Errorf("idempotency key mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestCompleteIdempotentRequest_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryCompleteIdempotentRequest)).
+		WithArgs([]byte(`{"id":"1"}`), 86400.0, `{"Content-Type":"application/json"}`, 201, "client:acme", "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.CompleteIdempotentRequest(context.Background(), "client:acme", "key-1", 201,
+		map[string]string{"Content-Type": "application/json"}, []byte(`{"id":"1"}`), 24*time.Hour)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestReleaseIdempotentRequest_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectExec(regexp.QuoteMeta(queryReleaseIdempotentRequest)).
+		WithArgs("client:acme", "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.ReleaseIdempotentRequest(context.Background(), "client:acme", "key-1")
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestDeleteExpiredIdempotencyKeys_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectExec(regexp.QuoteMeta(queryDeleteExpiredIdempotency)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repo.DeleteExpiredIdempotencyKeys(context.Background())
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if n != 3 {
+		t.Errorf("deleted mismatch: want 3 got %d", n)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
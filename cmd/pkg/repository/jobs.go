@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"match/cmd/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jobResultsBatchSize is the number of job results fetched from the database at a time.
+const jobResultsBatchSize = 500
+
+// CreateJob persists a new pending job of the owner for the given match requests.
+func (db *Database) CreateJob(ctx context.Context, owner string, reqs []models.MatchRequest) (models.Job, error) {
+	id, err := newJobId()
+	if err != nil {
+		return models.Job{}, fmt.Errorf("error trying to generate the job id: %w", err)
+	}
+
+	j := models.Job{
+		ID:       id,
+		Owner:    owner,
+		Status:   models.JobPending,
+		Requests: reqs,
+		Total:    len(reqs),
+	}
+
+	err = db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Create(&j).
+			Error
+	})
+
+	if err != nil {
+		return models.Job{}, fmt.Errorf("error trying to create the job in the database: %w", err)
+	}
+
+	return j, nil
+}
+
+// GetJob returns a job by id, without its requests.
+func (db *Database) GetJob(ctx context.Context, id string) (models.Job, error) {
+	var j models.Job
+
+	err := db.read(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Model(&models.Job{}).
+			Omit("requests").
+			Where("id = ?", id).
+			First(&j).
+			Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Job{}, ErrNotFound
+		}
+		return models.Job{}, fmt.Errorf("error trying to retrieve the job from the database: %w", err)
+	}
+
+	return j, nil
+}
+
+// ClaimJob marks the oldest pending job, or the oldest running job whose lock expired, as running and locked
+// until the given time, and returns it with its requests. Returns ErrNotFound if there is no job to claim.
+//
+// A running job's lock expires when the worker processing it stops without finishing it, e.g. on a restart,
+// which allows another worker to resume it.
+func (db *Database) ClaimJob(ctx context.Context, lockedUntil time.Time) (models.Job, error) {
+	var js []models.Job
+
+	now := time.Now()
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Raw(
+				`UPDATE jobs SET status = ?, locked_until = ?, updated_at = ? WHERE id = (`+
+					`SELECT id FROM jobs WHERE status = ? OR (status = ? AND locked_until < ?) `+
+					`ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED`+
+					`) RETURNING *`,
+				models.JobRunning, lockedUntil, now, models.JobPending, models.JobRunning, now,
+			).
+			Scan(&js).
+			Error
+	})
+
+	if err != nil {
+		return models.Job{}, fmt.Errorf("error trying to claim a job from the database: %w", err)
+	}
+
+	if len(js) == 0 {
+		return models.Job{}, ErrNotFound
+	}
+
+	return js[0], nil
+}
+
+// SaveJobResults persists the results of the job's requests starting at the given offset, updates the job's
+// progress and extends its lock until the given time.
+// Results that were already persisted, e.g. by a worker that stopped before updating the progress, are kept.
+func (db *Database) SaveJobResults(ctx context.Context, id string, offset int, results []models.MatchResult, lockedUntil time.Time) error {
+	jrs := make([]models.JobResult, 0, len(results))
+	for i, r := range results {
+		jrs = append(jrs, models.JobResult{JobID: id, Index: offset + i, Result: r})
+	}
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Transaction(func(tx *gorm.DB) error {
+				if len(jrs) > 0 {
+					err := tx.
+						Clauses(clause.OnConflict{DoNothing: true}).
+						Create(&jrs).
+						Error
+					if err != nil {
+						return err
+					}
+				}
+
+				return tx.
+					Model(&models.Job{}).
+					Where("id = ?", id).
+					Updates(map[string]interface{}{
+						"processed":    offset + len(results),
+						"locked_until": lockedUntil,
+						"updated_at":   time.Now(),
+					}).
+					Error
+			})
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to save the job results in the database: %w", err)
+	}
+
+	return nil
+}
+
+// FinishJob marks the job as done, or as failed if the given error message is not empty, and releases its lock.
+func (db *Database) FinishJob(ctx context.Context, id string, errMsg string) error {
+	status := models.JobDone
+	if errMsg != "" {
+		status = models.JobFailed
+	}
+
+	err := db.write(ctx, func(ctx context.Context) error {
+		return db.handler.
+			WithContext(ctx).
+			Model(&models.Job{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"error":        errMsg,
+				"locked_until": nil,
+				"updated_at":   time.Now(),
+			}).
+			Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("error trying to finish the job in the database: %w", err)
+	}
+
+	return nil
+}
+
+// StreamJobResults calls fn for every result of the job, in the same order as the job's requests.
+// The results are fetched in batches, so they are never all held in memory. If fn returns an error, the
+// streaming stops and the error is returned.
+func (db *Database) StreamJobResults(ctx context.Context, id string, fn func(r models.MatchResult) error) error {
+	var next int
+
+	for {
+		var jrs []models.JobResult
+
+		// each batch is a query of its own, so it is retried like any other read
+		err := db.read(ctx, func(ctx context.Context) error {
+			return db.handler.
+				WithContext(ctx).
+				Model(&models.JobResult{}).
+				Where("job_id = ? AND idx >= ?", id, next).
+				Order("idx").
+				Limit(jobResultsBatchSize).
+				Find(&jrs).
+				Error
+		})
+
+		if err != nil {
+			return fmt.Errorf("error trying to stream the job results from the database: %w", err)
+		}
+
+		for _, jr := range jrs {
+			err = fn(jr.Result)
+			if err != nil {
+				return fmt.Errorf("error trying to stream the job results from the database: %w", err)
+			}
+		}
+
+		if len(jrs) < jobResultsBatchSize {
+			return nil
+		}
+
+		next = jrs[len(jrs)-1].Index + 1
+	}
+}
+
+// newJobId returns a random, hard to guess, job id.
+func newJobId() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
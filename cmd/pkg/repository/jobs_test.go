@@ -0,0 +1,346 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+	"gorm.io/gorm"
+)
+
+const (
+	queryCreateJob        = `INSERT INTO "jobs" ("id","owner","status","requests","total","processed","error","locked_until","created_at","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
+	queryGetJob           = `SELECT "jobs"."id","jobs"."owner","jobs"."status","jobs"."total","jobs"."processed","jobs"."error","jobs"."locked_until","jobs"."created_at","jobs"."updated_at" FROM "jobs" WHERE id = $1 ORDER BY "jobs"."id" LIMIT 1`
+	queryClaimJob         = `UPDATE jobs SET status = $1, locked_until = $2, updated_at = $3 WHERE id = (SELECT id FROM jobs WHERE status = $4 OR (status = $5 AND locked_until < $6) ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *`
+	queryCreateJobResults = `INSERT INTO "job_results" ("job_id","idx","result") VALUES ($1,$2,$3),($4,$5,$6) ON CONFLICT DO NOTHING`
+	queryUpdateJobResults = `UPDATE "jobs" SET "locked_until"=$1,"processed"=$2,"updated_at"=$3 WHERE id = $4`
+	queryFinishJob        = `UPDATE "jobs" SET "error"=$1,"locked_until"=$2,"status"=$3,"updated_at"=$4 WHERE id = $5`
+	queryGetJobResults    = `SELECT * FROM "job_results" WHERE job_id = $1 AND idx >= $2 ORDER BY idx LIMIT 500`
+)
+
+var jobColumns = []string{"id", "owner", "status", "requests", "total", "processed", "error", "locked_until", "created_at", "updated_at"}
+
+func TestCreateJob_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	reqs := []models.MatchRequest{
+		{
+			Materials: []uint{1},
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryCreateJob)).
+		WithArgs(sqlmock.AnyArg(), "key:1", models.JobPending, `[{"materials":[1],"address":{"lat":1.1,"long":1.2},"square_meters":0,"phone_number":""}]`, 1, 0, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	j, err := repo.CreateJob(context.Background(), "key:1", reqs)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(j.ID) {
+		t.Errorf("invalid job id: %v", j.ID)
+	}
+
+	if j.Owner != "key:1" || j.Status != models.JobPending || j.Total != 1 || j.Processed != 0 {
+		t.Errorf("job mismatch: got %+v", j)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestGetJob_NotFoundFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetJob)).
+		WithArgs("abc").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err := repo.GetJob(context.Background(), "abc")
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestGetJob_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	now := time.Now().UTC()
+	jExpected := models.Job{
+		ID:        "abc",
+		Owner:     "key:1",
+		Status:    models.JobRunning,
+		Total:     10,
+		Processed: 5,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "owner", "status", "total", "processed", "error", "locked_until", "created_at", "updated_at"})
+	rows.AddRow(jExpected.ID, jExpected.Owner, jExpected.Status, jExpected.Total, jExpected.Processed, "", nil, now, now)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetJob)).
+		WithArgs(jExpected.ID).
+		WillReturnRows(rows)
+
+	j, err := repo.GetJob(context.Background(), jExpected.ID)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff(jExpected, j); diff != "" {
+		t.Errorf("job mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestClaimJob_NoJobs(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	lockedUntil := time.Now().Add(time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryClaimJob)).
+		WithArgs(models.JobRunning, lockedUntil, sqlmock.AnyArg(), models.JobPending, models.JobRunning, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(jobColumns))
+
+	_, err := repo.ClaimJob(context.Background(), lockedUntil)
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestClaimJob_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	now := time.Now().UTC()
+	lockedUntil := now.Add(time.Minute)
+
+	jExpected := models.Job{
+		ID:     "abc",
+		Owner:  "key:1",
+		Status: models.JobRunning,
+		Requests: []models.MatchRequest{
+			{
+				Materials: []uint{1, 2},
+				Address: models.Address{
+					Lat:  1.1,
+					Long: 1.2,
+				},
+			},
+		},
+		Total:       1,
+		LockedUntil: &lockedUntil,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	rows := sqlmock.NewRows(jobColumns)
+	rows.AddRow(jExpected.ID, jExpected.Owner, jExpected.Status, `[{"materials":[1,2],"address":{"lat":1.1,"long":1.2}}]`, 1, 0, "", lockedUntil, now, now)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryClaimJob)).
+		WithArgs(models.JobRunning, lockedUntil, sqlmock.AnyArg(), models.JobPending, models.JobRunning, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+
+	j, err := repo.ClaimJob(context.Background(), lockedUntil)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff(jExpected, j); diff != "" {
+		t.Errorf("job mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestSaveJobResults_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	lockedUntil := time.Now().Add(time.Minute)
+	results := []models.MatchResult{
+		{Partners: []models.Partner{}},
+		{Error: "bad_request"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryCreateJobResults)).
+		WithArgs("abc", 100, `{"partners":[]}`, "abc", 101, `{"partners":null,"error":"bad_request"}`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(queryUpdateJobResults)).
+		WithArgs(lockedUntil, 102, sqlmock.AnyArg(), "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.SaveJobResults(context.Background(), "abc", 100, results, lockedUntil)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestSaveJobResults_Failure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	errQuery := errors.New("some error")
+	results := []models.MatchResult{
+		{Partners: []models.Partner{}},
+		{Error: "bad_request"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryCreateJobResults)).
+		WillReturnError(errQuery)
+	mock.ExpectRollback()
+
+	err := repo.SaveJobResults(context.Background(), "abc", 0, results, time.Now())
+
+	if !errors.Is(err, errQuery) {
+		t.Errorf("error mismatch: want '%s' got '%s'", errQuery, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestFinishJob_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryFinishJob)).
+		WithArgs("", nil, models.JobDone, sqlmock.AnyArg(), "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.FinishJob(context.Background(), "abc", "")
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestFinishJob_Failed(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryFinishJob)).
+		WithArgs("some error", nil, models.JobFailed, sqlmock.AnyArg(), "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.FinishJob(context.Background(), "abc", "some error")
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestStreamJobResults_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	rsExpected := []models.MatchResult{
+		{Partners: []models.Partner{{ID: 1, Radius: 100, Rating: 4}}},
+		{Error: "bad_request"},
+	}
+
+	rows := sqlmock.NewRows([]string{"job_id", "idx", "result"})
+	rows.AddRow("abc", 0, `{"partners":[{"id":1,"radius":100,"rating":4}]}`)
+	rows.AddRow("abc", 1, `{"error":"bad_request"}`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetJobResults)).
+		WithArgs("abc", 0).
+		WillReturnRows(rows)
+
+	var rs []models.MatchResult
+	err := repo.StreamJobResults(context.Background(), "abc", func(r models.MatchResult) error {
+		rs = append(rs, r)
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if diff := cmp.Diff(rsExpected, rs); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"match/cmd/pkg/logging"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"gorm.io/gorm"
+)
+
+// ChangesChannel is the channel notified by the database on every change to a partner, see 03-notify.sql.
+const ChangesChannel = "partner_changes"
+
+const (
+	// listenerRetryDelay is the time waited before listening again after the connection is lost.
+	listenerRetryDelay = 5 * time.Second
+	// subscriptionBuffer is the number of changes buffered for each subscriber.
+	subscriptionBuffer = 100
+)
+
+// ChangeOp is the operation that changed a partner.
+type ChangeOp string
+
+const (
+	ChangeInsert   ChangeOp = "INSERT"
+	ChangeUpdate   ChangeOp = "UPDATE"
+	ChangeDelete   ChangeOp = "DELETE"
+	ChangeTruncate ChangeOp = "TRUNCATE"
+	// ChangeReset is sent when changes may have been missed, e.g. after the connection was lost, so all partners
+	// must be considered changed.
+	ChangeReset ChangeOp = "RESET"
+)
+
+// Change is a change to a partner, to its categories or to its materials.
+type Change struct {
+	// Table is the table that changed: partners, categories or materials.
+	Table string   `json:"table"`
+	Op    ChangeOp `json:"op"`
+	// PartnerID is the id of the partner that changed, or 0 when all partners may have changed.
+	PartnerID uint `json:"partner_id"`
+}
+
+// AllPartners reports whether the change may affect every partner.
+func (c Change) AllPartners() bool {
+	return c.PartnerID == 0
+}
+
+// ParseChange parses the payload of a notification of ChangesChannel.
+func ParseChange(payload string) (Change, error) {
+	var c Change
+
+	err := json.Unmarshal([]byte(payload), &c)
+	if err != nil {
+		return Change{}, fmt.Errorf("error trying to parse the partner change '%s': %w", payload, err)
+	}
+
+	switch c.Op {
+	case ChangeInsert, ChangeUpdate, ChangeDelete, ChangeTruncate:
+	default:
+		return Change{}, fmt.Errorf("error trying to parse the partner change '%s': unknown operation", payload)
+	}
+
+	return c, nil
+}
+
+// Listener listens to the partner changes notified by the database and sends them to its subscribers.
+type Listener struct {
+	handler *gorm.DB
+
+	mu          sync.Mutex
+	subscribers []chan Change
+}
+
+// NewListener creates a new Listener that listens on a connection of the given SQL database handler.
+func NewListener(handler *gorm.DB) *Listener {
+	return &Listener{handler: handler}
+}
+
+// Subscribe returns a channel that receives every change, in the order they were committed, until Run returns.
+// Subscribers must keep up with the changes, as a full channel blocks all other subscribers.
+func (l *Listener) Subscribe() <-chan Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan Change, subscriptionBuffer)
+	l.subscribers = append(l.subscribers, ch)
+	return ch
+}
+
+// Run listens to the changes until the context is done, then closes the subscribed channels.
+// When the connection is lost it listens again on a new connection, and sends a ChangeReset change since changes
+// may have been missed in between.
+func (l *Listener) Run(ctx context.Context) {
+	defer l.close()
+
+	for first := true; ; first = false {
+		err := l.listen(ctx, !first)
+		if ctx.Err() != nil {
+			return
+		}
+
+		logging.FromContext(ctx).Error("error listening to the partner changes", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenerRetryDelay):
+		}
+	}
+}
+
+// listen takes a connection out of the pool and sends the changes notified on it until an error happens.
+func (l *Listener) listen(ctx context.Context, reset bool) error {
+	sqlDB, err := l.handler.DB()
+	if err != nil {
+		return fmt.Errorf("error trying to get the database connection pool: %w", err)
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error trying to get a database connection: %w", err)
+	}
+	defer conn.Close()
+
+	var listenErr error
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			listenErr = fmt.Errorf("error trying to listen to the partner changes: unsupported connection %T", driverConn)
+		} else {
+			listenErr = l.wait(ctx, c.Conn(), reset)
+		}
+		// the connection may still be listening, so it must not go back to the pool
+		return driver.ErrBadConn
+	})
+	if listenErr != nil {
+		return listenErr
+	}
+	return err
+}
+
+// wait listens on the given connection and sends the changes notified on it until an error happens.
+func (l *Listener) wait(ctx context.Context, conn *pgx.Conn, reset bool) error {
+	_, err := conn.Exec(ctx, "LISTEN "+ChangesChannel)
+	if err != nil {
+		return fmt.Errorf("error trying to listen to the partner changes: %w", err)
+	}
+
+	if reset {
+		l.send(ctx, Change{Op: ChangeReset})
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("error trying to wait for the partner changes: %w", err)
+		}
+
+		change, err := ParseChange(n.Payload)
+		if err != nil {
+			logging.FromContext(ctx).Error("error parsing a partner change", "error", err)
+			change = Change{Op: ChangeReset}
+		}
+
+		l.send(ctx, change)
+	}
+}
+
+func (l *Listener) send(ctx context.Context, c Change) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, ch := range l.subscribers {
+		select {
+		case ch <- c:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *Listener) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, ch := range l.subscribers {
+		close(ch)
+	}
+	l.subscribers = nil
+}
@@ -0,0 +1,59 @@
+//go:build integration
+// +build integration
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/repository"
+
+	"github.com/google/go-cmp/cmp"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const dsn string = "host=localhost port=5432 user=root password=password dbname=match sslmode=disable"
+
+func TestIntegrationListener(t *testing.T) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("error opening the database: '%s'", err)
+	}
+
+	l := repository.NewListener(db)
+	changes := l.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go l.Run(ctx)
+
+	// the listener may not be listening yet, so keep changing the partner until a change arrives
+	var c repository.Change
+	for received := false; !received; {
+		err = db.Exec("UPDATE materials SET description = description WHERE id = 1 AND partner_id = 6").Error
+		if err != nil {
+			t.Fatalf("error updating the materials: '%s'", err)
+		}
+
+		select {
+		case c = <-changes:
+			received = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	expected := repository.Change{Table: "materials", Op: repository.ChangeUpdate, PartnerID: 6}
+	if diff := cmp.Diff(expected, c); diff != "" {
+		t.Errorf("change mismatch (-want +got):\n%s", diff)
+	}
+
+	cancel()
+
+	// the channel is closed once the listener stops
+	for range changes {
+	}
+}
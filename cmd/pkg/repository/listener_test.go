@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"testing"
+
+	"match/cmd/pkg/repository"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseChange_Success(t *testing.T) {
+	tests := []struct {
+		payload  string
+		expected repository.Change
+	}{
+		{`{"table": "partners", "op": "INSERT", "partner_id": 1}`, repository.Change{Table: "partners", Op: repository.ChangeInsert, PartnerID: 1}},
+		{`{"table": "materials", "op": "UPDATE", "partner_id": 2}`, repository.Change{Table: "materials", Op: repository.ChangeUpdate, PartnerID: 2}},
+		{`{"table": "categories", "op": "DELETE", "partner_id": 3}`, repository.Change{Table: "categories", Op: repository.ChangeDelete, PartnerID: 3}},
+		{`{"table": "partners", "op": "TRUNCATE", "partner_id": 0}`, repository.Change{Table: "partners", Op: repository.ChangeTruncate}},
+	}
+
+	for _, test := range tests {
+		c, err := repository.ParseChange(test.payload)
+
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+
+		if diff := cmp.Diff(test.expected, c); diff != "" {
+			t.Errorf("change mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestParseChange_Failure(t *testing.T) {
+	for _, payload := range []string{
+		``,
+		`{"table": "partners", "op": "INSERT", "partner_id": -1}`,
+		`{"table": "partners", "op": "RESET", "partner_id": 1}`,
+		`{"table": "partners", "partner_id": 1}`,
+	} {
+		_, err := repository.ParseChange(payload)
+
+		if err == nil {
+			t.Errorf("%s: error mismatch: want an error got 'nil'", payload)
+		}
+	}
+}
+
+func TestChange_AllPartners(t *testing.T) {
+	if !(repository.Change{Op: repository.ChangeTruncate}).AllPartners() {
+		t.Errorf("all partners mismatch: want true got false")
+	}
+
+	if (repository.Change{Op: repository.ChangeUpdate, PartnerID: 1}).AllPartners() {
+		t.Errorf("all partners mismatch: want false got true")
+	}
+}
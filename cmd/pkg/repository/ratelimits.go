@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// The rate limit buckets are refilled and taken from by two statements, instead of a transaction, since the refill
+// is the same whenever it runs and the take is conditional on the bucket having a token left.
+const (
+	queryRefillBucket = `INSERT INTO rate_limits AS r (key, tokens, updated_at, full_at) VALUES (?, ?, now(), now()) ` +
+		`ON CONFLICT (key) DO UPDATE SET ` +
+		`tokens = LEAST(EXCLUDED.tokens, r.tokens + EXTRACT(EPOCH FROM now() - r.updated_at)::DOUBLE PRECISION * ?), ` +
+		`updated_at = now() ` +
+		`RETURNING tokens`
+	queryTakeToken = `UPDATE rate_limits SET tokens = tokens - 1, ` +
+		`full_at = now() + make_interval(secs => (? - tokens + 1) / ?) ` +
+		`WHERE key = ? AND tokens >= 1 ` +
+		`RETURNING tokens`
+	// queryDeleteFullBuckets only deletes the buckets that have been full for a while, so a bucket isn't deleted
+	// between the refill and the take of a request, which would then find no bucket to take from and be limited.
+	queryDeleteFullBuckets = `DELETE FROM rate_limits WHERE full_at < now() - interval '1 minute'`
+)
+
+// TakeToken refills the rate limit bucket of the key, which holds up to burst tokens, with rate tokens per second
+// for the time since it was last taken from, and then takes a token from it, if it has one. It returns the tokens
+// left and whether one was taken. A new bucket is full.
+//
+// The buckets are not a write of the request, so they don't make its reads go to the primary.
+func (db *Database) TakeToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	var tokens float64
+	var taken []float64
+
+	err := db.run(ctx, true, func(ctx context.Context) error {
+		err := db.handler.
+			WithContext(ctx).
+			Raw(queryRefillBucket, key, float64(burst), rate).
+			Scan(&tokens).
+			Error
+		if err != nil || tokens < 1 {
+			return err
+		}
+
+		return db.handler.
+			WithContext(ctx).
+			Raw(queryTakeToken, float64(burst), rate, key).
+			Scan(&taken).
+			Error
+	})
+
+	if err != nil {
+		return 0, false, fmt.Errorf("error trying to take a token of the rate limit from the database: %w", err)
+	}
+
+	// another instance may have taken the last token between the refill and the take
+	if len(taken) == 0 {
+		return tokens, false, nil
+	}
+	return taken[0], true, nil
+}
+
+// DeleteFullBuckets deletes the rate limit buckets that have been full again for a minute, since they are the same
+// as no bucket, and returns how many were deleted.
+func (db *Database) DeleteFullBuckets(ctx context.Context) (int64, error) {
+	var n int64
+
+	err := db.run(ctx, true, func(ctx context.Context) error {
+		res := db.handler.
+			WithContext(ctx).
+			Exec(queryDeleteFullBuckets)
+		n = res.RowsAffected
+		return res.Error
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("error trying to delete the full rate limit buckets from the database: %w", err)
+	}
+
+	return n, nil
+}
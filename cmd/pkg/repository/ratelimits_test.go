@@ -0,0 +1,119 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	queryRefillBucket      = `INSERT INTO rate_limits AS r (key, tokens, updated_at, full_at) VALUES ($1, $2, now(), now()) ON CONFLICT (key) DO UPDATE SET tokens = LEAST(EXCLUDED.tokens, r.tokens + EXTRACT(EPOCH FROM now() - r.updated_at)::DOUBLE PRECISION * $3), updated_at = now() RETURNING tokens`
+	queryTakeToken         = `UPDATE rate_limits SET tokens = tokens - 1, full_at = now() + make_interval(secs => ($1 - tokens + 1) / $2) WHERE key = $3 AND tokens >= 1 RETURNING tokens`
+	queryDeleteFullBuckets = `DELETE FROM rate_limits WHERE full_at < now() - interval '1 minute'`
+)
+
+func TestTakeToken_Allowed(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryRefillBucket)).
+		WithArgs("match:ip:10.0.0.1", 60.0, 1.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(3.5))
+	mock.ExpectQuery(regexp.QuoteMeta(queryTakeToken)).
+		WithArgs(60.0, 1.0, "match:ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(2.5))
+
+	tokens, allowed, err := repo.TakeToken(context.Background(), "match:ip:10.0.0.1", 1, 60)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if !allowed || tokens != 2.5 {
+		t.Errorf("token mismatch: want 2.5 tokens left, allowed, got %v, %v", tokens, allowed)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestTakeToken_Empty(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	// an empty bucket is not taken from
+	mock.ExpectQuery(regexp.QuoteMeta(queryRefillBucket)).
+		WithArgs("match:ip:10.0.0.1", 60.0, 1.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(0.25))
+
+	tokens, allowed, err := repo.TakeToken(context.Background(), "match:ip:10.0.0.1", 1, 60)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if allowed || tokens != 0.25 {
+		t.Errorf("token mismatch: want 0.25 tokens left, not allowed, got %v, %v", tokens, allowed)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestTakeToken_TakenConcurrently(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryRefillBucket)).
+		WithArgs("match:ip:10.0.0.1", 60.0, 1.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(1.0))
+	mock.ExpectQuery(regexp.QuoteMeta(queryTakeToken)).
+		WithArgs(60.0, 1.0, "match:ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens"}))
+
+	_, allowed, err := repo.TakeToken(context.Background(), "match:ip:10.0.0.1", 1, 60)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if allowed {
+		t.Errorf("allowed mismatch: want false got true")
+	}
+}
+
+func TestDeleteFullBuckets_Success(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler)
+
+	mock.ExpectExec(regexp.QuoteMeta(queryDeleteFullBuckets)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.DeleteFullBuckets(context.Background())
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if n != 2 {
+		t.Errorf("deleted mismatch: want 2 got %d", n)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
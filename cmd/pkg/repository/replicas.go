@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"match/cmd/pkg/logging"
+
+	"gorm.io/gorm"
+)
+
+// replica is a read replica of the database.
+type replica struct {
+	handler *gorm.DB
+	// healthy is 1 while the replica answers, 0 otherwise.
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	var v int32
+	if healthy {
+		v = 1
+	}
+	atomic.StoreInt32(&r.healthy, v)
+}
+
+// WithReplicas sends the queries that read partners to the given read replicas, in turns, instead of the primary.
+// A replica that fails is skipped until CheckReplicas finds it healthy again, and when no replica is healthy the
+// queries go to the primary. Writes, and reads that must see them, always go to the primary.
+//
+// Replicas lag behind the primary, so the partners read from them may be slightly out of date.
+func WithReplicas(handlers ...*gorm.DB) Option {
+	return func(db *Database) {
+		for _, h := range handlers {
+			db.replicas = append(db.replicas, &replica{handler: h, healthy: 1})
+		}
+	}
+}
+
+// WithReplicaMaxLag bounds the lag of the replicas: for that long after the partners change, see PartnersChanged,
+// they are read from the primary, so the partners read right after a change, e.g. to be cached again, are not the
+// outdated ones of a lagging replica. A replica that lags more may still be read outdated once the time is up.
+func WithReplicaMaxLag(lag time.Duration) Option {
+	return func(db *Database) {
+		db.replicaMaxLag = lag
+	}
+}
+
+// PartnersChanged records that the partners changed, e.g. as notified by the database, so they are read from the
+// primary for the replicas' maximum lag. The partners updated by the Database record it themselves.
+func (db *Database) PartnersChanged() {
+	if len(db.replicas) == 0 || db.replicaMaxLag <= 0 {
+		return
+	}
+	atomic.StoreInt64(&db.primaryUntil, time.Now().Add(db.replicaMaxLag).UnixNano())
+}
+
+// CheckReplicas pings every replica and routes queries only to the ones that answer.
+func (db *Database) CheckReplicas(ctx context.Context) {
+	for i, r := range db.replicas {
+		err := pingReplica(ctx, r, db.queryTimeout)
+		if err != nil {
+			if r.isHealthy() {
+				logging.FromContext(ctx).Error("error checking a replica, routing its queries to the primary", "replica", i, "error", err)
+			}
+			r.setHealthy(false)
+			continue
+		}
+
+		if !r.isHealthy() {
+			logging.FromContext(ctx).Info("replica healthy again", "replica", i)
+		}
+		r.setHealthy(true)
+	}
+}
+
+// RunReplicaChecks checks the replicas every interval until the context is done.
+func (db *Database) RunReplicaChecks(ctx context.Context, interval time.Duration) {
+	if len(db.replicas) == 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			db.CheckReplicas(ctx)
+		}
+	}
+}
+
+func pingReplica(ctx context.Context, r *replica, timeout time.Duration) error {
+	sqlDB, err := r.handler.DB()
+	if err != nil {
+		return fmt.Errorf("error trying to get the replica connection pool: %w", err)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// readReplica runs a query that only reads partners on the next healthy replica. It runs on the primary, like any
+// other read, when there is no healthy replica, when the replica is unavailable, when the request must read its
+// own writes or when the partners just changed.
+func (db *Database) readReplica(ctx context.Context, query func(ctx context.Context, handler *gorm.DB) error) error {
+	if r, i := db.nextReplica(ctx); r != nil {
+		unavailable, err := runWithTimeout(ctx, db.queryTimeout, func(ctx context.Context) error {
+			return query(ctx, r.handler)
+		})
+		if !unavailable {
+			return err
+		}
+
+		logging.FromContext(ctx).Error("error querying a replica, routing its queries to the primary", "replica", i, "error", err)
+		r.setHealthy(false)
+	}
+
+	return db.read(ctx, func(ctx context.Context) error {
+		return query(ctx, db.handler)
+	})
+}
+
+// nextReplica returns the next healthy replica, and its index, or nil if the query must go to the primary.
+func (db *Database) nextReplica(ctx context.Context) (*replica, int) {
+	if len(db.replicas) == 0 || mustReadPrimary(ctx) {
+		return nil, 0
+	}
+	if time.Now().UnixNano() < atomic.LoadInt64(&db.primaryUntil) {
+		return nil, 0
+	}
+
+	start := int(atomic.AddUint32(&db.nextReplicaIdx, 1))
+	for i := 0; i < len(db.replicas); i++ {
+		idx := (start + i) % len(db.replicas)
+		if db.replicas[idx].isHealthy() {
+			return db.replicas[idx], idx
+		}
+	}
+
+	return nil, 0
+}
+
+type sessionKey struct{}
+
+// session tracks the writes made within a request.
+type session struct {
+	// wrote is 1 once a write was made, 0 before.
+	wrote int32
+}
+
+// WithReadYourWrites returns a context whose reads, once a write is made with it, or with a context derived from
+// it, go to the primary, so they see the write instead of a replica's possibly outdated data.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// markWrite records, if the context tracks its writes, that a write was made.
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		atomic.StoreInt32(&s.wrote, 1)
+	}
+}
+
+// mustReadPrimary reports whether a write was made with the context, if it tracks its writes.
+func mustReadPrimary(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && atomic.LoadInt32(&s.wrote) == 1
+}
@@ -0,0 +1,242 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func initReplica(t *testing.T) (sqlmock.Sqlmock, *gorm.DB) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	dialector := postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	})
+
+	handler, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: '%s'", err)
+	}
+
+	return mock, handler
+}
+
+func expectGetPartnerById(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"}).AddRow(1, 1.1, 1.2, 10, 4))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+}
+
+func TestReplicas_ReadsInTurns(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	mock1, replica1 := initReplica(t)
+	mock2, replica2 := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica1, replica2))
+
+	expectGetPartnerById(mock1)
+	expectGetPartnerById(mock2)
+
+	for i := 0; i < 2; i++ {
+		_, err := repo.GetPartnerById(context.Background(), 1)
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, mock1, mock2} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
+
+func TestReplicas_FallbackToPrimary(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	replicaMock, replica := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica))
+
+	replicaMock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnError(errSerialization)
+
+	// the failed replica is skipped from then on
+	expectGetPartnerById(mock)
+	expectGetPartnerById(mock)
+
+	for i := 0; i < 2; i++ {
+		_, err := repo.GetPartnerById(context.Background(), 1)
+		if err != nil {
+			t.Errorf("error mismatch: want 'nil' got '%s'", err)
+		}
+	}
+
+	// until it is found healthy again
+	replicaMock.ExpectPing()
+	repo.CheckReplicas(context.Background())
+
+	expectGetPartnerById(replicaMock)
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, replicaMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
+
+func TestReplicas_NotFoundDoesNotFallback(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	replicaMock, replica := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica))
+
+	replicaMock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, replicaMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
+
+func TestReplicas_CheckFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	replicaMock, replica := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica))
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	repo.CheckReplicas(context.Background())
+
+	expectGetPartnerById(mock)
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, replicaMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
+
+func TestReplicas_ReadYourWrites(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	replicaMock, replica := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica))
+
+	ctx := repository.WithReadYourWrites(context.Background())
+
+	// before writing the replica is read
+	expectGetPartnerById(replicaMock)
+
+	_, err := repo.GetPartnerById(ctx, 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryFinishJob)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.FinishJob(ctx, "4f1a", "")
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	// after writing the primary is read
+	expectGetPartnerById(mock)
+
+	_, err = repo.GetPartnerById(ctx, 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, replicaMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
+
+func TestReplicas_PartnersChanged(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	replicaMock, replica := initReplica(t)
+
+	repo := repository.NewDatabase(handler, repository.WithReplicas(replica), repository.WithReplicaMaxLag(20*time.Millisecond))
+
+	// right after a change the primary is read
+	repo.PartnersChanged()
+	expectGetPartnerById(mock)
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	// and once the replica caught up, the replica
+	time.Sleep(30 * time.Millisecond)
+	expectGetPartnerById(replicaMock)
+
+	_, err = repo.GetPartnerById(context.Background(), 1)
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	for _, m := range []sqlmock.Sqlmock{mock, replicaMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("expectations were not met: '%s'", err)
+		}
+	}
+}
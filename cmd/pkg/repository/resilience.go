@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"match/cmd/pkg/breaker"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	// ErrUnavailable is returned when the database can't be reached, either because it is down, because it is
+	// too slow to answer or because the circuit breaker is open.
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+const (
+	defaultOpenMinBackoff = 500 * time.Millisecond
+	defaultOpenMaxBackoff = 30 * time.Second
+)
+
+// OpenOptions configures the connection to the database. Zero values keep the defaults.
+type OpenOptions struct {
+	// MaxOpenConns is the maximum number of open connections. Defaults to unlimited.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections. Defaults to 2.
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum time a connection is reused. Defaults to forever.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is the maximum time a connection stays idle. Defaults to forever.
+	ConnMaxIdleTime time.Duration
+
+	// MinBackoff is the time waited after the first failed attempt to connect. Defaults to 500 milliseconds.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum time waited between attempts to connect. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+
+	// Logger logs the queries. Defaults to gorm's logger.
+	Logger logger.Interface
+}
+
+// Open opens the database and configures its connection pool. Until the database is reachable it tries again,
+// waiting twice as long after each failed attempt, or until the context is done.
+func Open(ctx context.Context, dialector gorm.Dialector, opts OpenOptions) (*gorm.DB, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultOpenMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultOpenMaxBackoff
+	}
+
+	db, sqlDB, err := openPool(dialector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := opts.MinBackoff
+	for {
+		err = sqlDB.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+
+		logging.FromContext(ctx).Warn("error connecting to the database, trying again", "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			_ = sqlDB.Close()
+			return nil, fmt.Errorf("error trying to connect to the database: %w", err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// OpenReplica opens a read replica of the database and configures its connection pool, without waiting for it to
+// be reachable, since the Database checks the health of its replicas.
+func OpenReplica(dialector gorm.Dialector, opts OpenOptions) (*gorm.DB, error) {
+	db, _, err := openPool(dialector, opts)
+	return db, err
+}
+
+// openPool opens the database, without connecting to it, and configures its connection pool.
+func openPool(dialector gorm.Dialector, opts OpenOptions) (*gorm.DB, *sql.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true, Logger: opts.Logger})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error trying to open the database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error trying to get the database connection pool: %w", err)
+	}
+
+	configurePool(sqlDB, opts)
+
+	return db, sqlDB, nil
+}
+
+func configurePool(sqlDB *sql.DB, opts OpenOptions) {
+	if opts.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+}
+
+// Option configures a Database.
+type Option func(db *Database)
+
+// WithQueryTimeout limits each query to the given time, or less if the request's context ends sooner.
+// Streaming queries are not limited, since their time depends on the caller.
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(db *Database) {
+		db.queryTimeout = timeout
+	}
+}
+
+// WithReadRetries retries the queries that only read from the database up to the given number of times when they
+// fail with a transient error, e.g. a lost connection, waiting the given backoff, doubled after each attempt.
+func WithReadRetries(retries int, backoff time.Duration) Option {
+	return func(db *Database) {
+		db.readRetries = retries
+		db.retryBackoff = backoff
+	}
+}
+
+// WithBreaker makes the queries fail fast with ErrUnavailable while the given circuit breaker is open.
+// The breaker opens after consecutive queries fail because the database is unavailable.
+func WithBreaker(b *breaker.Breaker) Option {
+	return func(db *Database) {
+		db.breaker = b
+	}
+}
+
+// WithMatchRanking ranks and limits the matches returned by GetMatches as given.
+func WithMatchRanking(ranking models.MatchRanking) Option {
+	return func(db *Database) {
+		db.ranking = ranking
+	}
+}
+
+// unavailableError is an error caused by the database being unavailable.
+type unavailableError struct {
+	err error
+}
+
+func (e unavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnavailable, e.err)
+}
+
+func (e unavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e unavailableError) Unwrap() error {
+	return e.err
+}
+
+// read runs a query that only reads from the database, retrying it on transient errors.
+func (db *Database) read(ctx context.Context, query func(ctx context.Context) error) error {
+	backoff := db.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := db.run(ctx, true, query)
+		if err == nil || attempt >= db.readRetries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// write runs a query that changes the database. It is never retried, since it may have been applied.
+func (db *Database) write(ctx context.Context, query func(ctx context.Context) error) error {
+	markWrite(ctx)
+	return db.run(ctx, true, query)
+}
+
+// stream runs a query whose time depends on the caller, so it has no timeout and is never retried.
+func (db *Database) stream(ctx context.Context, query func(ctx context.Context) error) error {
+	return db.run(ctx, false, query)
+}
+
+// run runs the query through the circuit breaker, if any, and with the query timeout, if any and if asked to.
+// Errors caused by the database being unavailable are returned as ErrUnavailable.
+func (db *Database) run(ctx context.Context, timeout bool, query func(ctx context.Context) error) error {
+	if db.breaker != nil && !db.breaker.Allow() {
+		return unavailableError{err: breaker.ErrOpen}
+	}
+
+	var queryTimeout time.Duration
+	if timeout {
+		queryTimeout = db.queryTimeout
+	}
+
+	unavailable, err := runWithTimeout(ctx, queryTimeout, query)
+
+	if db.breaker != nil {
+		db.breaker.Done(unavailable)
+	}
+
+	if unavailable {
+		return unavailableError{err: err}
+	}
+	return err
+}
+
+// runWithTimeout runs the query limited to the given timeout, if any, and reports whether it failed because the
+// database is unavailable.
+func runWithTimeout(ctx context.Context, timeout time.Duration, query func(ctx context.Context) error) (bool, error) {
+	queryCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := query(queryCtx)
+
+	// a query that timed out, unlike one whose request was canceled, means the database is struggling
+	unavailable := err != nil && ctx.Err() == nil && (isTransient(err) || queryCtx.Err() != nil)
+
+	return unavailable, err
+}
+
+// isTransient reports whether the error is caused by the database being temporarily unavailable, so the query may
+// succeed if tried again.
+func isTransient(err error) bool {
+	// the context errors are net.Errors too, but only mean the caller stopped waiting
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"), // connection exception
+			pgErr.Code == "40001", // serialization failure
+			pgErr.Code == "40P01", // deadlock detected
+			pgErr.Code == "53300", // too many connections
+			pgErr.Code == "57P01", // admin shutdown
+			pgErr.Code == "57P02", // crash shutdown
+			pgErr.Code == "57P03": // cannot connect now
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
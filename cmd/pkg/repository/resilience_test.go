@@ -0,0 +1,273 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/breaker"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"gorm.io/driver/postgres"
+)
+
+// errSerialization is a transient error.
+var errSerialization = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+func TestOpen_RetriesUntilReachable(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	dialector := postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	})
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	_, err = repository.Open(context.Background(), dialector, repository.OpenOptions{MinBackoff: time.Millisecond})
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestOpen_ContextDone(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	dialector := postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	})
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = repository.Open(ctx, dialector, repository.OpenOptions{MinBackoff: time.Hour})
+
+	if err == nil {
+		t.Errorf("error mismatch: want an error got 'nil'")
+	}
+}
+
+func TestReadRetries_TransientError(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithReadRetries(2, time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnError(errSerialization)
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"}).AddRow(1, 1.1, 1.2, 10, 4))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetCategoriesByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetMaterialsByPartnerId)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+
+	p, err := repo.GetPartnerById(context.Background(), 1)
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if p.ID != 1 {
+		t.Errorf("partner id mismatch: want 1 got %v", p.ID)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestReadRetries_GiveUp(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithReadRetries(1, time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnError(errSerialization)
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnError(errSerialization)
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+
+	if !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrUnavailable, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestReadRetries_NotTransientError(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithReadRetries(2, time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnError(errors.New("some error"))
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+
+	if err == nil || errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want 'some error' got '%v'", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestReadRetries_WritesAreNotRetried(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithReadRetries(2, time.Millisecond))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(queryFinishJob)).
+		WillReturnError(errSerialization)
+	mock.ExpectRollback()
+
+	err := repo.FinishJob(context.Background(), "4f1a", "")
+
+	if !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrUnavailable, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithQueryTimeout(10*time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+
+	if !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrUnavailable, err)
+	}
+}
+
+func TestQueryTimeout_RequestCanceled(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	repo := repository.NewDatabase(handler, repository.WithQueryTimeout(time.Second))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := repo.GetPartnerById(ctx, 1)
+
+	if err == nil || errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want a canceled query got '%v'", err)
+	}
+}
+
+func TestBreaker_FailsFast(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	b := breaker.New(breaker.Options{Threshold: 2, Cooldown: time.Hour})
+	repo := repository.NewDatabase(handler, repository.WithBreaker(b))
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+			WithArgs(1).
+			WillReturnError(errSerialization)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := repo.GetPartnerById(context.Background(), 1)
+		if !errors.Is(err, repository.ErrUnavailable) {
+			t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrUnavailable, err)
+		}
+	}
+
+	// the breaker is open, so neither reads nor writes reach the database
+	_, err := repo.GetPartnerById(context.Background(), 1)
+	if !errors.Is(err, breaker.ErrOpen) || !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want '%s' got '%s'", breaker.ErrOpen, err)
+	}
+
+	_, err = repo.CreateJob(context.Background(), "key:1", []models.MatchRequest{{Materials: []uint{1}}})
+	if !errors.Is(err, repository.ErrUnavailable) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrUnavailable, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectations were not met: '%s'", err)
+	}
+}
+
+func TestBreaker_NotFoundIsNotAFailure(t *testing.T) {
+	db, mock, handler := initDB(t)
+	defer db.Close()
+
+	b := breaker.New(breaker.Options{Threshold: 1, Cooldown: time.Hour})
+	repo := repository.NewDatabase(handler, repository.WithBreaker(b))
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryGetPartnerById)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repo.GetPartnerById(context.Background(), 1)
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("error mismatch: want '%s' got '%s'", repository.ErrNotFound, err)
+	}
+
+	if b.State() != breaker.Closed {
+		t.Errorf("state mismatch: want %v got %v", breaker.Closed, b.State())
+	}
+}
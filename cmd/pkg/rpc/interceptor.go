@@ -0,0 +1,180 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// methodScopes are the scopes required by the methods of the Partners service, by their full name. The other
+// methods, e.g. the health checks, are not authenticated.
+var methodScopes = map[string]auth.Scope{
+	"/match.v1.Partners/Match":      auth.ScopeMatch,
+	"/match.v1.Partners/GetPartner": auth.ScopePartnersRead,
+}
+
+// methodRoutes are the routes of the HTTP API whose limits apply to the methods of the Partners service, by their
+// full name, so a client has the same limits over both APIs.
+var methodRoutes = map[string]string{
+	"/match.v1.Partners/Match":      "match",
+	"/match.v1.Partners/GetPartner": "get_partner",
+}
+
+// logCalls logs every call with its method, code and duration, like the HTTP requests, and puts the logger in the
+// call's context.
+func logCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+
+	logger := slog.Default().With("grpc_method", info.FullMethod)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+
+	res, err := handler(logging.WithLogger(ctx, logger), req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+		level = slog.LevelError
+	}
+
+	logger.LogAttrs(ctx, level, "call",
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	)
+	return res, err
+}
+
+// authenticate returns an interceptor that serves the calls authenticated by their API key or JWT, given by the
+// 'authorization: Bearer <key>' or the 'x-api-key' metadata, whose principal is allowed to use the scope of their
+// method, with their principal in their context. The others fail with Unauthenticated or PermissionDenied. The calls
+// whose principal was identified by limit are not authenticated again.
+func authenticate(a *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		scope, ok := methodScopes[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		p, ok := auth.FromContext(ctx)
+		var err error
+		if !ok {
+			p, err = a.Authenticate(ctx, credentials(ctx))
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrMissingCredentials), errors.Is(err, auth.ErrInvalidCredentials):
+				logging.FromContext(ctx).Warn("unauthenticated call", "error", err)
+				return nil, status.Error(codes.Unauthenticated, "missing or invalid credentials")
+			case errors.Is(err, repository.ErrUnavailable):
+				logging.FromContext(ctx).Error("error authenticating the call", "error", err)
+				return nil, status.Error(codes.Unavailable, "the database is unavailable")
+			default:
+				logging.FromContext(ctx).Error("error authenticating the call", "error", err)
+				return nil, status.Error(codes.Internal, "internal error")
+			}
+		}
+
+		logger := logging.FromContext(ctx).With("client", p.Name)
+		if !p.Can(scope) {
+			logger.Warn("forbidden call", "role", string(p.Role), "scope", string(scope))
+			return nil, status.Errorf(codes.PermissionDenied, "the credentials are not allowed to use the '%s' scope", scope)
+		}
+
+		return handler(auth.WithPrincipal(logging.WithLogger(ctx, logger), p), req)
+	}
+}
+
+// limit returns an interceptor that serves the calls whose client is within the limit of their method's route, like
+// ratelimit.Limiter.Limit. The others fail with ResourceExhausted and the delay after which to retry them. It limits
+// the calls before they are authenticated, so the calls with invalid credentials are limited too.
+//
+// If the limits can't be checked, e.g. because the shared store is unavailable, the calls are let through.
+func limit(l *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	routes := make(map[string]ratelimit.Route, len(methodRoutes))
+	for method, name := range methodRoutes {
+		routes[method] = l.Route(name)
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		route, ok := routes[info.FullMethod]
+		if !ok || route.Limit().Unlimited() {
+			return handler(ctx, req)
+		}
+
+		// the identified principal is put in the call's context, so authenticate doesn't authenticate it again
+		client := ratelimit.IPClient(peerIP(ctx))
+		if p, ok := l.Identify(ctx, credentials(ctx)); ok {
+			client = ratelimit.PrincipalClient(p)
+			ctx = auth.WithPrincipal(ctx, p)
+		}
+
+		res, err := route.Take(ctx, client)
+		if err != nil {
+			logging.FromContext(ctx).Error("error checking the rate limit", "route", methodRoutes[info.FullMethod], "error", err)
+			return handler(ctx, req)
+		}
+
+		if !res.Allowed {
+			logging.FromContext(ctx).Warn("rate limited call", "route", methodRoutes[info.FullMethod], "limit", route.Limit().String())
+			st, err := status.New(codes.ResourceExhausted, "too many requests").
+				WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+			if err != nil {
+				return nil, status.Error(codes.ResourceExhausted, "too many requests")
+			}
+			return nil, st.Err()
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// peerIP returns the IP of the call's client, or an empty string if it is unknown.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// credentials returns the API key or the JWT of the call, or an empty string if it has none.
+func credentials(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if vs := md.Get("authorization"); len(vs) > 0 {
+		prefix := "bearer "
+		if len(vs[0]) > len(prefix) && strings.EqualFold(vs[0][:len(prefix)], prefix) {
+			return strings.TrimSpace(vs[0][len(prefix):])
+		}
+		return ""
+	}
+
+	if vs := md.Get(strings.ToLower(auth.HeaderAPIKey)); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
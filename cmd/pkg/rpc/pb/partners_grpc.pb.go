@@ -0,0 +1,147 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: partners.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PartnersClient is the client API for Partners service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PartnersClient interface {
+	// Match returns the partners that are experienced with all the materials of the request and whose radius covers
+	// its address, the best match first.
+	Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+	// GetPartner returns a partner by its id. A partner can only get itself.
+	GetPartner(ctx context.Context, in *GetPartnerRequest, opts ...grpc.CallOption) (*Partner, error)
+}
+
+type partnersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPartnersClient(cc grpc.ClientConnInterface) PartnersClient {
+	return &partnersClient{cc}
+}
+
+func (c *partnersClient) Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, "/match.v1.Partners/Match", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *partnersClient) GetPartner(ctx context.Context, in *GetPartnerRequest, opts ...grpc.CallOption) (*Partner, error) {
+	out := new(Partner)
+	err := c.cc.Invoke(ctx, "/match.v1.Partners/GetPartner", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PartnersServer is the server API for Partners service.
+// All implementations must embed UnimplementedPartnersServer
+// for forward compatibility
+type PartnersServer interface {
+	// Match returns the partners that are experienced with all the materials of the request and whose radius covers
+	// its address, the best match first.
+	Match(context.Context, *MatchRequest) (*MatchResponse, error)
+	// GetPartner returns a partner by its id. A partner can only get itself.
+	GetPartner(context.Context, *GetPartnerRequest) (*Partner, error)
+	mustEmbedUnimplementedPartnersServer()
+}
+
+// UnimplementedPartnersServer must be embedded to have forward compatible implementations.
+type UnimplementedPartnersServer struct {
+}
+
+func (UnimplementedPartnersServer) Match(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedPartnersServer) GetPartner(context.Context, *GetPartnerRequest) (*Partner, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPartner not implemented")
+}
+func (UnimplementedPartnersServer) mustEmbedUnimplementedPartnersServer() {}
+
+// UnsafePartnersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PartnersServer will
+// result in compilation errors.
+type UnsafePartnersServer interface {
+	mustEmbedUnimplementedPartnersServer()
+}
+
+func RegisterPartnersServer(s grpc.ServiceRegistrar, srv PartnersServer) {
+	s.RegisterService(&Partners_ServiceDesc, srv)
+}
+
+func _Partners_Match_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PartnersServer).Match(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/match.v1.Partners/Match",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PartnersServer).Match(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Partners_GetPartner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPartnerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PartnersServer).GetPartner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/match.v1.Partners/GetPartner",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PartnersServer).GetPartner(ctx, req.(*GetPartnerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Partners_ServiceDesc is the grpc.ServiceDesc for Partners service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Partners_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "match.v1.Partners",
+	HandlerType: (*PartnersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Match",
+			Handler:    _Partners_Match_Handler,
+		},
+		{
+			MethodName: "GetPartner",
+			Handler:    _Partners_GetPartner_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "partners.proto",
+}
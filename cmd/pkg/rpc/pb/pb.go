@@ -0,0 +1,2 @@
+// Package pb is the protobuf and gRPC code generated from partners.proto by 'make proto'.
+package pb
@@ -0,0 +1,131 @@
+// Package rpc serves the matches and the partners over gRPC, alongside the HTTP API, from the same database and with
+// the same credentials.
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"match/cmd/pkg/auth"
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+	"match/cmd/pkg/rpc/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the Partners service of partners.proto.
+type Server struct {
+	pb.UnimplementedPartnersServer
+	db partners.Database
+}
+
+// NewServer creates a new Server.
+func NewServer(db partners.Database) *Server {
+	return &Server{db: db}
+}
+
+// New returns a gRPC server with the Partners service, whose calls are limited by l with the limits of the routes of
+// the same operations and authenticated by a, the health service and the server reflection, e.g. for grpcurl. The
+// returned health server reports the server as serving until its Shutdown is called.
+func New(db partners.Database, a *auth.Authenticator, l *ratelimit.Limiter) (*grpc.Server, *health.Server) {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(logCalls, limit(l), authenticate(a)))
+	pb.RegisterPartnersServer(s, NewServer(db))
+
+	hs := health.NewServer()
+	hs.SetServingStatus(pb.Partners_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+
+	reflection.Register(s)
+
+	return s, hs
+}
+
+// Match returns the partners that best match the customer's request.
+func (s *Server) Match(ctx context.Context, req *pb.MatchRequest) (*pb.MatchResponse, error) {
+	r := models.MatchRequest{
+		Materials:    make([]uint, len(req.GetMaterials())),
+		Address:      models.Address{Lat: req.GetAddress().GetLat(), Long: req.GetAddress().GetLong()},
+		SquareMeters: uint(req.GetSquareMeters()),
+		PhoneNumber:  req.GetPhoneNumber(),
+	}
+	for i, id := range req.GetMaterials() {
+		r.Materials[i] = uint(id)
+	}
+
+	if !partners.IsValidMatchRequest(r) {
+		return nil, status.Error(codes.InvalidArgument, "the request must have an address and at least one material")
+	}
+
+	ps, err := s.db.GetMatches(ctx, r.Materials, r.Address.Lat, r.Address.Long)
+	if err != nil {
+		logging.FromContext(ctx).Error("error retrieving matches from the database", "error", err)
+		return nil, databaseError(ctx, err)
+	}
+
+	res := &pb.MatchResponse{Partners: make([]*pb.Partner, len(ps))}
+	for i, p := range ps {
+		res.Partners[i] = partner(p)
+	}
+	return res, nil
+}
+
+// GetPartner returns a partner by id. A partner can only get itself.
+func (s *Server) GetPartner(ctx context.Context, req *pb.GetPartnerRequest) (*pb.Partner, error) {
+	id := uint(req.GetId())
+
+	if p, ok := auth.FromContext(ctx); ok && !p.CanAccessPartner(id) {
+		logging.FromContext(ctx).Warn("forbidden call", "role", string(p.Role), "partner_id", p.PartnerID)
+		return nil, status.Error(codes.PermissionDenied, "a partner can only get itself")
+	}
+
+	p, err := s.db.GetPartnerById(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "partner %d not found", id)
+		}
+		logging.FromContext(ctx).Error("error retrieving the partner from the database", "error", err)
+		return nil, databaseError(ctx, err)
+	}
+
+	return partner(p), nil
+}
+
+// databaseError returns the status of a database error: Unavailable while the database is, the context's status if
+// the call was canceled or timed out, and Internal otherwise.
+func databaseError(ctx context.Context, err error) error {
+	switch {
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, repository.ErrUnavailable):
+		return status.Error(codes.Unavailable, "the database is unavailable")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func partner(p models.Partner) *pb.Partner {
+	res := &pb.Partner{
+		Id:         uint32(p.ID),
+		Categories: make([]*pb.Category, len(p.Categories)),
+		Materials:  make([]*pb.Material, len(p.Materials)),
+		Address:    &pb.Address{Lat: p.Address.Lat, Long: p.Address.Long},
+		Radius:     int32(p.Radius),
+		Rating:     int32(p.Rating),
+	}
+	for i, c := range p.Categories {
+		res.Categories[i] = &pb.Category{Id: uint32(c.ID), Description: c.Description}
+	}
+	for i, m := range p.Materials {
+		res.Materials[i] = &pb.Material{Id: uint32(m.ID), Description: m.Description}
+	}
+	return res
+}
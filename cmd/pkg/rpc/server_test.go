@@ -0,0 +1,301 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"match/cmd/pkg/auth"
+	authmock "match/cmd/pkg/auth/mock"
+	"match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/ratelimit"
+	"match/cmd/pkg/repository"
+	"match/cmd/pkg/rpc"
+	"match/cmd/pkg/rpc/pb"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const (
+	adminKey   = "mk_admin"
+	partnerKey = "mk_partner"
+)
+
+// dial serves the gRPC server of the database, without rate limits, on an in-process listener and returns a
+// connection to it. The admin key is the bootstrap key, and the partner key is the key of partner 1.
+func dial(t *testing.T, db *mock.MockDatabase) *grpc.ClientConn {
+	return dialLimited(t, db, ratelimit.Options{})
+}
+
+// dialLimited is dial with the rate limits of the options, counted in memory.
+func dialLimited(t *testing.T, db *mock.MockDatabase, opts ratelimit.Options) *grpc.ClientConn {
+	t.Helper()
+
+	store := authmock.NewMockKeyStore(gomock.NewController(t))
+	partnerID := uint(1)
+	store.EXPECT().
+		GetAPIKeyByHash(gomock.Any(), auth.HashKey(partnerKey)).
+		Return(models.APIKey{Name: "acme", Role: "partner", PartnerID: &partnerID}, nil).
+		AnyTimes()
+	store.EXPECT().
+		GetAPIKeyByHash(gomock.Any(), gomock.Any()).
+		Return(models.APIKey{}, repository.ErrNotFound).
+		AnyTimes()
+
+	a := auth.NewAuthenticator(store, auth.Options{BootstrapKey: adminKey})
+	opts.Authenticator = a
+	s, _ := rpc.New(db, a, ratelimit.New(ratelimit.NewMemoryStore(), opts))
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+// withKey returns a context whose calls are authenticated by the key.
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestMatch(t *testing.T) {
+	db := mock.NewMockDatabase(gomock.NewController(t))
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1, 2}, float32(52.5), float32(13.4)).
+		Return([]models.Partner{{
+			ID:         3,
+			Categories: []models.Category{{ID: 1, Description: "flooring"}},
+			Materials:  []models.Material{{ID: 1, Description: "wood"}, {ID: 2, Description: "tiles"}},
+			Address:    models.Address{Lat: 52.51, Long: 13.41},
+			Radius:     10,
+			Rating:     5,
+		}}, nil)
+
+	c := pb.NewPartnersClient(dial(t, db))
+
+	res, err := c.Match(withKey(adminKey), &pb.MatchRequest{
+		Materials:    []uint32{1, 2},
+		Address:      &pb.Address{Lat: 52.5, Long: 13.4},
+		SquareMeters: 20,
+	})
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := &pb.MatchResponse{Partners: []*pb.Partner{{
+		Id:         3,
+		Categories: []*pb.Category{{Id: 1, Description: "flooring"}},
+		Materials:  []*pb.Material{{Id: 1, Description: "wood"}, {Id: 2, Description: "tiles"}},
+		Address:    &pb.Address{Lat: 52.51, Long: 13.41},
+		Radius:     10,
+		Rating:     5,
+	}}}
+	if diff := cmp.Diff(expected, res, protocmp.Transform()); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMatch_Errors(t *testing.T) {
+	db := mock.NewMockDatabase(gomock.NewController(t))
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(52.5), float32(13.4)).
+		Return(nil, repository.ErrUnavailable)
+
+	c := pb.NewPartnersClient(dial(t, db))
+	valid := &pb.MatchRequest{Materials: []uint32{3}, Address: &pb.Address{Lat: 52.5, Long: 13.4}}
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		req  *pb.MatchRequest
+		code codes.Code
+	}{
+		{"no credentials", context.Background(), valid, codes.Unauthenticated},
+		{"invalid key", withKey("mk_unknown"), valid, codes.Unauthenticated},
+		{"api key header", metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "mk_unknown"), valid, codes.Unauthenticated},
+		// the partners can't match
+		{"forbidden scope", withKey(partnerKey), valid, codes.PermissionDenied},
+		{"no materials", withKey(adminKey), &pb.MatchRequest{Address: &pb.Address{Lat: 52.5, Long: 13.4}}, codes.InvalidArgument},
+		{"no address", withKey(adminKey), &pb.MatchRequest{Materials: []uint32{3}}, codes.InvalidArgument},
+		{"database unavailable", withKey(adminKey), valid, codes.Unavailable},
+	} {
+		_, err := c.Match(tc.ctx, tc.req)
+
+		if code := status.Code(err); code != tc.code {
+			t.Errorf("%s code mismatch: want %v got %v", tc.name, tc.code, code)
+		}
+	}
+}
+
+func TestGetPartner(t *testing.T) {
+	db := mock.NewMockDatabase(gomock.NewController(t))
+
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(models.Partner{ID: 1, Address: models.Address{Lat: 52.5, Long: 13.4}, Radius: 10, Rating: 4}, nil)
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(2)).
+		Return(models.Partner{}, repository.ErrNotFound)
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(3)).
+		Return(models.Partner{}, errors.New("connection reset"))
+
+	c := pb.NewPartnersClient(dial(t, db))
+
+	res, err := c.GetPartner(withKey(partnerKey), &pb.GetPartnerRequest{Id: 1})
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	expected := &pb.Partner{
+		Id:         1,
+		Categories: []*pb.Category{},
+		Materials:  []*pb.Material{},
+		Address:    &pb.Address{Lat: 52.5, Long: 13.4},
+		Radius:     10,
+		Rating:     4,
+	}
+	if diff := cmp.Diff(expected, res, protocmp.Transform()); diff != "" {
+		t.Errorf("partner mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		id   uint32
+		code codes.Code
+	}{
+		// a partner can only get itself
+		{"other partner", partnerKey, 2, codes.PermissionDenied},
+		{"not found", adminKey, 2, codes.NotFound},
+		{"database error", adminKey, 3, codes.Internal},
+	} {
+		_, err := c.GetPartner(withKey(tc.key), &pb.GetPartnerRequest{Id: tc.id})
+
+		if code := status.Code(err); code != tc.code {
+			t.Errorf("%s code mismatch: want %v got %v", tc.name, tc.code, code)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	db := mock.NewMockDatabase(gomock.NewController(t))
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{3}, float32(52.5), float32(13.4)).
+		Return([]models.Partner{}, nil)
+
+	c := pb.NewPartnersClient(dialLimited(t, db, ratelimit.Options{
+		Routes: map[string]ratelimit.Limit{"match": {Requests: 1, Period: time.Minute}},
+	}))
+	req := &pb.MatchRequest{Materials: []uint32{3}, Address: &pb.Address{Lat: 52.5, Long: 13.4}}
+
+	if _, err := c.Match(withKey(adminKey), req); err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	_, err := c.Match(withKey(adminKey), req)
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("code mismatch: want %v got %v", codes.ResourceExhausted, st.Code())
+	}
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("retry info mismatch: want a positive delay got %v", retry)
+	}
+
+	// the limits are per client, so the partner is not limited but forbidden, and the other methods have their own
+	_, err = c.Match(withKey(partnerKey), req)
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("code mismatch: want %v got %v", codes.PermissionDenied, code)
+	}
+	db.EXPECT().
+		GetPartnerById(gomock.Any(), uint(1)).
+		Return(models.Partner{ID: 1}, nil)
+	if _, err := c.GetPartner(withKey(adminKey), &pb.GetPartnerRequest{Id: 1}); err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := dial(t, mock.NewMockDatabase(gomock.NewController(t)))
+	ctx := context.Background()
+
+	// the health checks are not authenticated
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "match.v1.Partners"})
+
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status mismatch: want SERVING got %v", res.GetStatus())
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	info, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	var services []string
+	for _, s := range info.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	sort.Strings(services)
+
+	expected := []string{"grpc.health.v1.Health", "grpc.reflection.v1alpha.ServerReflection", "match.v1.Partners"}
+	if diff := cmp.Diff(expected, services); diff != "" {
+		t.Errorf("services mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"errors"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey is the key of the span of a statement in the gorm instance.
+const spanKey = "tracing:span"
+
+// literal matches a string literal of a SQL statement, which may hold personal data.
+var literal = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// GormPlugin traces every statement run by gorm, with a span that has the statement, sanitized, as its
+// 'db.statement' attribute. The values of the statements' parameters are never recorded.
+type GormPlugin struct {
+	// replica is whether the statements run on a read replica.
+	replica bool
+}
+
+// NewGormPlugin creates a new GormPlugin, which is added to a gorm.DB with its Use method. The replica argument
+// tells the statements run on a read replica from the ones run on the primary.
+func NewGormPlugin(replica bool) GormPlugin {
+	return GormPlugin{replica: replica}
+}
+
+// Name returns the name of the plugin.
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize registers the callbacks that start and end the spans of the statements.
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", p.start("INSERT")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", end),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", p.start("SELECT")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", end),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", p.start("UPDATE")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", end),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.start("DELETE")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", end),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", p.start("SELECT")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", end),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.start("EXEC")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", end),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start returns the callback that starts the span of a statement of the given operation, e.g. 'SELECT'.
+func (p GormPlugin) start(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+
+		ctx, span := Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationKey.String(operation),
+				attribute.Bool("db.replica", p.replica),
+			),
+		)
+		if db.Statement.Table != "" {
+			span.SetAttributes(semconv.DBSQLTableKey.String(db.Statement.Table))
+		}
+
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// end ends the span of a statement, with the statement once it was built.
+func end(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBStatementKey.String(Sanitize(db.Statement.SQL.String())),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
+
+// Sanitize removes the string literals of a SQL statement, replacing them with '?', since they may hold personal
+// data. The values of the parameters, e.g. '$1', are not part of the statement.
+func Sanitize(sql string) string {
+	return literal.ReplaceAllString(sql, "?")
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"match/cmd/pkg/controller/response"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serverName is the name of the HTTP server in the spans.
+const serverName = "match"
+
+// Middleware traces the requests, with a span named after the method and the template of their route, e.g.
+// 'GET /partners/{id:[0-9]+}', that continues the trace of the caller given by the W3C 'traceparent' header.
+// It must be used by the router, with its Use method, so the route is known.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		ctx, span := Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, route, r)...),
+		)
+		defer span.End()
+
+		sw := response.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(sw.Code())...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(sw.Code(), trace.SpanKindServer))
+	})
+}
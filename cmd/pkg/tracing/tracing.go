@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer of the app's spans.
+const instrumentationName = "match"
+
+// The exporters of the spans.
+const (
+	// ExporterNone does not trace, so it costs nothing.
+	ExporterNone = "none"
+	// ExporterStdout writes the spans to the standard output, e.g. to debug.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends the spans to an OpenTelemetry collector, with the OTLP protocol over gRPC.
+	ExporterOTLP = "otlp"
+)
+
+// Options configures the tracing.
+type Options struct {
+	// ServiceName is the name of the app in the traces.
+	ServiceName string
+	// Exporter is the exporter of the spans: ExporterNone, ExporterStdout or ExporterOTLP.
+	Exporter string
+	// Endpoint is the 'host:port' of the OpenTelemetry collector, for ExporterOTLP.
+	Endpoint string
+	// Insecure sends the spans to the collector without TLS, e.g. to a collector running alongside the app.
+	Insecure bool
+	// SampleRatio is the ratio, between 0 and 1, of the traces started by the app that are sampled. The traces
+	// started by the callers are sampled as they were.
+	SampleRatio float64
+}
+
+// Setup sets the global tracer provider, which exports the spans as configured, and the propagation of the trace
+// context with the W3C 'traceparent' and 'tracestate' headers. It returns the function that flushes the spans and
+// stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		grpcOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, grpcOpts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter '%s'", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating the tracing exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the app's spans, from the global tracer provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span, which must be ended, as a child of the span of the context, if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, opts...)
+}
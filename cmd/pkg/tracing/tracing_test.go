@@ -0,0 +1,172 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"match/cmd/pkg/repository"
+	"match/cmd/pkg/tracing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// initTracing makes the global tracer provider record the ended spans.
+func initTracing(t *testing.T) *tracetest.SpanRecorder {
+	_, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+	if err != nil {
+		t.Fatalf("error setting up the tracing: '%s'", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func attributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	res := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		res[kv.Key] = kv.Value
+	}
+	return res
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := initTracing(t)
+
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/partners/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/partners/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("number of spans mismatch: want 1 got %d", len(spans))
+	}
+
+	s := spans[0]
+	if s.Name() != "GET /partners/{id:[0-9]+}" {
+		t.Errorf("span name mismatch: want 'GET /partners/{id:[0-9]+}' got '%s'", s.Name())
+	}
+
+	// the span continues the caller's trace
+	if s.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id mismatch: want '4bf92f3577b34da6a3ce929d0e0e4736' got '%s'", s.SpanContext().TraceID())
+	}
+	if s.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("parent span id mismatch: want '00f067aa0ba902b7' got '%s'", s.Parent().SpanID())
+	}
+
+	if code := attributes(s)["http.status_code"].AsInt64(); code != http.StatusNotFound {
+		t.Errorf("status code mismatch: want %v got %v", http.StatusNotFound, code)
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := initTracing(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating a stub for database connection: '%s'", err)
+	}
+	defer db.Close()
+
+	handler, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  "sqlmock_db_0",
+		DriverName:           "postgres",
+		Conn:                 db,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: '%s'", err)
+	}
+
+	err = handler.Use(tracing.NewGormPlugin(false))
+	if err != nil {
+		t.Fatalf("error adding the plugin: '%s'", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "partners" WHERE id = $1 ORDER BY "partners"."id" LIMIT 1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "lat", "long", "radius", "rating"}).AddRow(1, 1.1, 1.2, 10, 4))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE "categories"."partner_id" = $1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "materials" WHERE "materials"."partner_id" = $1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "partner_id", "description"}))
+
+	ctx, parent := tracing.Start(context.Background(), "GET /partners/{id:[0-9]+}")
+	_, err = repository.NewDatabase(handler).GetPartnerById(ctx, 1)
+	parent.End()
+
+	if err != nil {
+		t.Errorf("error mismatch: want 'nil' got '%s'", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	var names, statements []string
+	for _, s := range recorder.Ended() {
+		if s.Name() == "GET /partners/{id:[0-9]+}" {
+			continue
+		}
+		spans[s.Name()] = s
+		names = append(names, s.Name())
+		statements = append(statements, attributes(s)["db.statement"].AsString())
+	}
+
+	// the preloads run, and end, within the partner's query
+	expectedNames := []string{"SELECT categories", "SELECT materials", "SELECT partners"}
+	if diff := cmp.Diff(expectedNames, names); diff != "" {
+		t.Errorf("span names mismatch (-want +got):\n%s", diff)
+	}
+
+	expectedStatements := []string{
+		`SELECT * FROM "categories" WHERE "categories"."partner_id" = $1`,
+		`SELECT * FROM "materials" WHERE "materials"."partner_id" = $1`,
+		`SELECT * FROM "partners" WHERE id = $1 ORDER BY "partners"."id" LIMIT 1`,
+	}
+	if diff := cmp.Diff(expectedStatements, statements); diff != "" {
+		t.Errorf("statements mismatch (-want +got):\n%s", diff)
+	}
+
+	partners, ok := spans["SELECT partners"]
+	if !ok {
+		return
+	}
+	if partners.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("the span 'SELECT partners' is not a child of the request's span")
+	}
+	for _, name := range []string{"SELECT categories", "SELECT materials"} {
+		if s, ok := spans[name]; ok && s.Parent().SpanID() != partners.SpanContext().SpanID() {
+			t.Errorf("the span '%s' is not a child of the partner's query span", name)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	sql := `SELECT * FROM partners WHERE phone = '+351 912''345678' AND id = $1`
+
+	got := tracing.Sanitize(sql)
+
+	expected := `SELECT * FROM partners WHERE phone = ? AND id = $1`
+	if got != expected {
+		t.Errorf("statement mismatch: want '%s' got '%s'", expected, got)
+	}
+}
@@ -0,0 +1,3 @@
+//go:generate mockgen -package=mock -source=../worker.go -destination=./worker.go
+
+package mock
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ../worker.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	models "match/cmd/pkg/models"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// ClaimJob mocks base method.
+func (m *MockStore) ClaimJob(ctx context.Context, lockedUntil time.Time) (models.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimJob", ctx, lockedUntil)
+	ret0, _ := ret[0].(models.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimJob indicates an expected call of ClaimJob.
+func (mr *MockStoreMockRecorder) ClaimJob(ctx, lockedUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimJob", reflect.TypeOf((*MockStore)(nil).ClaimJob), ctx, lockedUntil)
+}
+
+// FinishJob mocks base method.
+func (m *MockStore) FinishJob(ctx context.Context, id, errMsg string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishJob", ctx, id, errMsg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishJob indicates an expected call of FinishJob.
+func (mr *MockStoreMockRecorder) FinishJob(ctx, id, errMsg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishJob", reflect.TypeOf((*MockStore)(nil).FinishJob), ctx, id, errMsg)
+}
+
+// SaveJobResults mocks base method.
+func (m *MockStore) SaveJobResults(ctx context.Context, id string, offset int, results []models.MatchResult, lockedUntil time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveJobResults", ctx, id, offset, results, lockedUntil)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveJobResults indicates an expected call of SaveJobResults.
+func (mr *MockStoreMockRecorder) SaveJobResults(ctx, id, offset, results, lockedUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveJobResults", reflect.TypeOf((*MockStore)(nil).SaveJobResults), ctx, id, offset, results, lockedUntil)
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"match/cmd/pkg/controller/partners"
+	"match/cmd/pkg/logging"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+)
+
+const (
+	// pollInterval is the time the worker waits before looking for a new job when there are none.
+	pollInterval = time.Second
+
+	// lease is the time a job stays locked to the worker after each update of its progress.
+	// Once the lock expires, e.g. because the app restarted, the job is resumed by the next worker looking for one.
+	lease = time.Minute
+
+	// chunkSize is the number of requests of a job processed between updates of its progress.
+	chunkSize = 100
+
+	// concurrency is the maximum number of requests of a job processed at the same time.
+	concurrency = 8
+)
+
+// Store can communicate with the persistent storage for our jobs.
+type Store interface {
+	// ClaimJob locks the next job to process until the given time and returns it with its requests.
+	ClaimJob(ctx context.Context, lockedUntil time.Time) (models.Job, error)
+
+	// SaveJobResults persists the results of the job's requests starting at the given offset and extends its lock.
+	SaveJobResults(ctx context.Context, id string, offset int, results []models.MatchResult, lockedUntil time.Time) error
+
+	// FinishJob marks the job as done, or as failed if the given error message is not empty.
+	FinishJob(ctx context.Context, id string, errMsg string) error
+}
+
+// Worker processes the match jobs in the background.
+type Worker struct {
+	store Store
+	db    partners.Database
+}
+
+// NewWorker creates a new Worker that persists the jobs in the given store and matches their requests
+// using the given database.
+func NewWorker(store Store, db partners.Database) *Worker {
+	return &Worker{store: store, db: db}
+}
+
+// Run processes the jobs, one at a time, until the context is done.
+// A job that is being processed when the context is done is left to be resumed later.
+func (w *Worker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if w.processNext(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// processNext claims and processes the next job, returning whether there was a job to claim.
+func (w *Worker) processNext(ctx context.Context) bool {
+	j, err := w.store.ClaimJob(ctx, time.Now().Add(lease))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("error claiming a job", "error", err)
+		}
+		return false
+	}
+
+	w.process(ctx, j)
+	return true
+}
+
+// process processes a job, logging, in the repository too, with its ID.
+func (w *Worker) process(ctx context.Context, j models.Job) {
+	logger := logging.FromContext(ctx).With("job_id", j.ID)
+	ctx = logging.WithLogger(ctx, logger)
+
+	if len(j.Requests) != j.Total {
+		logger.Error("job with corrupted requests", "requests", len(j.Requests), "expected", j.Total)
+		w.finish(ctx, j.ID, "the job's requests are corrupted")
+		return
+	}
+
+	for offset := j.Processed; offset < j.Total; offset += chunkSize {
+		end := offset + chunkSize
+		if end > j.Total {
+			end = j.Total
+		}
+
+		results := partners.MatchBatch(ctx, w.db, j.Requests[offset:end], concurrency)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if hasUnavailable(results) {
+			// the job is resumed from the last saved progress once its lock expires, hopefully with the database back
+			logger.Warn("database unavailable while processing the job")
+			return
+		}
+
+		err := w.store.SaveJobResults(ctx, j.ID, offset, results, time.Now().Add(lease))
+		if err != nil {
+			// the job is resumed from the last saved progress once its lock expires
+			logger.Error("error saving the results of the job", "error", err)
+			return
+		}
+	}
+
+	w.finish(ctx, j.ID, "")
+}
+
+func (w *Worker) finish(ctx context.Context, id string, errMsg string) {
+	err := w.store.FinishJob(ctx, id, errMsg)
+	if err != nil {
+		logging.FromContext(ctx).Error("error finishing the job", "error", err)
+	}
+}
+
+// hasUnavailable reports whether any of the results failed because the database was unavailable.
+func hasUnavailable(results []models.MatchResult) bool {
+	for _, r := range results {
+		if r.Error == partners.MatchErrServiceUnavailable {
+			return true
+		}
+	}
+	return false
+}
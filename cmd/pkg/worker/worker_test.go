@@ -0,0 +1,215 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	partnersmock "match/cmd/pkg/controller/partners/mock"
+	"match/cmd/pkg/models"
+	"match/cmd/pkg/repository"
+	"match/cmd/pkg/worker"
+	"match/cmd/pkg/worker/mock"
+
+	"github.com/golang/mock/gomock"
+)
+
+func newTestJob(total, processed int) models.Job {
+	j := models.Job{
+		ID:        "abc",
+		Status:    models.JobRunning,
+		Total:     total,
+		Processed: processed,
+	}
+
+	for i := 0; i < total; i++ {
+		j.Requests = append(j.Requests, models.MatchRequest{
+			Materials: []uint{1},
+			Address: models.Address{
+				Lat:  1.1,
+				Long: 1.2,
+			},
+		})
+	}
+
+	return j
+}
+
+// runUntilDone runs the worker until the context is canceled, failing the test if it takes too long.
+func runUntilDone(t *testing.T, ctx context.Context, w *worker.Worker) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+}
+
+func TestRun_ProcessesJobInChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	db := partnersmock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	j := newTestJob(150, 0)
+
+	gomock.InOrder(
+		store.EXPECT().
+			ClaimJob(gomock.Any(), gomock.Any()).
+			Return(j, nil),
+		store.EXPECT().
+			SaveJobResults(gomock.Any(), j.ID, 0, gomock.Len(100), gomock.Any()).
+			Return(nil),
+		store.EXPECT().
+			SaveJobResults(gomock.Any(), j.ID, 100, gomock.Len(50), gomock.Any()).
+			Return(nil),
+		store.EXPECT().
+			FinishJob(gomock.Any(), j.ID, "").
+			DoAndReturn(func(context.Context, string, string) error {
+				cancel()
+				return nil
+			}),
+	)
+
+	store.EXPECT().
+		ClaimJob(gomock.Any(), gomock.Any()).
+		Return(models.Job{}, repository.ErrNotFound).
+		AnyTimes()
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{{ID: 1}}, nil).
+		Times(150)
+
+	runUntilDone(t, ctx, worker.NewWorker(store, db))
+}
+
+func TestRun_ResumesJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	db := partnersmock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	j := newTestJob(150, 100)
+
+	gomock.InOrder(
+		store.EXPECT().
+			ClaimJob(gomock.Any(), gomock.Any()).
+			Return(j, nil),
+		store.EXPECT().
+			SaveJobResults(gomock.Any(), j.ID, 100, gomock.Len(50), gomock.Any()).
+			Return(nil),
+		store.EXPECT().
+			FinishJob(gomock.Any(), j.ID, "").
+			DoAndReturn(func(context.Context, string, string) error {
+				cancel()
+				return nil
+			}),
+	)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), []uint{1}, float32(1.1), float32(1.2)).
+		Return([]models.Partner{{ID: 1}}, nil).
+		Times(50)
+
+	runUntilDone(t, ctx, worker.NewWorker(store, db))
+}
+
+func TestRun_CorruptedJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	db := partnersmock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	j := newTestJob(2, 0)
+	j.Total = 3
+
+	gomock.InOrder(
+		store.EXPECT().
+			ClaimJob(gomock.Any(), gomock.Any()).
+			Return(j, nil),
+		store.EXPECT().
+			FinishJob(gomock.Any(), j.ID, gomock.Not("")).
+			DoAndReturn(func(context.Context, string, string) error {
+				cancel()
+				return nil
+			}),
+	)
+
+	runUntilDone(t, ctx, worker.NewWorker(store, db))
+}
+
+func TestRun_SaveFailureLeavesJobToBeResumed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	db := partnersmock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	j := newTestJob(150, 0)
+
+	gomock.InOrder(
+		store.EXPECT().
+			ClaimJob(gomock.Any(), gomock.Any()).
+			Return(j, nil),
+		store.EXPECT().
+			SaveJobResults(gomock.Any(), j.ID, 0, gomock.Len(100), gomock.Any()).
+			DoAndReturn(func(context.Context, string, int, []models.MatchResult, time.Time) error {
+				cancel()
+				return errors.New("some error")
+			}),
+	)
+
+	db.EXPECT().
+		GetMatches(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, nil).
+		Times(100)
+
+	runUntilDone(t, ctx, worker.NewWorker(store, db))
+}
+
+func TestRun_DatabaseUnavailableLeavesJobToBeResumed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock.NewMockStore(ctrl)
+	db := partnersmock.NewMockDatabase(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	j := newTestJob(150, 0)
+
+	store.EXPECT().
+		ClaimJob(gomock.Any(), gomock.Any()).
+		Return(j, nil)
+
+	store.EXPECT().
+		ClaimJob(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, time.Time) (models.Job, error) {
+			cancel()
+			return models.Job{}, repository.ErrNotFound
+		}).
+		AnyTimes()
+
+	// neither the results nor the job's failure are saved
+	db.EXPECT().
+		GetMatches(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, repository.ErrUnavailable).
+		Times(100)
+
+	runUntilDone(t, ctx, worker.NewWorker(store, db))
+}
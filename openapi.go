@@ -0,0 +1,10 @@
+// Package match holds the OpenAPI specification of the API, which is the source of truth of its routes: they are
+// registered from it, see cmd/pkg/openapi.
+package match
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI specification of the API, i.e. openapi.yml.
+//
+//go:embed openapi.yml
+var OpenAPI []byte
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id              VARCHAR(32) PRIMARY KEY,
+    status          VARCHAR(16) NOT NULL,
+    requests        JSONB NOT NULL,
+    total           INT NOT NULL,
+    processed       INT NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    locked_until    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS jobs_status_created_at_idx ON jobs (status, created_at);
+
+CREATE TABLE IF NOT EXISTS job_results
+(
+    job_id  VARCHAR(32) NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    idx     INT NOT NULL,
+    result  JSONB NOT NULL,
+    PRIMARY KEY (job_id, idx)
+);
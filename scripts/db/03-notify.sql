@@ -0,0 +1,51 @@
+-- Notifies the 'partner_changes' channel of every change to a partner, its categories or its materials, with a
+-- payload like {"table": "materials", "op": "UPDATE", "partner_id": 1}. A truncate notifies a partner_id of 0.
+CREATE OR REPLACE FUNCTION notify_partner_change() RETURNS TRIGGER
+AS $$
+DECLARE
+    new_id INT;
+    old_id INT;
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        new_id := 0;
+    ELSIF TG_TABLE_NAME = 'partners' THEN
+        IF TG_OP <> 'DELETE' THEN new_id := NEW.id; END IF;
+        IF TG_OP <> 'INSERT' THEN old_id := OLD.id; END IF;
+    ELSE
+        IF TG_OP <> 'DELETE' THEN new_id := NEW.partner_id; END IF;
+        IF TG_OP <> 'INSERT' THEN old_id := OLD.partner_id; END IF;
+    END IF;
+
+    IF new_id IS NOT NULL THEN
+        PERFORM pg_notify('partner_changes', json_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'partner_id', new_id)::TEXT);
+    END IF;
+
+    -- a row moved to another partner changes both partners
+    IF old_id IS NOT NULL AND old_id IS DISTINCT FROM new_id THEN
+        PERFORM pg_notify('partner_changes', json_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'partner_id', old_id)::TEXT);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS partners_notify ON partners;
+CREATE TRIGGER partners_notify AFTER INSERT OR UPDATE OR DELETE ON partners
+    FOR EACH ROW EXECUTE FUNCTION notify_partner_change();
+DROP TRIGGER IF EXISTS partners_notify_truncate ON partners;
+CREATE TRIGGER partners_notify_truncate AFTER TRUNCATE ON partners
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_partner_change();
+
+DROP TRIGGER IF EXISTS categories_notify ON categories;
+CREATE TRIGGER categories_notify AFTER INSERT OR UPDATE OR DELETE ON categories
+    FOR EACH ROW EXECUTE FUNCTION notify_partner_change();
+DROP TRIGGER IF EXISTS categories_notify_truncate ON categories;
+CREATE TRIGGER categories_notify_truncate AFTER TRUNCATE ON categories
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_partner_change();
+
+DROP TRIGGER IF EXISTS materials_notify ON materials;
+CREATE TRIGGER materials_notify AFTER INSERT OR UPDATE OR DELETE ON materials
+    FOR EACH ROW EXECUTE FUNCTION notify_partner_change();
+DROP TRIGGER IF EXISTS materials_notify_truncate ON materials;
+CREATE TRIGGER materials_notify_truncate AFTER TRUNCATE ON materials
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_partner_change();
@@ -0,0 +1,4 @@
+-- The categories and materials are looked up by partner, e.g. when aggregating them for the matched partners, but
+-- their primary keys start with their own id.
+CREATE INDEX IF NOT EXISTS categories_partner_id_idx ON categories (partner_id);
+CREATE INDEX IF NOT EXISTS materials_partner_id_idx ON materials (partner_id);
@@ -0,0 +1,12 @@
+-- The API keys authenticate the clients of the API. Only the SHA-256 hash of a key is stored, the key itself is shown
+-- once, when it is created.
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id          SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    hash        VARCHAR(64) NOT NULL UNIQUE,
+    role        VARCHAR(16) NOT NULL,
+    scopes      JSONB NOT NULL DEFAULT '[]',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at  TIMESTAMPTZ
+);
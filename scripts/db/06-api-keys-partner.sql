@@ -0,0 +1,2 @@
+-- A partner's API key, like a partner's JWT, is for its partner, which is the only one it can see and edit.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS partner_id INT REFERENCES partners(id);
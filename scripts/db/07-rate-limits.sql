@@ -0,0 +1,9 @@
+-- The token buckets of the rate limits shared by all the instances of the app, see RATE_LIMIT_STORE. They are
+-- deleted once full again, and losing them on a crash only resets the limits, so the table is not logged.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits
+(
+    key         TEXT PRIMARY KEY,
+    tokens      DOUBLE PRECISION NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL,
+    full_at     TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,17 @@
+-- The responses of the POST requests with an 'Idempotency-Key' header, by client and key, replayed to their retries.
+-- A request in progress has no status code yet, and expires_at is then the time after which it is considered
+-- abandoned, e.g. because its instance crashed, and may be retried.
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    client          TEXT NOT NULL,
+    key             VARCHAR(255) NOT NULL,
+    fingerprint     VARCHAR(64) NOT NULL,
+    status_code     INT,
+    headers         JSONB NOT NULL DEFAULT '{}',
+    body            BYTEA,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (client, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
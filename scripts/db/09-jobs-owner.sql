@@ -0,0 +1,3 @@
+-- The principal that created a job, which is, besides the admins, the only one that can see it and its results.
+-- The jobs created before have no owner, so only the admins can see them.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';